@@ -12,19 +12,21 @@ import (
 )
 
 type RemoteFSConfig struct {
-	LocalAddr               string                  `toml:"localaddr"`
-	ExternalAddr            string                  `toml:"externaladdr"`
-	JWTAlg                  []string                `toml:"jwtalg"`
-	JWTKey                  map[string]string       `toml:"jwtkey"`
-	ResolverAddr            string                  `toml:"resolveraddr"`
-	ResolverTimeout         config.Duration         `toml:"resolvertimeout"`
-	ResolverNotFoundTimeout config.Duration         `toml:"resolvernotfoundtimeout"`
-	WebTLS                  loaderConfig.TLSConfig  `toml:"webtls"`
-	ClientTLS               *loaderConfig.TLSConfig `toml:"client"`
-	LogFile                 string                  `toml:"logfile"`
-	LogLevel                string                  `toml:"loglevel"`
-	VFS                     map[string]*vfsrw.VFS   `toml:"vfs"`
-	Log                     zLogger.Config          `toml:"log"`
+	LocalAddr               string                     `toml:"localaddr"`
+	ExternalAddr            string                     `toml:"externaladdr"`
+	MetricsAddr             string                     `toml:"metricsaddr"`
+	JWTAlg                  []string                   `toml:"jwtalg"`
+	JWTKey                  map[string]string          `toml:"jwtkey"`
+	ResolverAddr            string                     `toml:"resolveraddr"`
+	ResolverTimeout         config.Duration            `toml:"resolvertimeout"`
+	ResolverNotFoundTimeout config.Duration            `toml:"resolvernotfoundtimeout"`
+	WebTLS                  loaderConfig.TLSConfig     `toml:"webtls"`
+	ClientTLS               *loaderConfig.TLSConfig    `toml:"client"`
+	LogFile                 string                     `toml:"logfile"`
+	LogLevel                string                     `toml:"loglevel"`
+	VFS                     map[string]*vfsrw.VFS      `toml:"vfs"`
+	Throttle                map[string]*vfsrw.Throttle `toml:"throttle"`
+	Log                     zLogger.Config             `toml:"log"`
 }
 
 func LoadRemoteFSConfig(fSys fs.FS, fp string, conf *RemoteFSConfig) error {
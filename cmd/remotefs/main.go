@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"github.com/je4/filesystem/v3/config"
 	"github.com/je4/filesystem/v3/pkg/remotefs"
+	"github.com/je4/filesystem/v3/pkg/throttlefs"
 	"github.com/je4/filesystem/v3/pkg/vfsrw"
 	"github.com/je4/miniresolver/v2/pkg/resolver"
 	loaderConfig "github.com/je4/trustutil/v2/pkg/config"
@@ -123,7 +124,19 @@ func main() {
 	}
 	defer resolverClient.Close()
 
-	ctrl, err := remotefs.NewMainController(conf.LocalAddr, conf.ExternalAddr, webTLSConfig, conf.JWTAlg, conf.JWTKey, vfs, logger)
+	// limits per client: jwt client_id, jwt subject "vfs.<vfs>" for tokens without client_id,
+	// or certificate subject
+	limiters := vfsrw.NewLimiters()
+	throttles := map[string]*throttlefs.Limiter{}
+	for client, cfg := range conf.Throttle {
+		limiter, err := limiters.Get(cfg)
+		if err != nil {
+			logger.Fatal().Err(err).Msgf("cannot create limiter for client '%s'", client)
+		}
+		throttles[client] = limiter
+	}
+
+	ctrl, err := remotefs.NewMainController(conf.LocalAddr, conf.ExternalAddr, conf.MetricsAddr, webTLSConfig, conf.JWTAlg, conf.JWTKey, vfs, throttles, logger)
 	if err != nil {
 		logger.Fatal().Msgf("cannot create controller: %v", err)
 	}
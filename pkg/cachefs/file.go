@@ -0,0 +1,42 @@
+package cachefs
+
+import (
+	"emperror.dev/errors"
+	"github.com/je4/filesystem/v3/pkg/writefs"
+	"io"
+	"io/fs"
+	"os"
+)
+
+// file is a local copy of a remote file. Stat returns the info of the remote file.
+type file struct {
+	*os.File
+	info fs.FileInfo
+}
+
+func (f *file) Stat() (fs.FileInfo, error) {
+	return f.info, nil
+}
+
+// fileWrite removes the cache entry again after the upload, because it may have been read in the meantime
+type fileWrite struct {
+	writefs.FileWrite
+	c    *cacheFS
+	name string
+}
+
+func (fw *fileWrite) Close() error {
+	defer fw.c.invalidateName(fw.name)
+	return errors.WithStack(fw.FileWrite.Close())
+}
+
+func (fw *fileWrite) Abort() error {
+	return writefs.Abort(fw.FileWrite)
+}
+
+var (
+	_ fs.File                = &file{}
+	_ io.ReaderAt            = &file{}
+	_ io.Seeker              = &file{}
+	_ writefs.AbortFileWrite = &fileWrite{}
+)
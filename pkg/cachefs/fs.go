@@ -0,0 +1,368 @@
+// Package cachefs provides a read-through cache for remote filesystems like s3fsrw, sftpfsrw or remotefs.
+// Files are downloaded to a local folder, so that they support io.ReaderAt (e.g. for zipasfolder).
+package cachefs
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"emperror.dev/errors"
+	"encoding/hex"
+	"fmt"
+	"github.com/je4/filesystem/v3/pkg/osfsrw"
+	"github.com/je4/filesystem/v3/pkg/writefs"
+	"github.com/je4/utils/v2/pkg/zLogger"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+	"sync"
+)
+
+// cacheSuffix is the extension of all files in the cache folder
+const cacheSuffix = ".cache"
+
+// NewFS creates a cache for remote in the local folder cacheDir. The total size of the cached files is limited to maxBytes.
+// Files of former caches in cacheDir are removed.
+func NewFS(remote fs.FS, cacheDir string, maxBytes int64, logger zLogger.ZLogger) (*cacheFS, error) {
+	_logger := logger.With().Str("class", "cacheFS").Logger()
+	logger = &_logger
+	cache, err := osfsrw.NewFS(cacheDir, logger)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot open cache folder '%s'", cacheDir)
+	}
+	stale, err := fs.Glob(cache, "*"+cacheSuffix)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot list cache folder '%s'", cacheDir)
+	}
+	for _, name := range stale {
+		if err := writefs.Remove(cache, name); err != nil {
+			return nil, errors.Wrapf(err, "cannot remove stale cache file '%s'", name)
+		}
+	}
+	return &cacheFS{
+		remote: remote,
+		cache:  cache,
+		lru:    newLRU(maxBytes),
+		logger: logger,
+	}, nil
+}
+
+type cacheFS struct {
+	remote fs.FS
+	cache  writefs.ReadWriteFS
+	lock   sync.Mutex
+	lru    *lru
+	// generation is incremented on every invalidation. Downloads which overlap an invalidation are not cached.
+	generation uint64
+	logger     zLogger.ZLogger
+}
+
+func cacheName(name string) string {
+	hash := sha256.Sum256([]byte(name))
+	return hex.EncodeToString(hash[:]) + cacheSuffix
+}
+
+// tempName returns a unique name for a download of cacheName
+func tempName(cacheName string) string {
+	randBytes := make([]byte, 8)
+	rand.Read(randBytes)
+	return strings.TrimSuffix(cacheName, cacheSuffix) + "." + hex.EncodeToString(randBytes) + cacheSuffix
+}
+
+func (c *cacheFS) String() string {
+	return fmt.Sprintf("cacheFS(%v)", c.remote)
+}
+
+func (c *cacheFS) Sub(dir string) (fs.FS, error) {
+	return writefs.NewSubFS(c, dir), nil
+}
+
+func (c *cacheFS) Stat(name string) (fs.FileInfo, error) {
+	return c.StatContext(context.Background(), name)
+}
+
+func (c *cacheFS) StatContext(ctx context.Context, name string) (fs.FileInfo, error) {
+	info, err := writefs.StatContext(ctx, c.remote, name)
+	return info, errors.Wrapf(err, "cannot stat '%s'", name)
+}
+
+func (c *cacheFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	return c.ReadDirContext(context.Background(), name)
+}
+
+func (c *cacheFS) ReadDirContext(ctx context.Context, name string) ([]fs.DirEntry, error) {
+	entries, err := writefs.ReadDirContext(ctx, c.remote, name)
+	return entries, errors.Wrapf(err, "cannot read directory '%s'", name)
+}
+
+// Open returns the cached copy of name if its size, modification time and entity tag match the remote file.
+// Otherwise, the file is downloaded to the cache. Folders and files larger than the cache are not cached.
+func (c *cacheFS) Open(name string) (fs.File, error) {
+	return c.OpenContext(context.Background(), name)
+}
+
+func (c *cacheFS) OpenContext(ctx context.Context, name string) (fs.File, error) {
+	info, err := writefs.StatContext(ctx, c.remote, name)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot stat '%s'", name)
+	}
+	if info.IsDir() || info.Size() > c.lru.maxBytes {
+		fp, err := writefs.OpenContext(ctx, c.remote, name)
+		return fp, errors.Wrapf(err, "cannot open '%s'", name)
+	}
+	if fp := c.openCached(name, info); fp != nil {
+		return fp, nil
+	}
+	return c.download(ctx, name, info)
+}
+
+// openCached returns the valid cache file of name or nil
+func (c *cacheFS) openCached(name string, info fs.FileInfo) fs.File {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	e, ok := c.lru.get(name)
+	if !ok {
+		return nil
+	}
+	if e.isValid(info) {
+		fp, err := c.openFile(e.cacheName, info)
+		if err == nil {
+			c.logger.Debug().Msgf("cache hit '%s'", name)
+			return fp
+		}
+		c.logger.Warn().Err(err).Msgf("cannot open cache file of '%s'", name)
+	}
+	c.lru.remove(name)
+	c.removeFile(e.cacheName)
+	return nil
+}
+
+// download fetches name to a unique temporary file, which is renamed to its cache name when the entry is added.
+// Concurrent downloads of the same name therefore never remove the cache file of each other.
+func (c *cacheFS) download(ctx context.Context, name string, info fs.FileInfo) (fs.File, error) {
+	c.lock.Lock()
+	generation := c.generation
+	c.lock.Unlock()
+
+	c.logger.Debug().Msgf("cache miss '%s'", name)
+	e := &entry{
+		name:      name,
+		cacheName: cacheName(name),
+		size:      info.Size(),
+		modTime:   info.ModTime(),
+		etag:      etag(info),
+	}
+	tmpName := tempName(e.cacheName)
+	written, err := c.fetch(ctx, name, tmpName)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	fp, err := c.openFile(tmpName, info)
+	if err != nil {
+		c.removeFile(tmpName)
+		return nil, errors.WithStack(err)
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.generation != generation || written != e.size {
+		// the file has changed during the download, so the copy is only used once
+		c.removeFile(tmpName)
+		return fp, nil
+	}
+	if err := writefs.Rename(c.cache, tmpName, e.cacheName); err != nil {
+		c.logger.Warn().Err(err).Msgf("cannot rename cache file of '%s'", name)
+		c.removeFile(tmpName)
+		return fp, nil
+	}
+	for _, old := range c.lru.add(e) {
+		if old.cacheName != e.cacheName {
+			c.removeFile(old.cacheName)
+		}
+	}
+	return fp, nil
+}
+
+// fetch copies name from the remote filesystem to the cache file tmpName
+func (c *cacheFS) fetch(ctx context.Context, name, tmpName string) (int64, error) {
+	src, err := writefs.OpenContext(ctx, c.remote, name)
+	if err != nil {
+		return 0, errors.Wrapf(err, "cannot open '%s'", name)
+	}
+	defer src.Close()
+	dst, err := writefs.Create(c.cache, tmpName)
+	if err != nil {
+		return 0, errors.Wrapf(err, "cannot create cache file for '%s'", name)
+	}
+	written, err := io.Copy(dst, src)
+	if err != nil {
+		dst.Close()
+		c.removeFile(tmpName)
+		return 0, errors.Wrapf(err, "cannot download '%s'", name)
+	}
+	if err := dst.Close(); err != nil {
+		c.removeFile(tmpName)
+		return 0, errors.Wrapf(err, "cannot close cache file for '%s'", name)
+	}
+	return written, nil
+}
+
+func (c *cacheFS) openFile(cacheName string, info fs.FileInfo) (*file, error) {
+	fp, err := c.cache.Open(cacheName)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot open cache file '%s'", cacheName)
+	}
+	osFile, ok := fp.(*os.File)
+	if !ok {
+		fp.Close()
+		return nil, errors.Errorf("cache file '%s' is not a local file", cacheName)
+	}
+	return &file{File: osFile, info: info}, nil
+}
+
+// removeFile deletes a file of the cache folder. Open files stay readable on unix systems.
+func (c *cacheFS) removeFile(cacheName string) {
+	if err := writefs.Remove(c.cache, cacheName); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		c.logger.Warn().Err(err).Msgf("cannot remove cache file '%s'", cacheName)
+	}
+}
+
+// invalidate removes the entries for which fn returns true
+func (c *cacheFS) invalidate(fn func(e *entry) bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.generation++
+	for _, e := range c.lru.removeFunc(fn) {
+		c.removeFile(e.cacheName)
+	}
+}
+
+func (c *cacheFS) invalidateName(name string) {
+	c.invalidate(func(e *entry) bool {
+		return e.name == name
+	})
+}
+
+// Create writes name to the remote filesystem. The cache entry of name is removed.
+func (c *cacheFS) Create(name string) (writefs.FileWrite, error) {
+	return c.CreateContext(context.Background(), name)
+}
+
+func (c *cacheFS) CreateContext(ctx context.Context, name string) (writefs.FileWrite, error) {
+	c.invalidateName(name)
+	fp, err := writefs.CreateContext(ctx, c.remote, name)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return &fileWrite{FileWrite: fp, c: c, name: name}, nil
+}
+
+func (c *cacheFS) CreateAtomic(name string) (writefs.FileWrite, error) {
+	return c.CreateAtomicContext(context.Background(), name)
+}
+
+func (c *cacheFS) CreateAtomicContext(ctx context.Context, name string) (writefs.FileWrite, error) {
+	c.invalidateName(name)
+	fp, err := writefs.CreateAtomicContext(ctx, c.remote, name)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return &fileWrite{FileWrite: fp, c: c, name: name}, nil
+}
+
+func (c *cacheFS) OpenFile(name string, flag int, perm fs.FileMode) (writefs.FileWrite, error) {
+	return c.OpenFileContext(context.Background(), name, flag, perm)
+}
+
+func (c *cacheFS) OpenFileContext(ctx context.Context, name string, flag int, perm fs.FileMode) (writefs.FileWrite, error) {
+	c.invalidateName(name)
+	fp, err := writefs.OpenFileContext(ctx, c.remote, name, flag, perm)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return &fileWrite{FileWrite: fp, c: c, name: name}, nil
+}
+
+func (c *cacheFS) CopyFile(src, dst string) error {
+	defer c.invalidateName(dst)
+	if _fsys, ok := c.remote.(writefs.CopyFileFS); ok {
+		return errors.WithStack(_fsys.CopyFile(src, dst))
+	}
+	return errors.Wrap(writefs.ErrNotImplemented, "CopyFile")
+}
+
+func (c *cacheFS) MkDir(name string) error {
+	return c.MkDirContext(context.Background(), name)
+}
+
+func (c *cacheFS) MkDirContext(ctx context.Context, name string) error {
+	return errors.WithStack(writefs.MkDirContext(ctx, c.remote, name))
+}
+
+func (c *cacheFS) Remove(name string) error {
+	return c.RemoveContext(context.Background(), name)
+}
+
+func (c *cacheFS) RemoveContext(ctx context.Context, name string) error {
+	defer c.invalidateName(name)
+	return errors.WithStack(writefs.RemoveContext(ctx, c.remote, name))
+}
+
+func (c *cacheFS) RemoveAll(name string) error {
+	return c.RemoveAllContext(context.Background(), name)
+}
+
+func (c *cacheFS) RemoveAllContext(ctx context.Context, name string) error {
+	defer c.invalidate(func(e *entry) bool {
+		return name == "." || e.name == name || strings.HasPrefix(e.name, name+"/")
+	})
+	return errors.WithStack(writefs.RemoveAllContext(ctx, c.remote, name))
+}
+
+func (c *cacheFS) Rename(oldPath, newPath string) error {
+	return c.RenameContext(context.Background(), oldPath, newPath)
+}
+
+func (c *cacheFS) RenameContext(ctx context.Context, oldPath, newPath string) error {
+	defer c.invalidate(func(e *entry) bool {
+		return e.name == oldPath || e.name == newPath ||
+			strings.HasPrefix(e.name, oldPath+"/") || strings.HasPrefix(e.name, newPath+"/")
+	})
+	return errors.WithStack(writefs.RenameContext(ctx, c.remote, oldPath, newPath))
+}
+
+// Close removes all cached files
+func (c *cacheFS) Close() error {
+	c.invalidate(func(e *entry) bool {
+		return true
+	})
+	return nil
+}
+
+var (
+	_ writefs.ReadWriteFS    = &cacheFS{}
+	_ writefs.CreateAtomicFS = &cacheFS{}
+	_ writefs.OpenFileFS     = &cacheFS{}
+	_ writefs.CopyFileFS     = &cacheFS{}
+	_ writefs.MkDirFS        = &cacheFS{}
+	_ writefs.RemoveFS       = &cacheFS{}
+	_ writefs.RemoveAllFS    = &cacheFS{}
+	_ writefs.RenameFS       = &cacheFS{}
+	_ writefs.CloseFS        = &cacheFS{}
+	_ fs.ReadDirFS           = &cacheFS{}
+	_ fs.StatFS              = &cacheFS{}
+	_ fs.SubFS               = &cacheFS{}
+	_ fmt.Stringer           = &cacheFS{}
+
+	_ writefs.CreateContextFS       = &cacheFS{}
+	_ writefs.CreateAtomicContextFS = &cacheFS{}
+	_ writefs.OpenFileContextFS     = &cacheFS{}
+	_ writefs.OpenContextFS         = &cacheFS{}
+	_ writefs.StatContextFS         = &cacheFS{}
+	_ writefs.ReadDirContextFS      = &cacheFS{}
+	_ writefs.MkDirContextFS        = &cacheFS{}
+	_ writefs.RemoveContextFS       = &cacheFS{}
+	_ writefs.RemoveAllContextFS    = &cacheFS{}
+	_ writefs.RenameContextFS       = &cacheFS{}
+)
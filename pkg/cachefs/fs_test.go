@@ -0,0 +1,186 @@
+package cachefs
+
+import (
+	"archive/zip"
+	"github.com/je4/filesystem/v3/pkg/memfsrw"
+	"github.com/je4/filesystem/v3/pkg/writefs"
+	"github.com/je4/filesystem/v3/pkg/zipasfolder"
+	"github.com/rs/zerolog"
+	"io"
+	"io/fs"
+	"os"
+	"sync"
+	"testing"
+)
+
+// countingFS counts the downloads of the wrapped filesystem
+type countingFS struct {
+	writefs.ReadWriteFS
+	lock  sync.Mutex
+	opens map[string]int
+}
+
+func (c *countingFS) Open(name string) (fs.File, error) {
+	c.lock.Lock()
+	c.opens[name]++
+	c.lock.Unlock()
+	return c.ReadWriteFS.Open(name)
+}
+
+func (c *countingFS) Stat(name string) (fs.FileInfo, error) {
+	return fs.Stat(c.ReadWriteFS, name)
+}
+
+func (c *countingFS) Remove(name string) error {
+	return writefs.Remove(c.ReadWriteFS, name)
+}
+
+func (c *countingFS) count(name string) int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.opens[name]
+}
+
+func TestCacheFS(t *testing.T) {
+	logger := zerolog.New(os.Stderr).With().Timestamp().Logger()
+	memFS, err := memfsrw.NewFS("remote", &logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	remote := &countingFS{ReadWriteFS: memFS, opens: map[string]int{}}
+	cacheDir := t.TempDir()
+	cacheFS, err := NewFS(remote, cacheDir, 10, &logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cacheFS.Close()
+
+	write := func(t *testing.T, fsys fs.FS, name, content string) {
+		t.Helper()
+		if _, err := writefs.WriteFile(fsys, name, []byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	read := func(t *testing.T, name, content string, downloads int) {
+		t.Helper()
+		fp, err := cacheFS.Open(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer fp.Close()
+		readerAt, ok := fp.(io.ReaderAt)
+		if !ok {
+			t.Fatalf("'%s' does not implement io.ReaderAt", name)
+		}
+		buf := make([]byte, len(content))
+		if _, err := readerAt.ReadAt(buf, 0); err != nil && err != io.EOF {
+			t.Fatal(err)
+		}
+		if string(buf) != content {
+			t.Errorf("wrong content of '%s': expected '%s', got '%s'", name, content, buf)
+		}
+		if count := remote.count(name); count != downloads {
+			t.Errorf("wrong number of downloads of '%s': expected %d, got %d", name, downloads, count)
+		}
+	}
+
+	t.Run("hit", func(t *testing.T) {
+		write(t, memFS, "a.txt", "aaaa")
+		read(t, "a.txt", "aaaa", 1)
+		read(t, "a.txt", "aaaa", 1)
+	})
+
+	t.Run("revalidate", func(t *testing.T) {
+		// change the remote file behind the cache
+		write(t, memFS, "a.txt", "AAAA")
+		read(t, "a.txt", "AAAA", 2)
+		read(t, "a.txt", "AAAA", 2)
+	})
+
+	t.Run("invalidate", func(t *testing.T) {
+		write(t, cacheFS, "a.txt", "bbbb")
+		read(t, "a.txt", "bbbb", 3)
+		if err := writefs.Remove(cacheFS, "a.txt"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := cacheFS.Open("a.txt"); err == nil {
+			t.Errorf("removed file is still readable")
+		}
+	})
+
+	t.Run("evict", func(t *testing.T) {
+		for _, name := range []string{"x.txt", "y.txt", "z.txt"} {
+			write(t, memFS, name, name[:1]+"123")
+			read(t, name, name[:1]+"123", 1)
+		}
+		// 3*4 bytes exceed the cache size of 10 bytes
+		read(t, "z.txt", "z123", 1)
+		read(t, "x.txt", "x123", 2)
+	})
+
+	t.Run("concurrent", func(t *testing.T) {
+		write(t, memFS, "c.txt", "cccc")
+		wg := sync.WaitGroup{}
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				fp, err := cacheFS.Open("c.txt")
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				fp.Close()
+			}()
+		}
+		wg.Wait()
+		// the cache file of the last download is still valid
+		read(t, "c.txt", "cccc", remote.count("c.txt"))
+		files, err := os.ReadDir(cacheDir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// "c.txt" and the most recent file of the evict test
+		if len(files) != 2 {
+			t.Errorf("expected 2 cache files, got %d", len(files))
+		}
+	})
+
+	t.Run("zipasfolder", func(t *testing.T) {
+		fp, err := writefs.Create(memFS, "archive.zip")
+		if err != nil {
+			t.Fatal(err)
+		}
+		zw := zip.NewWriter(fp)
+		w, err := zw.Create("content.txt")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte("zipped")); err != nil {
+			t.Fatal(err)
+		}
+		if err := zw.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if err := fp.Close(); err != nil {
+			t.Fatal(err)
+		}
+		largeCacheFS, err := NewFS(remote, t.TempDir(), 1024*1024, &logger)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer largeCacheFS.Close()
+		zipFS, err := zipasfolder.NewFS(largeCacheFS, 2, &logger)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer writefs.Close(zipFS)
+		data, err := fs.ReadFile(zipFS, "archive.zip/content.txt")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != "zipped" {
+			t.Errorf("wrong content '%s'", data)
+		}
+	})
+}
@@ -0,0 +1,104 @@
+package cachefs
+
+import (
+	"container/list"
+	"io/fs"
+	"time"
+)
+
+// entry is a file of the remote filesystem in the local cache
+type entry struct {
+	name      string
+	cacheName string
+	size      int64
+	modTime   time.Time
+	etag      string
+}
+
+// etagFileInfo is implemented by the file infos of backends with entity tags like s3fsrw
+type etagFileInfo interface {
+	ETag() string
+}
+
+func etag(info fs.FileInfo) string {
+	if e, ok := info.(etagFileInfo); ok {
+		return e.ETag()
+	}
+	return ""
+}
+
+// isValid checks whether the cached file still matches the remote file info
+func (e *entry) isValid(info fs.FileInfo) bool {
+	return e.size == info.Size() && e.modTime.Equal(info.ModTime()) && e.etag == etag(info)
+}
+
+// lru keeps the entries in order of use and limits their total size.
+// It is not thread-safe.
+type lru struct {
+	maxBytes int64
+	size     int64
+	order    *list.List
+	entries  map[string]*list.Element
+}
+
+func newLRU(maxBytes int64) *lru {
+	return &lru{
+		maxBytes: maxBytes,
+		order:    list.New(),
+		entries:  map[string]*list.Element{},
+	}
+}
+
+// get returns the entry of name and marks it as recently used
+func (l *lru) get(name string) (*entry, bool) {
+	elem, ok := l.entries[name]
+	if !ok {
+		return nil, false
+	}
+	l.order.MoveToFront(elem)
+	return elem.Value.(*entry), true
+}
+
+// add inserts e and returns the entries, which had to be removed to stay within maxBytes.
+// An existing entry with the same name is replaced and returned as well.
+func (l *lru) add(e *entry) []*entry {
+	evicted := []*entry{}
+	if old, ok := l.remove(e.name); ok {
+		evicted = append(evicted, old)
+	}
+	l.entries[e.name] = l.order.PushFront(e)
+	l.size += e.size
+	for l.size > l.maxBytes && l.order.Len() > 1 {
+		evicted = append(evicted, l.removeElement(l.order.Back()))
+	}
+	return evicted
+}
+
+// remove deletes the entry of name
+func (l *lru) remove(name string) (*entry, bool) {
+	elem, ok := l.entries[name]
+	if !ok {
+		return nil, false
+	}
+	return l.removeElement(elem), true
+}
+
+// removeFunc deletes all entries for which fn returns true
+func (l *lru) removeFunc(fn func(e *entry) bool) []*entry {
+	removed := []*entry{}
+	for elem := l.order.Front(); elem != nil; {
+		next := elem.Next()
+		if fn(elem.Value.(*entry)) {
+			removed = append(removed, l.removeElement(elem))
+		}
+		elem = next
+	}
+	return removed
+}
+
+func (l *lru) removeElement(elem *list.Element) *entry {
+	e := l.order.Remove(elem).(*entry)
+	delete(l.entries, e.name)
+	l.size -= e.size
+	return e
+}
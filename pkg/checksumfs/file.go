@@ -0,0 +1,85 @@
+package checksumfs
+
+import (
+	"emperror.dev/errors"
+	"encoding/hex"
+	"github.com/je4/filesystem/v3/pkg/writefs"
+	"github.com/je4/utils/v2/pkg/checksum"
+	"hash"
+	"io"
+	"io/fs"
+)
+
+// fileWrite calculates the checksums while writing and writes the sidecars on Close
+type fileWrite struct {
+	*checksum.ChecksumWriter
+	fp   writefs.FileWrite
+	fsys *checksumFS
+	name string
+}
+
+func (f *fileWrite) Close() error {
+	if err := f.ChecksumWriter.Close(); err != nil {
+		f.discard()
+		return errors.Wrapf(err, "cannot close checksum writer for '%s'", f.name)
+	}
+	if err := f.fp.Close(); err != nil {
+		return errors.Wrapf(err, "cannot close '%s'", f.name)
+	}
+	checksums, err := f.ChecksumWriter.GetChecksums()
+	if err != nil {
+		return errors.Wrapf(err, "cannot get checksums of '%s'", f.name)
+	}
+	return errors.WithStack(f.fsys.writeSidecars(f.name, checksums))
+}
+
+// Abort discards the file without writing sidecars
+func (f *fileWrite) Abort() error {
+	f.ChecksumWriter.Close()
+	return f.discard()
+}
+
+func (f *fileWrite) discard() error {
+	if err := writefs.Abort(f.fp); err != nil {
+		if !errors.Is(err, writefs.ErrNotImplemented) {
+			return errors.Wrapf(err, "cannot abort '%s'", f.name)
+		}
+		f.fp.Close()
+		if err := writefs.Remove(f.fsys.baseFS, f.name); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return errors.Wrapf(err, "cannot remove '%s'", f.name)
+		}
+	}
+	return nil
+}
+
+// verifyFile compares the checksum of the content with the sidecar when reaching EOF
+type verifyFile struct {
+	fs.File
+	name     string
+	alg      checksum.DigestAlgorithm
+	expected string
+	hash     hash.Hash
+	verified bool
+}
+
+func (f *verifyFile) Read(p []byte) (int, error) {
+	n, err := f.File.Read(p)
+	f.hash.Write(p[:n])
+	if err == io.EOF && !f.verified {
+		f.verified = true
+		if actual := hex.EncodeToString(f.hash.Sum(nil)); actual != f.expected {
+			return n, &ChecksumMismatchError{
+				Name:      f.name,
+				Algorithm: f.alg,
+				Expected:  f.expected,
+				Actual:    actual,
+			}
+		}
+	}
+	return n, err
+}
+
+var (
+	_ writefs.AbortFileWrite = (*fileWrite)(nil)
+	_ fs.File                = (*verifyFile)(nil)
+)
@@ -0,0 +1,283 @@
+package checksumfs
+
+import (
+	"emperror.dev/errors"
+	"fmt"
+	"github.com/je4/filesystem/v3/pkg/writefs"
+	"github.com/je4/utils/v2/pkg/checksum"
+	"github.com/je4/utils/v2/pkg/zLogger"
+	"io"
+	"io/fs"
+	"strings"
+	"time"
+)
+
+// ChecksumMismatchError is returned while reading a file whose content does not match its sidecar
+type ChecksumMismatchError struct {
+	Name      string
+	Algorithm checksum.DigestAlgorithm
+	Expected  string
+	Actual    string
+}
+
+func (e *ChecksumMismatchError) Error() string {
+	return fmt.Sprintf("checksum mismatch for '%s' (%s): expected %s, got %s", e.Name, e.Algorithm, e.Expected, e.Actual)
+}
+
+// SidecarName returns the name of the checksum sidecar of name
+func SidecarName(name string, alg checksum.DigestAlgorithm) string {
+	return fmt.Sprintf("%s.%s", name, strings.ToLower(string(alg)))
+}
+
+// SidecarContent returns the content of the checksum sidecar of name in the format '<digest> *<name>'.
+// The same format is used by the zip file writers of zipfsrw.
+func SidecarContent(name, digest string) []byte {
+	return []byte(fmt.Sprintf("%s *%s", digest, name))
+}
+
+// NewFS wraps baseFS. For every created file, a sidecar '<file>.<alg>' is written for each algorithm.
+// If verify is true, files with a sidecar are verified while reading.
+func NewFS(baseFS writefs.ReadWriteFS, algs []checksum.DigestAlgorithm, verify bool, logger zLogger.ZLogger) (*checksumFS, error) {
+	if len(algs) == 0 {
+		return nil, errors.New("no checksum algorithm")
+	}
+	for _, alg := range algs {
+		if !checksum.HashExists(alg) {
+			return nil, errors.Errorf("unknown checksum algorithm '%s'", alg)
+		}
+	}
+	_logger := logger.With().Str("class", "checksumFS").Logger()
+	logger = &_logger
+	return &checksumFS{
+		baseFS: baseFS,
+		algs:   algs,
+		verify: verify,
+		logger: logger,
+	}, nil
+}
+
+type checksumFS struct {
+	baseFS writefs.ReadWriteFS
+	algs   []checksum.DigestAlgorithm
+	verify bool
+	logger zLogger.ZLogger
+}
+
+func (fsys *checksumFS) String() string {
+	return fmt.Sprintf("checksumFS(%v)", fsys.baseFS)
+}
+
+func (fsys *checksumFS) Sub(dir string) (fs.FS, error) {
+	return NewFS(writefs.NewSubFS(fsys.baseFS, dir), fsys.algs, fsys.verify, fsys.logger)
+}
+
+// readSidecar returns the algorithm and digest of the first existing sidecar of name
+func (fsys *checksumFS) readSidecar(name string) (checksum.DigestAlgorithm, string, error) {
+	for _, alg := range fsys.algs {
+		sidecar := SidecarName(name, alg)
+		data, err := fs.ReadFile(fsys.baseFS, sidecar)
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			return "", "", errors.Wrapf(err, "cannot read sidecar '%s'", sidecar)
+		}
+		fields := strings.Fields(string(data))
+		if len(fields) == 0 {
+			return "", "", errors.Errorf("empty sidecar '%s'", sidecar)
+		}
+		return alg, strings.ToLower(fields[0]), nil
+	}
+	return "", "", errors.Wrapf(fs.ErrNotExist, "no sidecar for '%s'", name)
+}
+
+// writeSidecars writes the sidecars of name
+func (fsys *checksumFS) writeSidecars(name string, checksums map[checksum.DigestAlgorithm]string) error {
+	var errs = []error{}
+	for alg, cs := range checksums {
+		sidecar := SidecarName(name, alg)
+		if _, err := writefs.WriteFile(fsys.baseFS, sidecar, SidecarContent(name, cs)); err != nil {
+			errs = append(errs, errors.Wrapf(err, "cannot write sidecar file '%s'", sidecar))
+		}
+	}
+	return errors.Combine(errs...)
+}
+
+func (fsys *checksumFS) Open(name string) (fs.File, error) {
+	fp, err := fsys.baseFS.Open(name)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if !fsys.verify {
+		return fp, nil
+	}
+	alg, expected, err := fsys.readSidecar(name)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			fsys.logger.Debug().Msgf("no sidecar for '%s', skipping verification", name)
+			return fp, nil
+		}
+		fp.Close()
+		return nil, errors.WithStack(err)
+	}
+	h, err := checksum.GetHash(alg)
+	if err != nil {
+		fp.Close()
+		return nil, errors.Wrapf(err, "cannot create hash '%s'", alg)
+	}
+	return &verifyFile{
+		File:     fp,
+		name:     name,
+		alg:      alg,
+		expected: expected,
+		hash:     h,
+	}, nil
+}
+
+func (fsys *checksumFS) ReadFile(name string) ([]byte, error) {
+	fp, err := fsys.Open(name)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer fp.Close()
+	data, err := io.ReadAll(fp)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot read '%s'", name)
+	}
+	return data, nil
+}
+
+func (fsys *checksumFS) Stat(name string) (fs.FileInfo, error) {
+	info, err := fs.Stat(fsys.baseFS, name)
+	return info, errors.WithStack(err)
+}
+
+func (fsys *checksumFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	entries, err := fs.ReadDir(fsys.baseFS, name)
+	return entries, errors.WithStack(err)
+}
+
+// Create creates path. The sidecars are written on Close.
+func (fsys *checksumFS) Create(path string) (writefs.FileWrite, error) {
+	fp, err := writefs.Create(fsys.baseFS, path)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return fsys.newFileWrite(fp, path)
+}
+
+// CreateAtomic creates path with writefs.CreateAtomic. The sidecars are written after the file has been published.
+func (fsys *checksumFS) CreateAtomic(path string) (writefs.FileWrite, error) {
+	fp, err := writefs.CreateAtomic(fsys.baseFS, path)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return fsys.newFileWrite(fp, path)
+}
+
+func (fsys *checksumFS) newFileWrite(fp writefs.FileWrite, path string) (writefs.FileWrite, error) {
+	csWriter, err := checksum.NewChecksumWriter(fsys.algs, fp)
+	if err != nil {
+		writefs.Abort(fp)
+		fp.Close()
+		return nil, errors.Wrapf(err, "cannot create checksum writer for '%s'", path)
+	}
+	return &fileWrite{
+		ChecksumWriter: csWriter,
+		fp:             fp,
+		fsys:           fsys,
+		name:           path,
+	}, nil
+}
+
+func (fsys *checksumFS) Chtimes(name string, atime, mtime time.Time) error {
+	return errors.WithStack(writefs.Chtimes(fsys.baseFS, name, atime, mtime))
+}
+
+func (fsys *checksumFS) Chmod(name string, mode fs.FileMode) error {
+	return errors.WithStack(writefs.Chmod(fsys.baseFS, name, mode))
+}
+
+func (fsys *checksumFS) MkDir(path string) error {
+	return errors.WithStack(writefs.MkDir(fsys.baseFS, path))
+}
+
+func (fsys *checksumFS) MkDirAll(path string) error {
+	return errors.WithStack(writefs.MkDirAll(fsys.baseFS, path))
+}
+
+// Remove removes path and its sidecars
+func (fsys *checksumFS) Remove(path string) error {
+	if err := writefs.Remove(fsys.baseFS, path); err != nil {
+		return errors.WithStack(err)
+	}
+	var errs = []error{}
+	for _, alg := range fsys.algs {
+		if err := writefs.Remove(fsys.baseFS, SidecarName(path, alg)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			errs = append(errs, errors.Wrapf(err, "cannot remove sidecar '%s'", SidecarName(path, alg)))
+		}
+	}
+	return errors.Combine(errs...)
+}
+
+func (fsys *checksumFS) RemoveAll(path string) error {
+	return errors.WithStack(writefs.RemoveAll(fsys.baseFS, path))
+}
+
+// Rename renames oldPath and rewrites its sidecars for newPath
+func (fsys *checksumFS) Rename(oldPath, newPath string) error {
+	if err := writefs.Rename(fsys.baseFS, oldPath, newPath); err != nil {
+		return errors.WithStack(err)
+	}
+	var errs = []error{}
+	for _, alg := range fsys.algs {
+		oldSidecar := SidecarName(oldPath, alg)
+		data, err := fs.ReadFile(fsys.baseFS, oldSidecar)
+		if err != nil {
+			if !errors.Is(err, fs.ErrNotExist) {
+				errs = append(errs, errors.Wrapf(err, "cannot read sidecar '%s'", oldSidecar))
+			}
+			continue
+		}
+		fields := strings.Fields(string(data))
+		if len(fields) == 0 {
+			errs = append(errs, errors.Errorf("empty sidecar '%s'", oldSidecar))
+			continue
+		}
+		if err := fsys.writeSidecars(newPath, map[checksum.DigestAlgorithm]string{alg: fields[0]}); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if err := writefs.Remove(fsys.baseFS, oldSidecar); err != nil {
+			errs = append(errs, errors.Wrapf(err, "cannot remove sidecar '%s'", oldSidecar))
+		}
+	}
+	return errors.Combine(errs...)
+}
+
+func (fsys *checksumFS) Fullpath(name string) (string, error) {
+	return writefs.Fullpath(fsys.baseFS, name)
+}
+
+func (fsys *checksumFS) Close() error {
+	return writefs.Close(fsys.baseFS)
+}
+
+var (
+	_ writefs.ReadWriteFS    = (*checksumFS)(nil)
+	_ writefs.CreateAtomicFS = (*checksumFS)(nil)
+	_ writefs.MkDirFS        = (*checksumFS)(nil)
+	_ writefs.MkDirAllFS     = (*checksumFS)(nil)
+	_ writefs.ChtimesFS      = (*checksumFS)(nil)
+	_ writefs.ChmodFS        = (*checksumFS)(nil)
+	_ writefs.RenameFS       = (*checksumFS)(nil)
+	_ writefs.RemoveFS       = (*checksumFS)(nil)
+	_ writefs.RemoveAllFS    = (*checksumFS)(nil)
+	_ writefs.FullpathFS     = (*checksumFS)(nil)
+	_ writefs.CloseFS        = (*checksumFS)(nil)
+	_ fs.ReadDirFS           = (*checksumFS)(nil)
+	_ fs.ReadFileFS          = (*checksumFS)(nil)
+	_ fs.StatFS              = (*checksumFS)(nil)
+	_ fs.SubFS               = (*checksumFS)(nil)
+	_ fmt.Stringer           = (*checksumFS)(nil)
+)
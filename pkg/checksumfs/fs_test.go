@@ -0,0 +1,101 @@
+package checksumfs
+
+import (
+	"emperror.dev/errors"
+	"fmt"
+	"github.com/je4/filesystem/v3/pkg/osfsrw"
+	"github.com/je4/filesystem/v3/pkg/writefs"
+	"github.com/je4/utils/v2/pkg/checksum"
+	"github.com/rs/zerolog"
+	"io/fs"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestChecksumFS(t *testing.T) {
+	logger := zerolog.New(os.Stderr).With().Timestamp().Logger()
+	baseFS, err := osfsrw.NewFS(t.TempDir(), &logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fsys, err := NewFS(baseFS, []checksum.DigestAlgorithm{checksum.DigestSHA512, checksum.DigestMD5}, true, &logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := "hello checksum"
+
+	t.Run("create", func(t *testing.T) {
+		if _, err := writefs.WriteFile(fsys, "sub/test.txt", []byte(content)); err != nil {
+			t.Fatal(err)
+		}
+		for _, alg := range []checksum.DigestAlgorithm{checksum.DigestSHA512, checksum.DigestMD5} {
+			cs, err := checksum.Checksum(strings.NewReader(content), alg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, err := fs.ReadFile(baseFS, SidecarName("sub/test.txt", alg))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if expected := fmt.Sprintf("%s *sub/test.txt", cs); string(data) != expected {
+				t.Errorf("sidecar %s: expected '%s', got '%s'", alg, expected, data)
+			}
+		}
+	})
+
+	t.Run("verify", func(t *testing.T) {
+		data, err := fs.ReadFile(fsys, "sub/test.txt")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != content {
+			t.Errorf("expected '%s', got '%s'", content, data)
+		}
+	})
+
+	t.Run("mismatch", func(t *testing.T) {
+		if _, err := writefs.WriteFile(baseFS, "sub/test.txt", []byte("tampered")); err != nil {
+			t.Fatal(err)
+		}
+		_, err := fs.ReadFile(fsys, "sub/test.txt")
+		var mismatch *ChecksumMismatchError
+		if !errors.As(err, &mismatch) {
+			t.Fatalf("expected ChecksumMismatchError, got %v", err)
+		}
+		if mismatch.Algorithm != checksum.DigestSHA512 {
+			t.Errorf("expected algorithm sha512, got %s", mismatch.Algorithm)
+		}
+	})
+
+	t.Run("rename & remove", func(t *testing.T) {
+		if _, err := writefs.WriteFile(fsys, "a.txt", []byte(content)); err != nil {
+			t.Fatal(err)
+		}
+		if err := writefs.Rename(fsys, "a.txt", "b.txt"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := fs.ReadFile(fsys, "b.txt"); err != nil {
+			t.Fatal(err)
+		}
+		data, err := fs.ReadFile(baseFS, SidecarName("b.txt", checksum.DigestMD5))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasSuffix(string(data), " *b.txt") {
+			t.Errorf("sidecar not renamed: '%s'", data)
+		}
+		if err := writefs.Remove(fsys, "b.txt"); err != nil {
+			t.Fatal(err)
+		}
+		entries, err := fs.ReadDir(baseFS, ".")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, entry := range entries {
+			if strings.HasPrefix(entry.Name(), "a.txt") || strings.HasPrefix(entry.Name(), "b.txt") {
+				t.Errorf("'%s' not removed", entry.Name())
+			}
+		}
+	})
+}
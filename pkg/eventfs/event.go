@@ -0,0 +1,50 @@
+package eventfs
+
+import (
+	"context"
+	"time"
+)
+
+type Op string
+
+const (
+	OpCreate  Op = "create"
+	OpMkDir   Op = "mkdir"
+	OpRename  Op = "rename"
+	OpRemove  Op = "remove"
+	OpChtimes Op = "chtimes"
+	OpChmod   Op = "chmod"
+	OpSymlink Op = "symlink"
+)
+
+// Event describes a change of the filesystem. Failed changes contain the error message.
+type Event struct {
+	Time    time.Time `json:"time"`
+	FS      string    `json:"fs,omitempty"`
+	Op      Op        `json:"op"`
+	Path    string    `json:"path"`
+	NewPath string    `json:"newPath,omitempty"`
+	Target  string    `json:"target,omitempty"`
+	Size    int64     `json:"size,omitempty"`
+	Actor   string    `json:"actor,omitempty"`
+	Error   string    `json:"error,omitempty"`
+}
+
+// Subscriber receives the events of an eventFS.
+// Notify is called synchronously and should not block.
+type Subscriber interface {
+	Notify(event *Event) error
+}
+
+type actorKey struct{}
+
+// WithActor returns a context, which assigns the changes of the context aware functions to actor
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// Actor returns the actor of ctx or an empty string
+func Actor(ctx context.Context) string {
+	actor, _ := ctx.Value(actorKey{}).(string)
+	return actor
+}
@@ -0,0 +1,37 @@
+package eventfs
+
+import (
+	"context"
+	"emperror.dev/errors"
+	"github.com/je4/filesystem/v3/pkg/writefs"
+)
+
+// fileWrite counts the written bytes and emits the create event on Close
+type fileWrite struct {
+	writefs.FileWrite
+	e       *eventFS
+	ctx     context.Context
+	name    string
+	written int64
+}
+
+func (fw *fileWrite) Write(p []byte) (int, error) {
+	n, err := fw.FileWrite.Write(p)
+	fw.written += int64(n)
+	return n, err
+}
+
+func (fw *fileWrite) Close() error {
+	err := fw.FileWrite.Close()
+	fw.e.emit(fw.ctx, &Event{Op: OpCreate, Path: fw.name, Size: fw.written}, err)
+	return errors.WithStack(err)
+}
+
+// Abort discards the file without an event
+func (fw *fileWrite) Abort() error {
+	return errors.WithStack(writefs.Abort(fw.FileWrite))
+}
+
+var (
+	_ writefs.AbortFileWrite = &fileWrite{}
+)
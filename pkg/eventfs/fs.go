@@ -0,0 +1,221 @@
+// Package eventfs emits an event for every change of a filesystem
+package eventfs
+
+import (
+	"context"
+	"emperror.dev/errors"
+	"fmt"
+	"github.com/je4/filesystem/v3/pkg/writefs"
+	"github.com/je4/utils/v2/pkg/zLogger"
+	"io"
+	"io/fs"
+	"os"
+	"time"
+)
+
+// NewFS wraps base and sends the changes to the subscribers. name identifies the filesystem in the events.
+// Subscribers implementing io.Closer are closed with the filesystem.
+func NewFS(base fs.FS, name string, subscribers []Subscriber, logger zLogger.ZLogger) (*eventFS, error) {
+	_logger := logger.With().Str("class", "eventFS").Logger()
+	logger = &_logger
+	return &eventFS{
+		base:        base,
+		name:        name,
+		subscribers: subscribers,
+		logger:      logger,
+	}, nil
+}
+
+type eventFS struct {
+	base        fs.FS
+	name        string
+	subscribers []Subscriber
+	logger      zLogger.ZLogger
+}
+
+func (e *eventFS) emit(ctx context.Context, event *Event, err error) {
+	event.Time = time.Now()
+	event.FS = e.name
+	event.Actor = Actor(ctx)
+	if err != nil {
+		event.Error = err.Error()
+	}
+	for _, s := range e.subscribers {
+		if err := s.Notify(event); err != nil {
+			e.logger.Error().Err(err).Msgf("cannot notify %s event of '%s'", event.Op, event.Path)
+		}
+	}
+}
+
+func (e *eventFS) String() string {
+	return fmt.Sprintf("eventFS(%v)", e.base)
+}
+
+func (e *eventFS) Open(name string) (fs.File, error) {
+	return e.base.Open(name)
+}
+
+func (e *eventFS) OpenContext(ctx context.Context, name string) (fs.File, error) {
+	return writefs.OpenContext(ctx, e.base, name)
+}
+
+func (e *eventFS) Stat(name string) (fs.FileInfo, error) {
+	return fs.Stat(e.base, name)
+}
+
+func (e *eventFS) StatContext(ctx context.Context, name string) (fs.FileInfo, error) {
+	return writefs.StatContext(ctx, e.base, name)
+}
+
+func (e *eventFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	return fs.ReadDir(e.base, name)
+}
+
+func (e *eventFS) ReadDirContext(ctx context.Context, name string) ([]fs.DirEntry, error) {
+	return writefs.ReadDirContext(ctx, e.base, name)
+}
+
+func (e *eventFS) ReadFile(name string) ([]byte, error) {
+	return fs.ReadFile(e.base, name)
+}
+
+func (e *eventFS) Sub(dir string) (fs.FS, error) {
+	return writefs.NewSubFS(e, dir), nil
+}
+
+// newFileWrite emits a failed create event or wraps fp to emit the event on Close
+func (e *eventFS) newFileWrite(ctx context.Context, name string, fp writefs.FileWrite, err error) (writefs.FileWrite, error) {
+	if err != nil {
+		e.emit(ctx, &Event{Op: OpCreate, Path: name}, err)
+		return nil, errors.WithStack(err)
+	}
+	return &fileWrite{FileWrite: fp, e: e, ctx: context.WithoutCancel(ctx), name: name}, nil
+}
+
+func (e *eventFS) Create(name string) (writefs.FileWrite, error) {
+	return e.CreateContext(context.Background(), name)
+}
+
+func (e *eventFS) CreateContext(ctx context.Context, name string) (writefs.FileWrite, error) {
+	fp, err := writefs.CreateContext(ctx, e.base, name)
+	return e.newFileWrite(ctx, name, fp, err)
+}
+
+func (e *eventFS) CreateAtomic(name string) (writefs.FileWrite, error) {
+	return e.CreateAtomicContext(context.Background(), name)
+}
+
+func (e *eventFS) CreateAtomicContext(ctx context.Context, name string) (writefs.FileWrite, error) {
+	fp, err := writefs.CreateAtomicContext(ctx, e.base, name)
+	return e.newFileWrite(ctx, name, fp, err)
+}
+
+func (e *eventFS) OpenFile(name string, flag int, perm fs.FileMode) (writefs.FileWrite, error) {
+	return e.OpenFileContext(context.Background(), name, flag, perm)
+}
+
+// OpenFileContext emits a create event for files opened for writing
+func (e *eventFS) OpenFileContext(ctx context.Context, name string, flag int, perm fs.FileMode) (writefs.FileWrite, error) {
+	fp, err := writefs.OpenFileContext(ctx, e.base, name, flag, perm)
+	if flag&(os.O_WRONLY|os.O_RDWR) == 0 {
+		return fp, errors.WithStack(err)
+	}
+	return e.newFileWrite(ctx, name, fp, err)
+}
+
+func (e *eventFS) MkDir(name string) error {
+	return e.MkDirContext(context.Background(), name)
+}
+
+func (e *eventFS) MkDirContext(ctx context.Context, name string) error {
+	err := writefs.MkDirContext(ctx, e.base, name)
+	e.emit(ctx, &Event{Op: OpMkDir, Path: name}, err)
+	return errors.WithStack(err)
+}
+
+func (e *eventFS) Remove(name string) error {
+	return e.RemoveContext(context.Background(), name)
+}
+
+func (e *eventFS) RemoveContext(ctx context.Context, name string) error {
+	err := writefs.RemoveContext(ctx, e.base, name)
+	e.emit(ctx, &Event{Op: OpRemove, Path: name}, err)
+	return errors.WithStack(err)
+}
+
+func (e *eventFS) Rename(oldPath, newPath string) error {
+	return e.RenameContext(context.Background(), oldPath, newPath)
+}
+
+func (e *eventFS) RenameContext(ctx context.Context, oldPath, newPath string) error {
+	err := writefs.RenameContext(ctx, e.base, oldPath, newPath)
+	e.emit(ctx, &Event{Op: OpRename, Path: oldPath, NewPath: newPath}, err)
+	return errors.WithStack(err)
+}
+
+func (e *eventFS) Chtimes(name string, atime, mtime time.Time) error {
+	err := writefs.Chtimes(e.base, name, atime, mtime)
+	e.emit(context.Background(), &Event{Op: OpChtimes, Path: name}, err)
+	return errors.WithStack(err)
+}
+
+func (e *eventFS) Chmod(name string, mode fs.FileMode) error {
+	err := writefs.Chmod(e.base, name, mode)
+	e.emit(context.Background(), &Event{Op: OpChmod, Path: name}, err)
+	return errors.WithStack(err)
+}
+
+// Symlink emits an event with the link as path and oldname as target
+func (e *eventFS) Symlink(oldname, newname string) error {
+	err := writefs.Symlink(e.base, oldname, newname)
+	e.emit(context.Background(), &Event{Op: OpSymlink, Path: newname, Target: oldname}, err)
+	return errors.WithStack(err)
+}
+
+func (e *eventFS) ReadLink(name string) (string, error) {
+	return writefs.ReadLink(e.base, name)
+}
+
+func (e *eventFS) Lstat(name string) (fs.FileInfo, error) {
+	return writefs.Lstat(e.base, name)
+}
+
+// Close closes the base filesystem and the subscribers
+func (e *eventFS) Close() error {
+	errs := []error{writefs.Close(e.base)}
+	for _, s := range e.subscribers {
+		if closer, ok := s.(io.Closer); ok {
+			errs = append(errs, closer.Close())
+		}
+	}
+	return errors.WithStack(errors.Combine(errs...))
+}
+
+var (
+	_ writefs.ReadWriteFS           = &eventFS{}
+	_ writefs.CreateContextFS       = &eventFS{}
+	_ writefs.CreateAtomicFS        = &eventFS{}
+	_ writefs.CreateAtomicContextFS = &eventFS{}
+	_ writefs.OpenFileFS            = &eventFS{}
+	_ writefs.OpenFileContextFS     = &eventFS{}
+	_ writefs.MkDirFS               = &eventFS{}
+	_ writefs.MkDirContextFS        = &eventFS{}
+	_ writefs.RemoveFS              = &eventFS{}
+	_ writefs.RemoveContextFS       = &eventFS{}
+	_ writefs.RenameFS              = &eventFS{}
+	_ writefs.RenameContextFS       = &eventFS{}
+	_ writefs.ChtimesFS             = &eventFS{}
+	_ writefs.ChmodFS               = &eventFS{}
+	_ writefs.SymlinkFS             = &eventFS{}
+	_ writefs.ReadLinkFS            = &eventFS{}
+	_ writefs.LstatFS               = &eventFS{}
+	_ writefs.OpenContextFS         = &eventFS{}
+	_ writefs.StatContextFS         = &eventFS{}
+	_ writefs.ReadDirContextFS      = &eventFS{}
+	_ writefs.CloseFS               = &eventFS{}
+	_ fs.ReadDirFS                  = &eventFS{}
+	_ fs.ReadFileFS                 = &eventFS{}
+	_ fs.StatFS                     = &eventFS{}
+	_ fs.SubFS                      = &eventFS{}
+	_ fmt.Stringer                  = &eventFS{}
+)
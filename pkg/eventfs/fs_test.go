@@ -0,0 +1,166 @@
+package eventfs
+
+import (
+	"bytes"
+	"context"
+	"emperror.dev/errors"
+	"encoding/json"
+	"github.com/je4/filesystem/v3/pkg/memfsrw"
+	"github.com/je4/filesystem/v3/pkg/writefs"
+	"github.com/rs/zerolog"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type recorder struct {
+	lock   sync.Mutex
+	events []*Event
+}
+
+func (r *recorder) Notify(event *Event) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.events = append(r.events, event)
+	return nil
+}
+
+func TestEventFS(t *testing.T) {
+	logger := zerolog.New(os.Stderr).With().Timestamp().Logger()
+	memFS, err := memfsrw.NewFS("event", &logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := &recorder{}
+	auditLog := &bytes.Buffer{}
+	auditLogger := zerolog.New(auditLog)
+	eventFS, err := NewFS(memFS, "test", []Subscriber{rec, NewLogSubscriber(&auditLogger)}, &logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer eventFS.Close()
+
+	ctx := WithActor(context.Background(), "alice")
+	fp, err := writefs.CreateContext(ctx, eventFS, "a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fp.Write([]byte("0123456789")); err != nil {
+		t.Fatal(err)
+	}
+	if len(rec.events) != 0 {
+		t.Errorf("event before Close")
+	}
+	if err := fp.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := writefs.MkDir(eventFS, "dir"); err != nil {
+		t.Fatal(err)
+	}
+	if err := writefs.RenameContext(ctx, eventFS, "a.txt", "dir/b.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if err := writefs.RemoveContext(ctx, eventFS, "missing.txt"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected fs.ErrNotExist, got %v", err)
+	}
+
+	expected := []Event{
+		{FS: "test", Op: OpCreate, Path: "a.txt", Size: 10, Actor: "alice"},
+		{FS: "test", Op: OpMkDir, Path: "dir"},
+		{FS: "test", Op: OpRename, Path: "a.txt", NewPath: "dir/b.txt", Actor: "alice"},
+		{FS: "test", Op: OpRemove, Path: "missing.txt", Actor: "alice"},
+	}
+	if len(rec.events) != len(expected) {
+		t.Fatalf("expected %d events, got %d", len(expected), len(rec.events))
+	}
+	for i, event := range rec.events {
+		if event.Time.IsZero() {
+			t.Errorf("event %d has no time", i)
+		}
+		if (event.Error != "") != (i == 3) {
+			t.Errorf("wrong error in event %d: '%s'", i, event.Error)
+		}
+		event.Time = time.Time{}
+		event.Error = ""
+		if *event != expected[i] {
+			t.Errorf("wrong event %d: expected %+v, got %+v", i, expected[i], *event)
+		}
+	}
+	if lines := strings.Count(auditLog.String(), "\n"); lines != len(expected) {
+		t.Errorf("expected %d audit log lines, got %d", len(expected), lines)
+	}
+}
+
+func TestWebhookSubscriber(t *testing.T) {
+	logger := zerolog.New(os.Stderr).With().Timestamp().Logger()
+	var lock sync.Mutex
+	var calls int
+	var received []*Event
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+		calls++
+		// the first request fails and must be retried
+		if calls == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		event := &Event{}
+		if err := json.NewDecoder(r.Body).Decode(event); err != nil {
+			t.Error(err)
+		}
+		received = append(received, event)
+	}))
+	defer srv.Close()
+
+	webhook := NewWebhookSubscriber(srv.URL, srv.Client(), 10, 2, time.Millisecond, &logger)
+	for _, name := range []string{"a.txt", "b.txt"} {
+		if err := webhook.Notify(&Event{Op: OpRemove, Path: name}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := webhook.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := webhook.Notify(&Event{Op: OpRemove, Path: "c.txt"}); err == nil {
+		t.Errorf("closed webhook accepted event")
+	}
+	if calls != 3 {
+		t.Errorf("expected 3 requests, got %d", calls)
+	}
+	if len(received) != 2 || received[0].Path != "a.txt" || received[1].Path != "b.txt" {
+		t.Errorf("wrong events received: %+v", received)
+	}
+}
+
+func TestWebhookSubscriberDropped(t *testing.T) {
+	logger := zerolog.New(os.Stderr).With().Timestamp().Logger()
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer srv.Close()
+
+	webhook := NewWebhookSubscriber(srv.URL, srv.Client(), 1, 0, time.Millisecond, &logger)
+	var queueFull bool
+	for i := 0; i < 3; i++ {
+		if err := webhook.Notify(&Event{Op: OpRemove, Path: "a.txt"}); errors.Is(err, ErrQueueFull) {
+			queueFull = true
+		}
+	}
+	if !queueFull {
+		t.Fatal("expected ErrQueueFull")
+	}
+	if webhook.Dropped() == 0 {
+		t.Error("dropped events not counted")
+	}
+	close(release)
+	if err := webhook.Close(); err == nil {
+		t.Error("no error for dropped events")
+	}
+}
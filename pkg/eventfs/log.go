@@ -0,0 +1,42 @@
+package eventfs
+
+import (
+	"github.com/je4/utils/v2/pkg/zLogger"
+)
+
+// NewLogSubscriber writes every event as audit log entry. Failed changes are logged as warning.
+func NewLogSubscriber(logger zLogger.ZLogger) *logSubscriber {
+	_logger := logger.With().Str("class", "logSubscriber").Logger()
+	return &logSubscriber{logger: &_logger}
+}
+
+type logSubscriber struct {
+	logger zLogger.ZLogger
+}
+
+func (l *logSubscriber) Notify(event *Event) error {
+	ev := l.logger.Info()
+	if event.Error != "" {
+		ev = l.logger.Warn().Str("error", event.Error)
+	}
+	ev = ev.Time("time", event.Time).
+		Str("fs", event.FS).
+		Str("op", string(event.Op)).
+		Str("path", event.Path).
+		Str("actor", event.Actor)
+	if event.NewPath != "" {
+		ev = ev.Str("newPath", event.NewPath)
+	}
+	if event.Target != "" {
+		ev = ev.Str("target", event.Target)
+	}
+	if event.Op == OpCreate {
+		ev = ev.Int64("size", event.Size)
+	}
+	ev.Msg("audit")
+	return nil
+}
+
+var (
+	_ Subscriber = &logSubscriber{}
+)
@@ -0,0 +1,141 @@
+package eventfs
+
+import (
+	"bytes"
+	"emperror.dev/errors"
+	"encoding/json"
+	"github.com/je4/utils/v2/pkg/zLogger"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrQueueFull is returned by Notify if the webhook cannot keep up with the events
+var ErrQueueFull = errors.NewPlain("event queue full")
+
+// DefaultWebhookTimeout limits the requests of a webhook without a client
+const DefaultWebhookTimeout = 10 * time.Second
+
+// NewWebhookSubscriber posts every event as JSON to url in the background.
+// Failed requests and server errors are retried up to retries times with a doubling backoff.
+// If client is nil, a client with DefaultWebhookTimeout is used.
+// Close waits until the queued events have been sent.
+func NewWebhookSubscriber(url string, client *http.Client, queueSize int, retries int, backoff time.Duration, logger zLogger.ZLogger) *webhookSubscriber {
+	_logger := logger.With().Str("class", "webhookSubscriber").Str("url", url).Logger()
+	if client == nil {
+		client = &http.Client{Timeout: DefaultWebhookTimeout}
+	}
+	w := &webhookSubscriber{
+		url:     url,
+		client:  client,
+		retries: retries,
+		backoff: backoff,
+		queue:   make(chan *Event, queueSize),
+		done:    make(chan struct{}),
+		logger:  &_logger,
+	}
+	go w.run()
+	return w
+}
+
+type webhookSubscriber struct {
+	url     string
+	client  *http.Client
+	retries int
+	backoff time.Duration
+	lock    sync.Mutex
+	closed  bool
+	queue   chan *Event
+	done    chan struct{}
+	// dropped counts the events which have not been delivered
+	dropped atomic.Int64
+	logger  zLogger.ZLogger
+}
+
+func (w *webhookSubscriber) Notify(event *Event) error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if w.closed {
+		return errors.Errorf("webhook '%s' is closed", w.url)
+	}
+	select {
+	case w.queue <- event:
+		return nil
+	default:
+		w.dropped.Add(1)
+		return errors.Wrapf(ErrQueueFull, "cannot send %s event of '%s' to '%s'", event.Op, event.Path, w.url)
+	}
+}
+
+func (w *webhookSubscriber) run() {
+	defer close(w.done)
+	for event := range w.queue {
+		if err := w.send(event); err != nil {
+			w.dropped.Add(1)
+			w.logger.Error().Err(err).Msgf("cannot send %s event of '%s'", event.Op, event.Path)
+		}
+	}
+}
+
+func (w *webhookSubscriber) send(event *Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return errors.Wrap(err, "cannot marshal event")
+	}
+	backoff := w.backoff
+	for try := 0; ; try++ {
+		retry, err := w.post(data)
+		if err == nil {
+			return nil
+		}
+		if !retry || try >= w.retries {
+			return errors.Wrapf(err, "giving up after %d tries", try+1)
+		}
+		w.logger.Debug().Err(err).Msgf("retrying in %v", backoff)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// post sends data once and returns whether a failure may be retried
+func (w *webhookSubscriber) post(data []byte) (bool, error) {
+	resp, err := w.client.Post(w.url, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return true, errors.Wrapf(err, "cannot post to '%s'", w.url)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 300 {
+		return false, nil
+	}
+	err = errors.Errorf("'%s' returned %s", w.url, resp.Status)
+	return resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests, err
+}
+
+// Dropped returns the number of events which have been rejected or could not be sent
+func (w *webhookSubscriber) Dropped() int64 {
+	return w.dropped.Load()
+}
+
+// Close sends the queued events and stops the webhook.
+// An error is returned if events have been dropped.
+func (w *webhookSubscriber) Close() error {
+	w.lock.Lock()
+	if !w.closed {
+		w.closed = true
+		close(w.queue)
+	}
+	w.lock.Unlock()
+	<-w.done
+	if dropped := w.Dropped(); dropped > 0 {
+		return errors.Errorf("%d events have not been sent to '%s'", dropped, w.url)
+	}
+	return nil
+}
+
+var (
+	_ Subscriber = &webhookSubscriber{}
+	_ io.Closer  = &webhookSubscriber{}
+)
@@ -0,0 +1,59 @@
+package factoryconfig
+
+import (
+	"emperror.dev/errors"
+	"github.com/BurntSushi/toml"
+	trustconfig "github.com/je4/trustutil/v2/pkg/config"
+	"github.com/je4/utils/v2/pkg/checksum"
+	"github.com/je4/utils/v2/pkg/config"
+	"io/fs"
+)
+
+type S3Access struct {
+	AccessKey config.EnvString
+	SecretKey config.EnvString
+	URL       config.EnvString
+	UseSSL    bool
+}
+
+// FS registers a backend for all paths matching Pattern
+type FS struct {
+	// Type is one of os, mem, s3, remote, zip, zipchecksum, zipencrypted or zipread
+	Type string `toml:"type"`
+	// Pattern is the regular expression which identifies the paths of the backend
+	Pattern string `toml:"pattern"`
+	// Level is one of low, medium (default) or high. Higher levels are tried first.
+	Level string `toml:"level"`
+
+	// s3
+	S3Access map[string]*S3Access `toml:"s3access"`
+	Debug    bool                 `toml:"debug"`
+	CAPEM    string               `toml:"capem"`
+
+	// zip
+	NoCompression bool                       `toml:"nocompression"`
+	Checksums     []checksum.DigestAlgorithm `toml:"checksums"`
+	KeyURI        config.EnvString           `toml:"keyuri"`
+
+	// remote
+	Address   string                 `toml:"address"`
+	VFS       string                 `toml:"vfs"`
+	ClientTLS *trustconfig.TLSConfig `toml:"clienttls"`
+}
+
+type Config struct {
+	FS []*FS `toml:"fs"`
+}
+
+// LoadConfig reads the toml file fp from fSys
+func LoadConfig(fSys fs.FS, fp string) (*Config, error) {
+	data, err := fs.ReadFile(fSys, fp)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot read file [%v] %s", fSys, fp)
+	}
+	conf := &Config{}
+	if _, err := toml.Decode(string(data), conf); err != nil {
+		return nil, errors.Wrapf(err, "error loading config file %v", fp)
+	}
+	return conf, nil
+}
@@ -0,0 +1,144 @@
+package factoryconfig
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"emperror.dev/errors"
+	"github.com/je4/filesystem/v3/pkg/memfsrw"
+	"github.com/je4/filesystem/v3/pkg/osfsrw"
+	"github.com/je4/filesystem/v3/pkg/remotefs"
+	"github.com/je4/filesystem/v3/pkg/s3fsrw"
+	"github.com/je4/filesystem/v3/pkg/writefs"
+	"github.com/je4/filesystem/v3/pkg/zipfs"
+	"github.com/je4/filesystem/v3/pkg/zipfsrw"
+	"github.com/je4/trustutil/v2/pkg/loader"
+	"github.com/je4/utils/v2/pkg/checksum"
+	"github.com/je4/utils/v2/pkg/zLogger"
+	"io"
+	"strings"
+)
+
+// NewFactory creates a writefs.Factory with all filesystems of conf registered
+func NewFactory(conf *Config, logger zLogger.ZLogger) (*writefs.Factory, error) {
+	factory, err := writefs.NewFactory()
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot create factory")
+	}
+	for i, cfg := range conf.FS {
+		if err := register(factory, cfg, logger); err != nil {
+			return nil, errors.Wrapf(err, "cannot register fs #%d (%s)", i, cfg.Type)
+		}
+	}
+	return factory, nil
+}
+
+func register(factory *writefs.Factory, cfg *FS, logger zLogger.ZLogger) error {
+	level := writefs.MediumFS
+	switch strings.ToLower(cfg.Level) {
+	case "low":
+		level = writefs.LowFS
+	case "medium", "":
+	case "high":
+		level = writefs.HighFS
+	default:
+		return errors.Errorf("invalid level '%s'", cfg.Level)
+	}
+
+	var createFS writefs.CreateFSFunc
+	// connections and memory filesystems are shared, zip files are opened per Get
+	var cached bool
+	pattern := cfg.Pattern
+	switch strings.ToLower(cfg.Type) {
+	case "os":
+		cached = true
+		createFS = osfsrw.NewCreateFSFunc(logger)
+	case "mem":
+		cached = true
+		if pattern == "" {
+			pattern = memfsrw.PathRegexStr
+		}
+		createFS = memfsrw.NewCreateFSFunc(logger)
+	case "s3":
+		cached = true
+		if pattern == "" {
+			pattern = s3fsrw.ARNRegexStr
+		}
+		tlsConfig, err := newTLSConfig(cfg.CAPEM)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		access := map[string]*s3fsrw.S3Access{}
+		for partition, acc := range cfg.S3Access {
+			access[partition] = &s3fsrw.S3Access{
+				AccessKey: string(acc.AccessKey),
+				SecretKey: string(acc.SecretKey),
+				URL:       string(acc.URL),
+				UseSSL:    acc.UseSSL,
+			}
+		}
+		createFS = s3fsrw.NewCreateFSFunc(access, pattern, cfg.Debug, tlsConfig, logger)
+	case "remote":
+		cached = true
+		clientCert, clientLoader, err := loader.CreateClientLoader(cfg.ClientTLS, logger)
+		if err != nil {
+			return errors.Wrap(err, "cannot create client loader")
+		}
+		createFS = remotefs.NewCreateFSFunc(clientCert, cfg.Address, cfg.VFS, []io.Closer{clientLoader}, logger)
+	case "zip":
+		createFS = zipfsrw.NewCreateFSFunc(cfg.NoCompression, logger)
+	case "zipchecksum":
+		algs, err := checksums(cfg.Checksums)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		createFS = zipfsrw.NewCreateFSChecksumFunc(cfg.NoCompression, algs, logger)
+	case "zipencrypted":
+		algs, err := checksums(cfg.Checksums)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		if cfg.KeyURI == "" {
+			return errors.New("no key uri")
+		}
+		createFS = zipfsrw.NewCreateFSEncryptedChecksumFunc(cfg.NoCompression, algs, string(cfg.KeyURI), logger)
+	case "zipread":
+		createFS = zipfs.NewCreateFSFunc(logger)
+	default:
+		return errors.Errorf("unknown type '%s'", cfg.Type)
+	}
+	if pattern == "" {
+		return errors.New("no pattern")
+	}
+	if cached {
+		return errors.WithStack(factory.RegisterCached(createFS, pattern, level))
+	}
+	return errors.WithStack(factory.Register(createFS, pattern, level))
+}
+
+func checksums(algs []checksum.DigestAlgorithm) ([]checksum.DigestAlgorithm, error) {
+	if len(algs) == 0 {
+		return []checksum.DigestAlgorithm{checksum.DigestSHA512}, nil
+	}
+	for _, alg := range algs {
+		if !checksum.HashExists(alg) {
+			return nil, errors.Errorf("unknown checksum algorithm '%s'", alg)
+		}
+	}
+	return algs, nil
+}
+
+func newTLSConfig(caPEM string) (*tls.Config, error) {
+	switch caPEM {
+	case "ignore":
+		return &tls.Config{InsecureSkipVerify: true}, nil
+	case "":
+		// no tls
+		return nil, nil
+	default:
+		tlsConfig := &tls.Config{RootCAs: x509.NewCertPool()}
+		if ok := tlsConfig.RootCAs.AppendCertsFromPEM([]byte(caPEM)); !ok {
+			return nil, errors.New("cannot add root ca to CertPool")
+		}
+		return tlsConfig, nil
+	}
+}
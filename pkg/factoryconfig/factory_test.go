@@ -0,0 +1,66 @@
+package factoryconfig
+
+import (
+	"fmt"
+	"github.com/BurntSushi/toml"
+	"github.com/je4/filesystem/v3/pkg/writefs"
+	"github.com/rs/zerolog"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const testConfig = `
+[[fs]]
+type = "os"
+pattern = "^file://"
+level = "medium"
+
+[[fs]]
+type = "zipchecksum"
+pattern = "\\.zip$"
+level = "high"
+checksums = ["sha512", "md5"]
+`
+
+func TestNewFactory(t *testing.T) {
+	logger := zerolog.New(os.Stderr).With().Timestamp().Logger()
+	conf := &Config{}
+	if _, err := toml.Decode(testConfig, conf); err != nil {
+		t.Fatal(err)
+	}
+	factory, err := NewFactory(conf, &logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := filepath.ToSlash(t.TempDir())
+	zipPath := fmt.Sprintf("file://%s/test.zip", dir)
+	zipFS, err := factory.Get(zipPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := writefs.WriteFile(zipFS, "test.txt", []byte("test")); err != nil {
+		t.Fatal(err)
+	}
+	if err := factory.Release(zipPath); err != nil {
+		t.Fatal(err)
+	}
+	if err := factory.Close(); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"test.zip", "test.zip.sha512", "test.zip.md5"} {
+		if _, err := fs.Stat(os.DirFS(dir), name); err != nil {
+			t.Errorf("'%s' not created: %v", name, err)
+		}
+	}
+
+	t.Run("invalid", func(t *testing.T) {
+		if _, err := NewFactory(&Config{FS: []*FS{{Type: "unknown", Pattern: "^x"}}}, &logger); err == nil {
+			t.Error("unknown type accepted")
+		}
+		if _, err := NewFactory(&Config{FS: []*FS{{Type: "os", Pattern: "^x", Level: "highest"}}}, &logger); err == nil {
+			t.Error("invalid level accepted")
+		}
+	})
+}
@@ -0,0 +1,292 @@
+// Package filterfs provides a view of a filesystem, which hides entries by glob rules and may be read-only
+package filterfs
+
+import (
+	"context"
+	"emperror.dev/errors"
+	"fmt"
+	"github.com/je4/filesystem/v3/pkg/writefs"
+	"github.com/je4/utils/v2/pkg/zLogger"
+	"io"
+	"io/fs"
+	"path"
+	"time"
+)
+
+// NewFS creates a view of base which shows the entries matching include (all if empty) and not matching exclude.
+// If readOnly is set, all modifications fail with fs.ErrPermission.
+func NewFS(base fs.FS, include, exclude []string, readOnly bool, logger zLogger.ZLogger) (*filterFS, error) {
+	_logger := logger.With().Str("class", "filterFS").Logger()
+	logger = &_logger
+	r, err := newRules(include, exclude)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return &filterFS{
+		base:     base,
+		rules:    r,
+		readOnly: readOnly,
+		logger:   logger,
+	}, nil
+}
+
+type filterFS struct {
+	base     fs.FS
+	rules    *rules
+	readOnly bool
+	logger   zLogger.ZLogger
+}
+
+func (f *filterFS) String() string {
+	return fmt.Sprintf("filterFS(%v)", f.base)
+}
+
+func (f *filterFS) Sub(dir string) (fs.FS, error) {
+	return writefs.NewSubFS(f, dir), nil
+}
+
+func (f *filterFS) Stat(name string) (fs.FileInfo, error) {
+	return f.StatContext(context.Background(), name)
+}
+
+func (f *filterFS) StatContext(ctx context.Context, name string) (fs.FileInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, errors.Wrapf(fs.ErrInvalid, "invalid path '%s'", name)
+	}
+	info, err := writefs.StatContext(ctx, f.base, name)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot stat '%s'", name)
+	}
+	if !f.rules.visible(name, info.IsDir()) {
+		return nil, errors.Wrapf(fs.ErrNotExist, "cannot stat '%s'", name)
+	}
+	return info, nil
+}
+
+// Open opens a visible file. Folders contain only the visible entries.
+func (f *filterFS) Open(name string) (fs.File, error) {
+	return f.OpenContext(context.Background(), name)
+}
+
+func (f *filterFS) OpenContext(ctx context.Context, name string) (fs.File, error) {
+	info, err := f.StatContext(ctx, name)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if info.IsDir() {
+		entries, err := f.ReadDirContext(ctx, name)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return &dir{info: info, entries: entries}, nil
+	}
+	fp, err := writefs.OpenContext(ctx, f.base, name)
+	return fp, errors.Wrapf(err, "cannot open '%s'", name)
+}
+
+func (f *filterFS) ReadFile(name string) ([]byte, error) {
+	if _, err := f.Stat(name); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	data, err := fs.ReadFile(f.base, name)
+	return data, errors.Wrapf(err, "cannot read '%s'", name)
+}
+
+func (f *filterFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	return f.ReadDirContext(context.Background(), name)
+}
+
+func (f *filterFS) ReadDirContext(ctx context.Context, name string) ([]fs.DirEntry, error) {
+	if _, err := f.StatContext(ctx, name); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	entries, err := writefs.ReadDirContext(ctx, f.base, name)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot read directory '%s'", name)
+	}
+	result := []fs.DirEntry{}
+	for _, entry := range entries {
+		if f.rules.visible(path.Join(name, entry.Name()), entry.IsDir()) {
+			result = append(result, entry)
+		}
+	}
+	return result, nil
+}
+
+// checkWrite fails with fs.ErrPermission for read-only views and names which would be hidden
+func (f *filterFS) checkWrite(name string, isDir bool) error {
+	if f.readOnly {
+		return errors.Wrapf(fs.ErrPermission, "'%s' is read-only", name)
+	}
+	if !fs.ValidPath(name) {
+		return errors.Wrapf(fs.ErrInvalid, "invalid path '%s'", name)
+	}
+	if !f.rules.visible(name, isDir) {
+		return errors.Wrapf(fs.ErrPermission, "'%s' is hidden", name)
+	}
+	return nil
+}
+
+// checkModify fails with fs.ErrPermission for read-only views and fs.ErrNotExist for hidden names
+func (f *filterFS) checkModify(ctx context.Context, name string) error {
+	if f.readOnly {
+		return errors.Wrapf(fs.ErrPermission, "'%s' is read-only", name)
+	}
+	_, err := f.StatContext(ctx, name)
+	return errors.WithStack(err)
+}
+
+func (f *filterFS) Create(name string) (writefs.FileWrite, error) {
+	return f.CreateContext(context.Background(), name)
+}
+
+func (f *filterFS) CreateContext(ctx context.Context, name string) (writefs.FileWrite, error) {
+	if err := f.checkWrite(name, false); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	fp, err := writefs.CreateContext(ctx, f.base, name)
+	return fp, errors.WithStack(err)
+}
+
+// CreateAtomic checks the final name. The temporary file is created by the base filesystem,
+// so that it is not rejected by the rules.
+func (f *filterFS) CreateAtomic(name string) (writefs.FileWrite, error) {
+	return f.CreateAtomicContext(context.Background(), name)
+}
+
+func (f *filterFS) CreateAtomicContext(ctx context.Context, name string) (writefs.FileWrite, error) {
+	if err := f.checkWrite(name, false); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	fp, err := writefs.CreateAtomicContext(ctx, f.base, name)
+	return fp, errors.WithStack(err)
+}
+
+func (f *filterFS) OpenFile(name string, flag int, perm fs.FileMode) (writefs.FileWrite, error) {
+	return f.OpenFileContext(context.Background(), name, flag, perm)
+}
+
+func (f *filterFS) OpenFileContext(ctx context.Context, name string, flag int, perm fs.FileMode) (writefs.FileWrite, error) {
+	if err := f.checkWrite(name, false); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	fp, err := writefs.OpenFileContext(ctx, f.base, name, flag, perm)
+	return fp, errors.WithStack(err)
+}
+
+func (f *filterFS) MkDir(name string) error {
+	return f.MkDirContext(context.Background(), name)
+}
+
+func (f *filterFS) MkDirContext(ctx context.Context, name string) error {
+	if err := f.checkWrite(name, true); err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(writefs.MkDirContext(ctx, f.base, name))
+}
+
+func (f *filterFS) Remove(name string) error {
+	return f.RemoveContext(context.Background(), name)
+}
+
+func (f *filterFS) RemoveContext(ctx context.Context, name string) error {
+	if err := f.checkModify(ctx, name); err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(writefs.RemoveContext(ctx, f.base, name))
+}
+
+func (f *filterFS) Rename(oldPath, newPath string) error {
+	return f.RenameContext(context.Background(), oldPath, newPath)
+}
+
+func (f *filterFS) RenameContext(ctx context.Context, oldPath, newPath string) error {
+	if f.readOnly {
+		return errors.Wrapf(fs.ErrPermission, "'%s' is read-only", oldPath)
+	}
+	info, err := f.StatContext(ctx, oldPath)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if err := f.checkWrite(newPath, info.IsDir()); err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(writefs.RenameContext(ctx, f.base, oldPath, newPath))
+}
+
+func (f *filterFS) Chtimes(name string, atime, mtime time.Time) error {
+	if err := f.checkModify(context.Background(), name); err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(writefs.Chtimes(f.base, name, atime, mtime))
+}
+
+func (f *filterFS) Chmod(name string, mode fs.FileMode) error {
+	if err := f.checkModify(context.Background(), name); err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(writefs.Chmod(f.base, name, mode))
+}
+
+func (f *filterFS) Close() error {
+	return errors.WithStack(writefs.Close(f.base))
+}
+
+// dir is a folder with the visible entries only
+type dir struct {
+	info    fs.FileInfo
+	entries []fs.DirEntry
+	offset  int
+}
+
+func (d *dir) Stat() (fs.FileInfo, error) {
+	return d.info, nil
+}
+
+func (d *dir) Read([]byte) (int, error) {
+	return 0, errors.Wrapf(fs.ErrInvalid, "'%s' is a directory", d.info.Name())
+}
+
+func (d *dir) Close() error {
+	return nil
+}
+
+func (d *dir) ReadDir(n int) ([]fs.DirEntry, error) {
+	entries := d.entries[d.offset:]
+	if n > 0 {
+		if len(entries) == 0 {
+			return nil, io.EOF
+		}
+		entries = entries[:min(n, len(entries))]
+	}
+	d.offset += len(entries)
+	return entries, nil
+}
+
+var (
+	_ writefs.ReadWriteFS    = &filterFS{}
+	_ writefs.CreateAtomicFS = &filterFS{}
+	_ writefs.OpenFileFS     = &filterFS{}
+	_ writefs.MkDirFS        = &filterFS{}
+	_ writefs.RemoveFS       = &filterFS{}
+	_ writefs.RenameFS       = &filterFS{}
+	_ writefs.ChtimesFS      = &filterFS{}
+	_ writefs.ChmodFS        = &filterFS{}
+	_ writefs.CloseFS        = &filterFS{}
+	_ fs.ReadDirFS           = &filterFS{}
+	_ fs.ReadFileFS          = &filterFS{}
+	_ fs.StatFS              = &filterFS{}
+	_ fs.SubFS               = &filterFS{}
+	_ fs.ReadDirFile         = &dir{}
+	_ fmt.Stringer           = &filterFS{}
+
+	_ writefs.CreateContextFS       = &filterFS{}
+	_ writefs.CreateAtomicContextFS = &filterFS{}
+	_ writefs.OpenFileContextFS     = &filterFS{}
+	_ writefs.OpenContextFS         = &filterFS{}
+	_ writefs.StatContextFS         = &filterFS{}
+	_ writefs.ReadDirContextFS      = &filterFS{}
+	_ writefs.MkDirContextFS        = &filterFS{}
+	_ writefs.RemoveContextFS       = &filterFS{}
+	_ writefs.RenameContextFS       = &filterFS{}
+)
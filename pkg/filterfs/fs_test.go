@@ -0,0 +1,122 @@
+package filterfs
+
+import (
+	"emperror.dev/errors"
+	"github.com/je4/filesystem/v3/pkg/memfsrw"
+	"github.com/je4/filesystem/v3/pkg/writefs"
+	"github.com/rs/zerolog"
+	"io/fs"
+	"os"
+	"testing"
+	"testing/fstest"
+)
+
+func TestFilterFS(t *testing.T) {
+	logger := zerolog.New(os.Stderr).With().Timestamp().Logger()
+	memFS, err := memfsrw.NewFS("filter", &logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"data/a.txt", "data/b.tmp", "data/sub/c.txt", "private/d.txt", "e.txt"} {
+		if _, err := writefs.WriteFile(memFS, name, []byte(name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("hidden", func(t *testing.T) {
+		filterFS, err := NewFS(memFS, []string{"data/**"}, []string{"**/*.tmp"}, false, &logger)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := fstest.TestFS(filterFS, "data"); err != nil {
+			t.Fatal(err)
+		}
+		entries, err := fs.ReadDir(filterFS, "data")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != 2 || entries[0].Name() != "a.txt" || entries[1].Name() != "sub" {
+			t.Errorf("wrong entries in 'data': %v", entries)
+		}
+		for _, name := range []string{"data/b.tmp", "private", "private/d.txt", "e.txt"} {
+			if _, err := fs.Stat(filterFS, name); !errors.Is(err, fs.ErrNotExist) {
+				t.Errorf("stat '%s': expected fs.ErrNotExist, got %v", name, err)
+			}
+			if _, err := filterFS.Open(name); !errors.Is(err, fs.ErrNotExist) {
+				t.Errorf("open '%s': expected fs.ErrNotExist, got %v", name, err)
+			}
+		}
+		if _, err := writefs.Create(filterFS, "data/x.tmp"); !errors.Is(err, fs.ErrPermission) {
+			t.Errorf("expected fs.ErrPermission, got %v", err)
+		}
+		if err := writefs.Remove(filterFS, "data/b.tmp"); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("expected fs.ErrNotExist, got %v", err)
+		}
+		// the hidden temporary file of the base filesystem is not checked
+		fp, err := writefs.CreateAtomic(filterFS, "data/atomic.txt")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := fp.Write([]byte("atomic")); err != nil {
+			t.Fatal(err)
+		}
+		if err := fp.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if data, err := fs.ReadFile(filterFS, "data/atomic.txt"); err != nil || string(data) != "atomic" {
+			t.Errorf("wrong content '%s': %v", data, err)
+		}
+		if err := writefs.Remove(filterFS, "data/atomic.txt"); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("sub", func(t *testing.T) {
+		filterFS, err := NewFS(memFS, nil, []string{"data/sub"}, false, &logger)
+		if err != nil {
+			t.Fatal(err)
+		}
+		subFS, err := fs.Sub(filterFS, "data")
+		if err != nil {
+			t.Fatal(err)
+		}
+		entries, err := fs.ReadDir(subFS, ".")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != 2 {
+			t.Errorf("expected 2 entries, got %d", len(entries))
+		}
+		if _, err := fs.Stat(subFS, "sub/c.txt"); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("expected fs.ErrNotExist, got %v", err)
+		}
+	})
+
+	t.Run("readonly", func(t *testing.T) {
+		filterFS, err := NewFS(memFS, nil, nil, true, &logger)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if data, err := fs.ReadFile(filterFS, "e.txt"); err != nil || string(data) != "e.txt" {
+			t.Errorf("cannot read 'e.txt': %v", err)
+		}
+		if _, err := writefs.Create(filterFS, "f.txt"); !errors.Is(err, fs.ErrPermission) {
+			t.Errorf("create: expected fs.ErrPermission, got %v", err)
+		}
+		if err := writefs.MkDir(filterFS, "g"); !errors.Is(err, fs.ErrPermission) {
+			t.Errorf("mkdir: expected fs.ErrPermission, got %v", err)
+		}
+		if err := writefs.Rename(filterFS, "e.txt", "f.txt"); !errors.Is(err, fs.ErrPermission) {
+			t.Errorf("rename: expected fs.ErrPermission, got %v", err)
+		}
+		if err := writefs.Remove(filterFS, "e.txt"); !errors.Is(err, fs.ErrPermission) {
+			t.Errorf("remove: expected fs.ErrPermission, got %v", err)
+		}
+	})
+
+	t.Run("pattern", func(t *testing.T) {
+		if _, err := NewFS(memFS, []string{"data/["}, nil, false, &logger); err == nil {
+			t.Errorf("invalid pattern accepted")
+		}
+	})
+}
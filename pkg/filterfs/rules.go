@@ -0,0 +1,120 @@
+package filterfs
+
+import (
+	"emperror.dev/errors"
+	"path"
+	"strings"
+)
+
+// rules decides which entries are visible.
+// Patterns are matched against the full slash separated path with path.Match per element.
+// The element "**" matches any number of elements, e.g. "**/*.tmp" matches temporary files in all folders.
+type rules struct {
+	include [][]string
+	exclude [][]string
+}
+
+func newRules(include, exclude []string) (*rules, error) {
+	r := &rules{}
+	var err error
+	if r.include, err = splitPatterns(include); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if r.exclude, err = splitPatterns(exclude); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return r, nil
+}
+
+func splitPatterns(patterns []string) ([][]string, error) {
+	result := [][]string{}
+	for _, pattern := range patterns {
+		elems := strings.Split(strings.Trim(pattern, "/"), "/")
+		for _, elem := range elems {
+			if _, err := path.Match(elem, ""); err != nil {
+				return nil, errors.Wrapf(err, "invalid pattern '%s'", pattern)
+			}
+		}
+		result = append(result, elems)
+	}
+	return result, nil
+}
+
+// visible checks whether name is shown.
+// Entries are hidden if they or one of their parents match an exclude pattern.
+// If there are include patterns, entries must match one of them or be inside a matching folder.
+// Folders stay visible if an include pattern may match entries below them.
+func (r *rules) visible(name string, isDir bool) bool {
+	if name == "." {
+		return true
+	}
+	elems := strings.Split(name, "/")
+	for i := range elems {
+		if matchAny(r.exclude, elems[:i+1]) {
+			return false
+		}
+	}
+	if len(r.include) == 0 {
+		return true
+	}
+	for i := range elems {
+		if matchAny(r.include, elems[:i+1]) {
+			return true
+		}
+	}
+	if isDir {
+		for _, pattern := range r.include {
+			if matchPrefix(pattern, elems) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func matchAny(patterns [][]string, elems []string) bool {
+	for _, pattern := range patterns {
+		if match(pattern, elems) {
+			return true
+		}
+	}
+	return false
+}
+
+func match(pattern, elems []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(elems); i++ {
+				if match(pattern[1:], elems[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(elems) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], elems[0]); !ok {
+			return false
+		}
+		pattern, elems = pattern[1:], elems[1:]
+	}
+	return len(elems) == 0
+}
+
+// matchPrefix checks whether pattern may match entries below the folder elems
+func matchPrefix(pattern, elems []string) bool {
+	for len(elems) > 0 {
+		if len(pattern) == 0 {
+			return false
+		}
+		if pattern[0] == "**" {
+			return true
+		}
+		if ok, _ := path.Match(pattern[0], elems[0]); !ok {
+			return false
+		}
+		pattern, elems = pattern[1:], elems[1:]
+	}
+	return len(pattern) > 0
+}
@@ -0,0 +1,45 @@
+package memfsrw
+
+import (
+	"emperror.dev/errors"
+	"github.com/je4/filesystem/v3/pkg/writefs"
+	"github.com/je4/utils/v2/pkg/zLogger"
+	"io/fs"
+	"strings"
+	"sync"
+)
+
+// PathRegexStr matches the paths of memory filesystems: mem://<name>[/<folder>]
+var PathRegexStr = `^mem://`
+
+// NewCreateFSFunc creates a memory filesystem for mem://<name>.
+// All filesystems with the same name, including folders like mem://<name>/<folder>, share one store,
+// which lives as long as the returned function.
+func NewCreateFSFunc(logger zLogger.ZLogger) writefs.CreateFSFunc {
+	var lock sync.Mutex
+	stores := map[string]*memFSRW{}
+	return func(f *writefs.Factory, path string) (fs.FS, error) {
+		name, folder, _ := strings.Cut(strings.Trim(strings.TrimPrefix(path, "mem://"), "/"), "/")
+		if name == "" {
+			return nil, errors.Errorf("invalid memory filesystem path: %s", path)
+		}
+		lock.Lock()
+		baseFS, ok := stores[name]
+		if !ok {
+			var err error
+			if baseFS, err = NewFS(name, logger); err != nil {
+				lock.Unlock()
+				return nil, errors.Wrapf(err, "cannot create memory filesystem '%s'", name)
+			}
+			stores[name] = baseFS
+		}
+		lock.Unlock()
+		if folder == "" {
+			return baseFS, nil
+		}
+		if err := writefs.MkDirAll(baseFS, folder); err != nil {
+			return nil, errors.Wrapf(err, "cannot create folder '%s'", path)
+		}
+		return fs.Sub(baseFS, folder)
+	}
+}
@@ -0,0 +1,111 @@
+package memfsrw
+
+import (
+	"bytes"
+	"emperror.dev/errors"
+	"github.com/je4/filesystem/v3/pkg/writefs"
+	"io"
+	"io/fs"
+	"path"
+	"time"
+)
+
+// file is a snapshot of the content of a file at the time it was opened
+type file struct {
+	*bytes.Reader
+	info *fileInfo
+}
+
+func (f *file) Stat() (fs.FileInfo, error) {
+	return f.info, nil
+}
+
+func (f *file) Close() error {
+	return nil
+}
+
+// dir is a snapshot of the entries of a directory at the time it was opened
+type dir struct {
+	info    *fileInfo
+	entries []fs.DirEntry
+	offset  int
+}
+
+func (d *dir) Stat() (fs.FileInfo, error) {
+	return d.info, nil
+}
+
+func (d *dir) Read([]byte) (int, error) {
+	return 0, errors.Wrapf(fs.ErrInvalid, "'%s' is a directory", d.info.name)
+}
+
+func (d *dir) Close() error {
+	return nil
+}
+
+func (d *dir) ReadDir(n int) ([]fs.DirEntry, error) {
+	entries := d.entries[d.offset:]
+	if n > 0 {
+		if len(entries) == 0 {
+			return nil, io.EOF
+		}
+		entries = entries[:min(n, len(entries))]
+	}
+	d.offset += len(entries)
+	return entries, nil
+}
+
+// fileWrite buffers the content, which is stored in the filesystem on Close
+type fileWrite struct {
+	bytes.Buffer
+	s       *store
+	name    string
+	created *node
+	closed  bool
+}
+
+func (fw *fileWrite) Close() error {
+	if fw.closed {
+		return errors.Wrapf(fs.ErrClosed, "cannot close '%s'", fw.name)
+	}
+	fw.closed = true
+	fw.s.lock.Lock()
+	defer fw.s.lock.Unlock()
+	n, ok := fw.s.nodes[fw.name]
+	if !ok {
+		// parent folder has been removed in the meantime
+		if err := fw.s.mkDirAll(path.Dir(fw.name)); err != nil {
+			return errors.Wrapf(err, "cannot create folder of '%s'", fw.name)
+		}
+		n = &node{mode: 0644}
+		fw.s.nodes[fw.name] = n
+	}
+	if n.mode.IsDir() {
+		return errors.Errorf("cannot write '%s': is a directory", fw.name)
+	}
+	n.data = bytes.Clone(fw.Bytes())
+	n.modTime = time.Now()
+	return nil
+}
+
+// Abort discards the content. Files created by Create are removed.
+func (fw *fileWrite) Abort() error {
+	if fw.closed {
+		return errors.Wrapf(fs.ErrClosed, "cannot abort '%s'", fw.name)
+	}
+	fw.closed = true
+	fw.s.lock.Lock()
+	defer fw.s.lock.Unlock()
+	if n, ok := fw.s.nodes[fw.name]; ok && n == fw.created {
+		delete(fw.s.nodes, fw.name)
+	}
+	return nil
+}
+
+var (
+	_ fs.File                = &file{}
+	_ io.ReaderAt            = &file{}
+	_ io.Seeker              = &file{}
+	_ fs.ReadDirFile         = &dir{}
+	_ writefs.AbortFileWrite = &fileWrite{}
+)
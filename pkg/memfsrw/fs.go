@@ -0,0 +1,302 @@
+// Package memfsrw provides a thread-safe in-memory filesystem with directories and modification times
+package memfsrw
+
+import (
+	"bytes"
+	"emperror.dev/errors"
+	"github.com/je4/filesystem/v3/pkg/writefs"
+	"github.com/je4/utils/v2/pkg/zLogger"
+	"golang.org/x/exp/slices"
+	"io/fs"
+	"path"
+	"strings"
+	"time"
+)
+
+// NewFS creates an empty memory filesystem. name is used by String only.
+func NewFS(name string, logger zLogger.ZLogger) (*memFSRW, error) {
+	_logger := logger.With().Str("class", "memFSRW").Logger()
+	logger = &_logger
+	return &memFSRW{
+		store:  newStore(),
+		name:   name,
+		dir:    ".",
+		logger: logger,
+	}, nil
+}
+
+type memFSRW struct {
+	store  *store
+	name   string
+	dir    string
+	logger zLogger.ZLogger
+}
+
+// fullpath returns the key of name in the store
+func (m *memFSRW) fullpath(name string) (string, error) {
+	if !fs.ValidPath(name) {
+		return "", errors.Wrapf(fs.ErrInvalid, "invalid path '%s'", name)
+	}
+	return path.Join(m.dir, name), nil
+}
+
+func (m *memFSRW) String() string {
+	if m.dir == "." {
+		return "memFSRW(" + m.name + ")"
+	}
+	return "memFSRW(" + m.name + "/" + m.dir + ")"
+}
+
+// Sub returns the filesystem of dir, which shares the content with m
+func (m *memFSRW) Sub(dir string) (fs.FS, error) {
+	full, err := m.fullpath(dir)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return &memFSRW{
+		store:  m.store,
+		name:   m.name,
+		dir:    full,
+		logger: m.logger,
+	}, nil
+}
+
+func (m *memFSRW) Open(name string) (fs.File, error) {
+	full, err := m.fullpath(name)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	m.store.lock.RLock()
+	defer m.store.lock.RUnlock()
+	n, ok := m.store.nodes[full]
+	if !ok {
+		return nil, errors.Wrapf(fs.ErrNotExist, "cannot open '%s'", name)
+	}
+	if n.mode.IsDir() {
+		return &dir{
+			info:    newFileInfo(full, n),
+			entries: m.readDir(full),
+		}, nil
+	}
+	return &file{
+		Reader: bytes.NewReader(n.data),
+		info:   newFileInfo(full, n),
+	}, nil
+}
+
+func (m *memFSRW) ReadFile(name string) ([]byte, error) {
+	full, err := m.fullpath(name)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	m.store.lock.RLock()
+	defer m.store.lock.RUnlock()
+	n, ok := m.store.nodes[full]
+	if !ok {
+		return nil, errors.Wrapf(fs.ErrNotExist, "cannot read '%s'", name)
+	}
+	if n.mode.IsDir() {
+		return nil, errors.Wrapf(fs.ErrInvalid, "'%s' is a directory", name)
+	}
+	return bytes.Clone(n.data), nil
+}
+
+func (m *memFSRW) Stat(name string) (fs.FileInfo, error) {
+	full, err := m.fullpath(name)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	m.store.lock.RLock()
+	defer m.store.lock.RUnlock()
+	n, ok := m.store.nodes[full]
+	if !ok {
+		return nil, errors.Wrapf(fs.ErrNotExist, "cannot stat '%s'", name)
+	}
+	return newFileInfo(full, n), nil
+}
+
+func (m *memFSRW) ReadDir(name string) ([]fs.DirEntry, error) {
+	full, err := m.fullpath(name)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	m.store.lock.RLock()
+	defer m.store.lock.RUnlock()
+	n, ok := m.store.nodes[full]
+	if !ok {
+		return nil, errors.Wrapf(fs.ErrNotExist, "cannot read directory '%s'", name)
+	}
+	if !n.mode.IsDir() {
+		return nil, errors.Errorf("cannot read directory '%s': not a directory", name)
+	}
+	return m.readDir(full), nil
+}
+
+// readDir returns the sorted entries of the directory full. The caller must hold the lock.
+func (m *memFSRW) readDir(full string) []fs.DirEntry {
+	result := []fs.DirEntry{}
+	for _, name := range m.store.children(full) {
+		if path.Dir(name) == full {
+			result = append(result, writefs.NewDirEntry(newFileInfo(name, m.store.nodes[name])))
+		}
+	}
+	slices.SortFunc(result, func(a, b fs.DirEntry) int {
+		return strings.Compare(a.Name(), b.Name())
+	})
+	return result
+}
+
+// Create creates or replaces name. Missing folders are created.
+// The content is visible after Close.
+func (m *memFSRW) Create(name string) (writefs.FileWrite, error) {
+	full, err := m.fullpath(name)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if full == "." {
+		return nil, errors.Wrapf(fs.ErrInvalid, "cannot create '%s'", name)
+	}
+	m.store.lock.Lock()
+	defer m.store.lock.Unlock()
+	if err := m.store.mkDirAll(path.Dir(full)); err != nil {
+		return nil, errors.Wrapf(err, "cannot create folder of '%s'", name)
+	}
+	fw := &fileWrite{
+		s:    m.store,
+		name: full,
+	}
+	if n, ok := m.store.nodes[full]; ok {
+		if n.mode.IsDir() {
+			return nil, errors.Errorf("cannot create '%s': is a directory", name)
+		}
+	} else {
+		fw.created = &node{data: []byte{}, mode: 0644, modTime: time.Now()}
+		m.store.nodes[full] = fw.created
+	}
+	return fw, nil
+}
+
+// MkDir creates the folder name. The parent folder must exist.
+func (m *memFSRW) MkDir(name string) error {
+	full, err := m.fullpath(name)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	m.store.lock.Lock()
+	defer m.store.lock.Unlock()
+	if _, ok := m.store.nodes[full]; ok {
+		return errors.Wrapf(fs.ErrExist, "cannot create folder '%s'", name)
+	}
+	parent, ok := m.store.nodes[path.Dir(full)]
+	if !ok {
+		return errors.Wrapf(fs.ErrNotExist, "cannot create folder '%s'", name)
+	}
+	if !parent.mode.IsDir() {
+		return errors.Errorf("cannot create folder '%s': parent is not a directory", name)
+	}
+	m.store.nodes[full] = &node{mode: fs.ModeDir | 0755, modTime: time.Now()}
+	return nil
+}
+
+func (m *memFSRW) MkDirAll(name string) error {
+	full, err := m.fullpath(name)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	m.store.lock.Lock()
+	defer m.store.lock.Unlock()
+	return errors.Wrapf(m.store.mkDirAll(full), "cannot create folder '%s'", name)
+}
+
+// Rename moves a file or a folder with its content. An existing file newPath is replaced.
+func (m *memFSRW) Rename(oldPath, newPath string) error {
+	oldFull, err := m.fullpath(oldPath)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	newFull, err := m.fullpath(newPath)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if oldFull == "." || newFull == "." {
+		return errors.Wrapf(fs.ErrInvalid, "cannot rename '%s' to '%s'", oldPath, newPath)
+	}
+	m.store.lock.Lock()
+	defer m.store.lock.Unlock()
+	n, ok := m.store.nodes[oldFull]
+	if !ok {
+		return errors.Wrapf(fs.ErrNotExist, "cannot rename '%s'", oldPath)
+	}
+	if oldFull == newFull {
+		return nil
+	}
+	if parent, ok := m.store.nodes[path.Dir(newFull)]; !ok || !parent.mode.IsDir() {
+		return errors.Wrapf(fs.ErrNotExist, "cannot rename '%s' to '%s': missing folder", oldPath, newPath)
+	}
+	if dest, ok := m.store.nodes[newFull]; ok && (dest.mode.IsDir() || n.mode.IsDir()) {
+		return errors.Wrapf(fs.ErrExist, "cannot rename '%s' to '%s'", oldPath, newPath)
+	}
+	if n.mode.IsDir() {
+		if strings.HasPrefix(newFull, oldFull+"/") {
+			return errors.Wrapf(fs.ErrInvalid, "cannot move '%s' into itself", oldPath)
+		}
+		for _, child := range m.store.children(oldFull) {
+			m.store.nodes[newFull+strings.TrimPrefix(child, oldFull)] = m.store.nodes[child]
+			delete(m.store.nodes, child)
+		}
+	}
+	m.store.nodes[newFull] = n
+	delete(m.store.nodes, oldFull)
+	return nil
+}
+
+// Remove removes a file or an empty folder
+func (m *memFSRW) Remove(name string) error {
+	full, err := m.fullpath(name)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if full == "." {
+		return errors.Wrapf(fs.ErrInvalid, "cannot remove '%s'", name)
+	}
+	m.store.lock.Lock()
+	defer m.store.lock.Unlock()
+	if _, ok := m.store.nodes[full]; !ok {
+		return errors.Wrapf(fs.ErrNotExist, "cannot remove '%s'", name)
+	}
+	if len(m.store.children(full)) > 0 {
+		return errors.Errorf("cannot remove '%s': directory not empty", name)
+	}
+	delete(m.store.nodes, full)
+	return nil
+}
+
+// RemoveAll removes name with all its content. A missing name is not an error.
+func (m *memFSRW) RemoveAll(name string) error {
+	full, err := m.fullpath(name)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	m.store.lock.Lock()
+	defer m.store.lock.Unlock()
+	for _, child := range m.store.children(full) {
+		delete(m.store.nodes, child)
+	}
+	if full != "." {
+		delete(m.store.nodes, full)
+	}
+	return nil
+}
+
+var (
+	_ writefs.ReadWriteFS = &memFSRW{}
+	_ writefs.MkDirFS     = &memFSRW{}
+	_ writefs.MkDirAllFS  = &memFSRW{}
+	_ writefs.RenameFS    = &memFSRW{}
+	_ writefs.RemoveFS    = &memFSRW{}
+	_ writefs.RemoveAllFS = &memFSRW{}
+	_ fs.ReadDirFS        = &memFSRW{}
+	_ fs.ReadFileFS       = &memFSRW{}
+	_ fs.StatFS           = &memFSRW{}
+	_ fs.SubFS            = &memFSRW{}
+)
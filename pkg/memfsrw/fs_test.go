@@ -0,0 +1,97 @@
+package memfsrw
+
+import (
+	"emperror.dev/errors"
+	"fmt"
+	"github.com/je4/filesystem/v3/pkg/writefs"
+	"github.com/je4/filesystem/v3/pkg/writefs/writefstest"
+	"github.com/rs/zerolog"
+	"io/fs"
+	"os"
+	"testing"
+	"testing/fstest"
+)
+
+func TestMemFSRW(t *testing.T) {
+	logger := zerolog.New(os.Stderr).With().Timestamp().Logger()
+	memFS, err := NewFS("test", &logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writefstest.TestReadWriteFS(t, memFS, nil)
+
+	t.Run("folders", func(t *testing.T) {
+		if err := writefs.MkDir(memFS, "missing/folder"); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("mkdir without parent: expected fs.ErrNotExist, got %v", err)
+		}
+		if err := writefs.MkDir(memFS, "folder"); err != nil {
+			t.Fatal(err)
+		}
+		if err := writefs.MkDir(memFS, "folder"); !errors.Is(err, fs.ErrExist) {
+			t.Errorf("mkdir of existing folder: expected fs.ErrExist, got %v", err)
+		}
+		if _, err := writefs.WriteFile(memFS, "folder/file.txt", []byte("content")); err != nil {
+			t.Fatal(err)
+		}
+		if err := writefs.Remove(memFS, "folder"); err == nil {
+			t.Errorf("remove of non-empty folder succeeded")
+		}
+		if err := writefs.Rename(memFS, "folder", "moved"); err != nil {
+			t.Fatal(err)
+		}
+		if err := fstest.TestFS(memFS, "moved/file.txt"); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("abort", func(t *testing.T) {
+		fp, err := writefs.Create(memFS, "aborted.txt")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := fp.Write([]byte("aborted")); err != nil {
+			t.Fatal(err)
+		}
+		if err := writefs.Abort(fp); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := fs.Stat(memFS, "aborted.txt"); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("aborted file: expected fs.ErrNotExist, got %v", err)
+		}
+	})
+
+	for _, cached := range []bool{false, true} {
+		t.Run(fmt.Sprintf("factory cached=%v", cached), func(t *testing.T) {
+			factory, err := writefs.NewFactory()
+			if err != nil {
+				t.Fatal(err)
+			}
+			register := factory.Register
+			if cached {
+				register = factory.RegisterCached
+			}
+			if err := register(NewCreateFSFunc(&logger), PathRegexStr, writefs.MediumFS); err != nil {
+				t.Fatal(err)
+			}
+			defer factory.Close()
+			subFS, err := factory.Get("mem://factory/sub")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := writefs.WriteFile(subFS, "file.txt", []byte("content")); err != nil {
+				t.Fatal(err)
+			}
+			baseFS, err := factory.Get("mem://factory")
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, err := fs.ReadFile(baseFS, "sub/file.txt")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != "content" {
+				t.Errorf("wrong content '%s'", data)
+			}
+		})
+	}
+}
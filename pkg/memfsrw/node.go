@@ -0,0 +1,104 @@
+package memfsrw
+
+import (
+	"io/fs"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// node is a file or a directory of the store
+type node struct {
+	data    []byte
+	mode    fs.FileMode
+	modTime time.Time
+}
+
+// store contains all nodes of a memory filesystem and its sub filesystems.
+// The nodes are indexed by their cleaned path, the root is ".".
+type store struct {
+	lock  sync.RWMutex
+	nodes map[string]*node
+}
+
+func newStore() *store {
+	return &store{
+		nodes: map[string]*node{
+			".": {mode: fs.ModeDir | 0755, modTime: time.Now()},
+		},
+	}
+}
+
+// mkDirAll creates name and all missing parents. The caller must hold the write lock.
+func (s *store) mkDirAll(name string) error {
+	if n, ok := s.nodes[name]; ok {
+		if !n.mode.IsDir() {
+			return fs.ErrExist
+		}
+		return nil
+	}
+	if err := s.mkDirAll(path.Dir(name)); err != nil {
+		return err
+	}
+	s.nodes[name] = &node{mode: fs.ModeDir | 0755, modTime: time.Now()}
+	return nil
+}
+
+// children returns the paths of all nodes below dir. The caller must hold the lock.
+func (s *store) children(dir string) []string {
+	var prefix string
+	if dir != "." {
+		prefix = dir + "/"
+	}
+	result := []string{}
+	for name := range s.nodes {
+		if name != "." && strings.HasPrefix(name, prefix) {
+			result = append(result, name)
+		}
+	}
+	return result
+}
+
+// fileInfo is a snapshot of the attributes of a node
+type fileInfo struct {
+	name    string
+	size    int64
+	mode    fs.FileMode
+	modTime time.Time
+}
+
+func newFileInfo(name string, n *node) *fileInfo {
+	return &fileInfo{
+		name:    path.Base(name),
+		size:    int64(len(n.data)),
+		mode:    n.mode,
+		modTime: n.modTime,
+	}
+}
+
+func (fi *fileInfo) Name() string {
+	return fi.name
+}
+
+func (fi *fileInfo) Size() int64 {
+	return fi.size
+}
+
+func (fi *fileInfo) Mode() fs.FileMode {
+	return fi.mode
+}
+
+func (fi *fileInfo) ModTime() time.Time {
+	return fi.modTime
+}
+
+func (fi *fileInfo) IsDir() bool {
+	return fi.mode.IsDir()
+}
+
+func (fi *fileInfo) Sys() any {
+	return nil
+}
+
+var _ fs.FileInfo = (*fileInfo)(nil)
@@ -0,0 +1,96 @@
+package metricsfs
+
+import (
+	"emperror.dev/errors"
+	"github.com/je4/filesystem/v3/pkg/writefs"
+	"io"
+	"io/fs"
+	"time"
+)
+
+// newFile wraps fp and keeps io.ReaderAt, io.Seeker and fs.ReadDirFile if fp implements them
+func (m *metricsFS) newFile(fp fs.File) fs.File {
+	f := &file{File: fp, m: m}
+	_, isReaderAt := fp.(io.ReaderAt)
+	_, isSeeker := fp.(io.Seeker)
+	if isReaderAt && isSeeker {
+		return &readSeekFile{file: f}
+	}
+	if _, ok := fp.(fs.ReadDirFile); ok {
+		return &dirFile{file: f}
+	}
+	return f
+}
+
+// file records every Read
+type file struct {
+	fs.File
+	m *metricsFS
+}
+
+func (f *file) Read(p []byte) (int, error) {
+	start := time.Now()
+	n, err := f.File.Read(p)
+	f.m.observe(OpRead, start, err)
+	f.m.addBytes(OpRead, n)
+	return n, err
+}
+
+type dirFile struct {
+	*file
+}
+
+func (d *dirFile) ReadDir(n int) ([]fs.DirEntry, error) {
+	return d.File.(fs.ReadDirFile).ReadDir(n)
+}
+
+// readSeekFile is a file with random access, which records ReadAt like Read
+type readSeekFile struct {
+	*file
+}
+
+func (f *readSeekFile) ReadAt(p []byte, off int64) (int, error) {
+	start := time.Now()
+	n, err := f.File.(io.ReaderAt).ReadAt(p, off)
+	f.m.observe(OpRead, start, err)
+	f.m.addBytes(OpRead, n)
+	return n, err
+}
+
+func (f *readSeekFile) Seek(offset int64, whence int) (int64, error) {
+	return f.File.(io.Seeker).Seek(offset, whence)
+}
+
+func (f *readSeekFile) ReadDir(n int) ([]fs.DirEntry, error) {
+	dir, ok := f.File.(fs.ReadDirFile)
+	if !ok {
+		return nil, errors.Wrap(writefs.ErrNotImplemented, "ReadDir")
+	}
+	return dir.ReadDir(n)
+}
+
+// fileWrite records every Write
+type fileWrite struct {
+	writefs.FileWrite
+	m *metricsFS
+}
+
+func (fw *fileWrite) Write(p []byte) (int, error) {
+	start := time.Now()
+	n, err := fw.FileWrite.Write(p)
+	fw.m.observe(OpWrite, start, err)
+	fw.m.addBytes(OpWrite, n)
+	return n, err
+}
+
+func (fw *fileWrite) Abort() error {
+	return writefs.Abort(fw.FileWrite)
+}
+
+var (
+	_ fs.ReadDirFile         = &dirFile{}
+	_ fs.ReadDirFile         = &readSeekFile{}
+	_ io.ReaderAt            = &readSeekFile{}
+	_ io.Seeker              = &readSeekFile{}
+	_ writefs.AbortFileWrite = &fileWrite{}
+)
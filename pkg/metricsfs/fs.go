@@ -0,0 +1,278 @@
+// Package metricsfs records prometheus metrics for the operations of a filesystem
+package metricsfs
+
+import (
+	"context"
+	"emperror.dev/errors"
+	"fmt"
+	"github.com/je4/filesystem/v3/pkg/writefs"
+	"github.com/je4/utils/v2/pkg/zLogger"
+	"io"
+	"io/fs"
+	"os"
+	"time"
+)
+
+// NewFS wraps base and records its operations in metrics, labeled with name and backend.
+// If base implements CacheStatsFS, the cache statistics are reported until the filesystem is closed.
+func NewFS(base fs.FS, name, backend string, metrics *Metrics, logger zLogger.ZLogger) (*metricsFS, error) {
+	_logger := logger.With().Str("class", "metricsFS").Logger()
+	logger = &_logger
+	m := &metricsFS{
+		base:    base,
+		name:    name,
+		backend: backend,
+		metrics: metrics,
+		logger:  logger,
+	}
+	if cache, ok := base.(CacheStatsFS); ok {
+		metrics.addCache(name, backend, cache)
+	}
+	return m, nil
+}
+
+type metricsFS struct {
+	base    fs.FS
+	name    string
+	backend string
+	metrics *Metrics
+	logger  zLogger.ZLogger
+}
+
+// observe records an operation, which started at start
+func (m *metricsFS) observe(op Op, start time.Time, err error) {
+	m.metrics.ops.WithLabelValues(m.name, m.backend, string(op)).Inc()
+	m.metrics.duration.WithLabelValues(m.name, m.backend, string(op)).Observe(time.Since(start).Seconds())
+	if err != nil && !errors.Is(err, io.EOF) {
+		m.metrics.errors.WithLabelValues(m.name, m.backend, string(op)).Inc()
+	}
+}
+
+func (m *metricsFS) addBytes(op Op, n int) {
+	if n > 0 {
+		m.metrics.bytes.WithLabelValues(m.name, m.backend, string(op)).Add(float64(n))
+	}
+}
+
+func (m *metricsFS) String() string {
+	return fmt.Sprintf("metricsFS(%v)", m.base)
+}
+
+func (m *metricsFS) Sub(dir string) (fs.FS, error) {
+	return writefs.NewSubFS(m, dir), nil
+}
+
+func (m *metricsFS) Open(name string) (fs.File, error) {
+	return m.OpenContext(context.Background(), name)
+}
+
+func (m *metricsFS) OpenContext(ctx context.Context, name string) (fs.File, error) {
+	start := time.Now()
+	fp, err := writefs.OpenContext(ctx, m.base, name)
+	m.observe(OpOpen, start, err)
+	if err != nil {
+		return nil, err
+	}
+	return m.newFile(fp), nil
+}
+
+func (m *metricsFS) ReadFile(name string) ([]byte, error) {
+	start := time.Now()
+	data, err := fs.ReadFile(m.base, name)
+	m.observe(OpRead, start, err)
+	m.addBytes(OpRead, len(data))
+	return data, err
+}
+
+func (m *metricsFS) Stat(name string) (fs.FileInfo, error) {
+	return m.StatContext(context.Background(), name)
+}
+
+func (m *metricsFS) StatContext(ctx context.Context, name string) (fs.FileInfo, error) {
+	start := time.Now()
+	info, err := writefs.StatContext(ctx, m.base, name)
+	m.observe(OpStat, start, err)
+	return info, err
+}
+
+func (m *metricsFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	return m.ReadDirContext(context.Background(), name)
+}
+
+func (m *metricsFS) ReadDirContext(ctx context.Context, name string) ([]fs.DirEntry, error) {
+	start := time.Now()
+	entries, err := writefs.ReadDirContext(ctx, m.base, name)
+	m.observe(OpReadDir, start, err)
+	return entries, err
+}
+
+// newFileWrite records a create operation and wraps fp to record the writes
+func (m *metricsFS) newFileWrite(start time.Time, fp writefs.FileWrite, err error) (writefs.FileWrite, error) {
+	m.observe(OpCreate, start, err)
+	if err != nil {
+		return nil, err
+	}
+	return &fileWrite{FileWrite: fp, m: m}, nil
+}
+
+func (m *metricsFS) Create(name string) (writefs.FileWrite, error) {
+	return m.CreateContext(context.Background(), name)
+}
+
+func (m *metricsFS) CreateContext(ctx context.Context, name string) (writefs.FileWrite, error) {
+	start := time.Now()
+	fp, err := writefs.CreateContext(ctx, m.base, name)
+	return m.newFileWrite(start, fp, err)
+}
+
+func (m *metricsFS) CreateAtomic(name string) (writefs.FileWrite, error) {
+	return m.CreateAtomicContext(context.Background(), name)
+}
+
+func (m *metricsFS) CreateAtomicContext(ctx context.Context, name string) (writefs.FileWrite, error) {
+	start := time.Now()
+	fp, err := writefs.CreateAtomicContext(ctx, m.base, name)
+	return m.newFileWrite(start, fp, err)
+}
+
+func (m *metricsFS) OpenFile(name string, flag int, perm fs.FileMode) (writefs.FileWrite, error) {
+	return m.OpenFileContext(context.Background(), name, flag, perm)
+}
+
+// OpenFileContext records files opened for writing as create operation
+func (m *metricsFS) OpenFileContext(ctx context.Context, name string, flag int, perm fs.FileMode) (writefs.FileWrite, error) {
+	start := time.Now()
+	fp, err := writefs.OpenFileContext(ctx, m.base, name, flag, perm)
+	if flag&(os.O_WRONLY|os.O_RDWR) == 0 {
+		return fp, err
+	}
+	return m.newFileWrite(start, fp, err)
+}
+
+func (m *metricsFS) Remove(name string) error {
+	return m.RemoveContext(context.Background(), name)
+}
+
+func (m *metricsFS) RemoveContext(ctx context.Context, name string) error {
+	start := time.Now()
+	err := writefs.RemoveContext(ctx, m.base, name)
+	m.observe(OpRemove, start, err)
+	return err
+}
+
+func (m *metricsFS) Rename(oldPath, newPath string) error {
+	return m.RenameContext(context.Background(), oldPath, newPath)
+}
+
+func (m *metricsFS) RenameContext(ctx context.Context, oldPath, newPath string) error {
+	start := time.Now()
+	err := writefs.RenameContext(ctx, m.base, oldPath, newPath)
+	m.observe(OpRename, start, err)
+	return err
+}
+
+// the following operations are passed through without metrics
+
+func (m *metricsFS) CreateWriterAt(name string) (writefs.FileWriterAt, error) {
+	return writefs.CreateWriterAt(m.base, name)
+}
+
+func (m *metricsFS) MkDir(name string) error {
+	return writefs.MkDir(m.base, name)
+}
+
+func (m *metricsFS) MkDirContext(ctx context.Context, name string) error {
+	return writefs.MkDirContext(ctx, m.base, name)
+}
+
+func (m *metricsFS) MkDirAll(name string) error {
+	return writefs.MkDirAll(m.base, name)
+}
+
+func (m *metricsFS) MkDirAllContext(ctx context.Context, name string) error {
+	return writefs.MkDirAllContext(ctx, m.base, name)
+}
+
+func (m *metricsFS) RemoveAll(name string) error {
+	return writefs.RemoveAll(m.base, name)
+}
+
+func (m *metricsFS) RemoveAllContext(ctx context.Context, name string) error {
+	return writefs.RemoveAllContext(ctx, m.base, name)
+}
+
+func (m *metricsFS) CopyFile(src, dst string) error {
+	cfs, ok := m.base.(writefs.CopyFileFS)
+	if !ok {
+		return errors.Wrap(writefs.ErrNotImplemented, "CopyFile")
+	}
+	return cfs.CopyFile(src, dst)
+}
+
+func (m *metricsFS) Chtimes(name string, atime, mtime time.Time) error {
+	return writefs.Chtimes(m.base, name, atime, mtime)
+}
+
+func (m *metricsFS) Chmod(name string, mode fs.FileMode) error {
+	return writefs.Chmod(m.base, name, mode)
+}
+
+func (m *metricsFS) Symlink(oldname, newname string) error {
+	return writefs.Symlink(m.base, oldname, newname)
+}
+
+func (m *metricsFS) ReadLink(name string) (string, error) {
+	return writefs.ReadLink(m.base, name)
+}
+
+func (m *metricsFS) Lstat(name string) (fs.FileInfo, error) {
+	return writefs.Lstat(m.base, name)
+}
+
+func (m *metricsFS) Fullpath(name string) (string, error) {
+	return writefs.Fullpath(m.base, name)
+}
+
+// Close closes the base filesystem and stops reporting its cache statistics
+func (m *metricsFS) Close() error {
+	if cache, ok := m.base.(CacheStatsFS); ok {
+		m.metrics.removeCache(m.name, m.backend, cache)
+	}
+	return writefs.Close(m.base)
+}
+
+var (
+	_ writefs.ReadWriteFS           = &metricsFS{}
+	_ writefs.CreateContextFS       = &metricsFS{}
+	_ writefs.CreateAtomicFS        = &metricsFS{}
+	_ writefs.CreateAtomicContextFS = &metricsFS{}
+	_ writefs.OpenFileFS            = &metricsFS{}
+	_ writefs.OpenFileContextFS     = &metricsFS{}
+	_ writefs.OpenContextFS         = &metricsFS{}
+	_ writefs.StatContextFS         = &metricsFS{}
+	_ writefs.ReadDirContextFS      = &metricsFS{}
+	_ writefs.CreateWriterAtFS      = &metricsFS{}
+	_ writefs.MkDirFS               = &metricsFS{}
+	_ writefs.MkDirAllFS            = &metricsFS{}
+	_ writefs.RemoveFS              = &metricsFS{}
+	_ writefs.RemoveContextFS       = &metricsFS{}
+	_ writefs.RemoveAllFS           = &metricsFS{}
+	_ writefs.MkDirContextFS        = &metricsFS{}
+	_ writefs.MkDirAllContextFS     = &metricsFS{}
+	_ writefs.RemoveAllContextFS    = &metricsFS{}
+	_ writefs.RenameFS              = &metricsFS{}
+	_ writefs.RenameContextFS       = &metricsFS{}
+	_ writefs.CopyFileFS            = &metricsFS{}
+	_ writefs.ChtimesFS             = &metricsFS{}
+	_ writefs.ChmodFS               = &metricsFS{}
+	_ writefs.SymlinkFS             = &metricsFS{}
+	_ writefs.ReadLinkFS            = &metricsFS{}
+	_ writefs.LstatFS               = &metricsFS{}
+	_ writefs.FullpathFS            = &metricsFS{}
+	_ writefs.CloseFS               = &metricsFS{}
+	_ fs.ReadDirFS                  = &metricsFS{}
+	_ fs.ReadFileFS                 = &metricsFS{}
+	_ fs.StatFS                     = &metricsFS{}
+	_ fs.SubFS                      = &metricsFS{}
+	_ fmt.Stringer                  = &metricsFS{}
+)
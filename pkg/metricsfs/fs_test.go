@@ -0,0 +1,99 @@
+package metricsfs
+
+import (
+	"emperror.dev/errors"
+	"github.com/je4/filesystem/v3/pkg/memfsrw"
+	"github.com/je4/filesystem/v3/pkg/writefs"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rs/zerolog"
+	"io/fs"
+	"os"
+	"strings"
+	"testing"
+)
+
+type cacheFS struct {
+	writefs.ReadWriteFS
+}
+
+func (c *cacheFS) CacheStats() (hits, misses, evictions uint64) {
+	return 5, 2, 1
+}
+
+func (c *cacheFS) Rename(oldPath, newPath string) error {
+	return writefs.Rename(c.ReadWriteFS, oldPath, newPath)
+}
+
+func (c *cacheFS) Remove(name string) error {
+	return writefs.Remove(c.ReadWriteFS, name)
+}
+
+func TestMetricsFS(t *testing.T) {
+	logger := zerolog.New(os.Stderr).With().Timestamp().Logger()
+	memFS, err := memfsrw.NewFS("metrics", &logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	registry := prometheus.NewRegistry()
+	metrics, err := NewMetrics(registry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	metricsFS, err := NewFS(&cacheFS{ReadWriteFS: memFS}, "test", "mem", metrics, &logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := writefs.WriteFile(metricsFS, "a.txt", []byte("0123456789")); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := fs.ReadFile(metricsFS, "a.txt"); err != nil || string(data) != "0123456789" {
+		t.Fatalf("cannot read 'a.txt': %v", err)
+	}
+	if _, err := fs.Stat(metricsFS, "missing.txt"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected fs.ErrNotExist, got %v", err)
+	}
+	if err := writefs.Rename(metricsFS, "a.txt", "b.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if err := writefs.Remove(metricsFS, "b.txt"); err != nil {
+		t.Fatal(err)
+	}
+
+	value := func(vec *prometheus.CounterVec, op Op) float64 {
+		return testutil.ToFloat64(vec.WithLabelValues("test", "mem", string(op)))
+	}
+	for _, op := range []Op{OpCreate, OpWrite, OpStat, OpRename, OpRemove} {
+		if v := value(metrics.ops, op); v != 1 {
+			t.Errorf("expected 1 %s operation, got %v", op, v)
+		}
+	}
+	if v := value(metrics.errors, OpStat); v != 1 {
+		t.Errorf("expected 1 stat error, got %v", v)
+	}
+	if v := value(metrics.bytes, OpWrite); v != 10 {
+		t.Errorf("expected 10 bytes written, got %v", v)
+	}
+	if v := value(metrics.bytes, OpRead); v != 10 {
+		t.Errorf("expected 10 bytes read, got %v", v)
+	}
+
+	expected := `
+# HELP filesystem_cache_evictions_total Number of cache evictions.
+# TYPE filesystem_cache_evictions_total counter
+filesystem_cache_evictions_total{backend="mem",fs="test"} 1
+# HELP filesystem_cache_hits_total Number of cache hits.
+# TYPE filesystem_cache_hits_total counter
+filesystem_cache_hits_total{backend="mem",fs="test"} 5
+`
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(expected), "filesystem_cache_hits_total", "filesystem_cache_evictions_total"); err != nil {
+		t.Error(err)
+	}
+	if err := metricsFS.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if count, err := testutil.GatherAndCount(registry, "filesystem_cache_hits_total"); err != nil || count != 0 {
+		t.Errorf("cache statistics reported after Close: %d, %v", count, err)
+	}
+}
@@ -0,0 +1,135 @@
+package metricsfs
+
+import (
+	"emperror.dev/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"sync"
+)
+
+type Op string
+
+const (
+	OpOpen    Op = "open"
+	OpRead    Op = "read"
+	OpCreate  Op = "create"
+	OpWrite   Op = "write"
+	OpStat    Op = "stat"
+	OpReadDir Op = "readdir"
+	OpRemove  Op = "remove"
+	OpRename  Op = "rename"
+)
+
+// CacheStatsFS is a filesystem with a cache, e.g. zipasfolder
+type CacheStatsFS interface {
+	CacheStats() (hits, misses, evictions uint64)
+}
+
+type cacheKey struct {
+	fs      string
+	backend string
+}
+
+// Metrics contains the collectors of all filesystems. The series are labeled by filesystem and backend.
+type Metrics struct {
+	ops      *prometheus.CounterVec
+	errors   *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+	bytes    *prometheus.CounterVec
+
+	cacheHits      *prometheus.Desc
+	cacheMisses    *prometheus.Desc
+	cacheEvictions *prometheus.Desc
+	lock           sync.RWMutex
+	caches         map[cacheKey]CacheStatsFS
+}
+
+// NewMetrics creates the collectors and registers them at registerer
+func NewMetrics(registerer prometheus.Registerer) (*Metrics, error) {
+	labels := []string{"fs", "backend", "op"}
+	cacheLabels := []string{"fs", "backend"}
+	m := &Metrics{
+		ops: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "filesystem",
+			Name:      "operations_total",
+			Help:      "Number of filesystem operations.",
+		}, labels),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "filesystem",
+			Name:      "operation_errors_total",
+			Help:      "Number of failed filesystem operations.",
+		}, labels),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "filesystem",
+			Name:      "operation_duration_seconds",
+			Help:      "Duration of filesystem operations.",
+			Buckets:   prometheus.DefBuckets,
+		}, labels),
+		bytes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "filesystem",
+			Name:      "bytes_total",
+			Help:      "Number of bytes read and written.",
+		}, labels),
+		cacheHits:      prometheus.NewDesc("filesystem_cache_hits_total", "Number of cache hits.", cacheLabels, nil),
+		cacheMisses:    prometheus.NewDesc("filesystem_cache_misses_total", "Number of cache misses.", cacheLabels, nil),
+		cacheEvictions: prometheus.NewDesc("filesystem_cache_evictions_total", "Number of cache evictions.", cacheLabels, nil),
+		caches:         map[cacheKey]CacheStatsFS{},
+	}
+	for _, c := range []prometheus.Collector{m.ops, m.errors, m.duration, m.bytes, m} {
+		if err := registerer.Register(c); err != nil {
+			return nil, errors.Wrap(err, "cannot register metrics")
+		}
+	}
+	return m, nil
+}
+
+var (
+	defaultMetrics     *Metrics
+	defaultMetricsErr  error
+	defaultMetricsOnce sync.Once
+)
+
+// DefaultMetrics returns the metrics registered at prometheus.DefaultRegisterer
+func DefaultMetrics() (*Metrics, error) {
+	defaultMetricsOnce.Do(func() {
+		defaultMetrics, defaultMetricsErr = NewMetrics(prometheus.DefaultRegisterer)
+	})
+	return defaultMetrics, defaultMetricsErr
+}
+
+func (m *Metrics) addCache(name, backend string, cache CacheStatsFS) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.caches[cacheKey{fs: name, backend: backend}] = cache
+}
+
+func (m *Metrics) removeCache(name, backend string, cache CacheStatsFS) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	key := cacheKey{fs: name, backend: backend}
+	if m.caches[key] == cache {
+		delete(m.caches, key)
+	}
+}
+
+// Describe implements prometheus.Collector for the cache statistics
+func (m *Metrics) Describe(ch chan<- *prometheus.Desc) {
+	ch <- m.cacheHits
+	ch <- m.cacheMisses
+	ch <- m.cacheEvictions
+}
+
+// Collect implements prometheus.Collector for the cache statistics
+func (m *Metrics) Collect(ch chan<- prometheus.Metric) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	for key, cache := range m.caches {
+		hits, misses, evictions := cache.CacheStats()
+		ch <- prometheus.MustNewConstMetric(m.cacheHits, prometheus.CounterValue, float64(hits), key.fs, key.backend)
+		ch <- prometheus.MustNewConstMetric(m.cacheMisses, prometheus.CounterValue, float64(misses), key.fs, key.backend)
+		ch <- prometheus.MustNewConstMetric(m.cacheEvictions, prometheus.CounterValue, float64(evictions), key.fs, key.backend)
+	}
+}
+
+var (
+	_ prometheus.Collector = &Metrics{}
+)
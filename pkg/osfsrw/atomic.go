@@ -0,0 +1,47 @@
+package osfsrw
+
+import (
+	"emperror.dev/errors"
+	"github.com/je4/filesystem/v3/pkg/writefs"
+	"os"
+)
+
+// atomicFile is written to a temporary file which is renamed to path on Close
+type atomicFile struct {
+	*os.File
+	path string
+}
+
+// Close publishes the temporary file. os.CreateTemp uses mode 0600, so the mode is set
+// to the one os.Create would use before the rename
+func (f *atomicFile) Close() error {
+	tmpPath := f.File.Name()
+	if err := f.File.Chmod(0666 &^ umask); err != nil {
+		f.File.Close()
+		os.Remove(tmpPath)
+		return errors.Wrapf(err, "cannot change mode of '%s'", tmpPath)
+	}
+	if err := f.File.Close(); err != nil {
+		os.Remove(tmpPath)
+		return errors.Wrapf(err, "cannot close '%s'", tmpPath)
+	}
+	if err := os.Rename(tmpPath, f.path); err != nil {
+		os.Remove(tmpPath)
+		return errors.Wrapf(err, "cannot rename '%s' to '%s'", tmpPath, f.path)
+	}
+	return nil
+}
+
+// Abort discards the temporary file
+func (f *atomicFile) Abort() error {
+	tmpPath := f.File.Name()
+	f.File.Close()
+	if err := os.Remove(tmpPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return errors.Wrapf(err, "cannot remove '%s'", tmpPath)
+	}
+	return nil
+}
+
+var (
+	_ writefs.AbortFileWrite = &atomicFile{}
+)
@@ -0,0 +1,25 @@
+package osfsrw
+
+import (
+	"emperror.dev/errors"
+	"github.com/je4/filesystem/v3/pkg/writefs"
+	"os"
+)
+
+// file is a file created for writing which can be aborted
+type file struct {
+	*os.File
+}
+
+// Abort closes and removes the file
+func (f *file) Abort() error {
+	f.File.Close()
+	if err := os.Remove(f.File.Name()); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return errors.Wrapf(err, "cannot remove '%s'", f.File.Name())
+	}
+	return nil
+}
+
+var (
+	_ writefs.AbortFileWrite = &file{}
+)
@@ -1,13 +1,16 @@
 package osfsrw
 
 import (
+	"context"
 	"emperror.dev/errors"
 	"github.com/je4/filesystem/v3/pkg/writefs"
 	"github.com/je4/utils/v2/pkg/zLogger"
+	"io"
 	"io/fs"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 )
 
 func NewFS(dir string, logger zLogger.ZLogger) (*osFSRW, error) {
@@ -64,10 +67,45 @@ func (d *osFSRW) Remove(path string) error {
 	return errors.WithStack(os.Remove(filepath.Join(d.dir, path)))
 }
 
+func (d *osFSRW) RemoveAll(path string) error {
+	return errors.WithStack(os.RemoveAll(filepath.Join(d.dir, path)))
+}
+
 func (d *osFSRW) Rename(oldPath, newPath string) error {
 	return errors.WithStack(os.Rename(filepath.Join(d.dir, oldPath), filepath.Join(d.dir, newPath)))
 }
 
+// CopyFile copies src to dst via reflink if supported by the filesystem.
+// Otherwise, the kernel copies the data (copy_file_range) if possible.
+func (d *osFSRW) CopyFile(src, dst string) error {
+	srcFP, err := os.Open(filepath.Join(d.dir, src))
+	if err != nil {
+		return errors.Wrapf(err, "cannot open '%s'", src)
+	}
+	defer srcFP.Close()
+	fullpath := filepath.Join(d.dir, dst)
+	if err := os.MkdirAll(filepath.Dir(fullpath), 0755); err != nil {
+		return errors.Wrapf(err, "cannot create directory '%s'", filepath.Dir(fullpath))
+	}
+	dstFP, err := os.Create(fullpath)
+	if err != nil {
+		return errors.Wrapf(err, "cannot create '%s'", dst)
+	}
+	if err := reflink(dstFP, srcFP); err != nil {
+		d.logger.Debug().Err(err).Msgf("no reflink for '%s' -> '%s'", src, dst)
+		if _, err := io.Copy(dstFP, srcFP); err != nil {
+			dstFP.Close()
+			os.Remove(fullpath)
+			return errors.Wrapf(err, "cannot copy '%s' -> '%s'", src, dst)
+		}
+	}
+	if err := dstFP.Close(); err != nil {
+		os.Remove(fullpath)
+		return errors.Wrapf(err, "cannot close '%s'", dst)
+	}
+	return nil
+}
+
 func (d *osFSRW) Open(name string) (fs.File, error) {
 	fp, err := os.Open(filepath.Join(d.dir, name))
 	return fp, errors.WithStack(err)
@@ -91,13 +129,96 @@ func (d *osFSRW) Create(path string) (writefs.FileWrite, error) {
 		return nil, errors.Wrapf(err, "cannot create directory '%s'", dir)
 	}
 	w, err := os.Create(fullpath)
-	return w, errors.Wrapf(err, "cannot create file '%s'", fullpath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot create file '%s'", fullpath)
+	}
+	return &file{File: w}, nil
+}
+
+// OpenFile opens path with os.OpenFile. Missing folders are created if os.O_CREATE is set.
+// Files created with os.O_EXCL can be aborted.
+func (d *osFSRW) OpenFile(path string, flag int, perm fs.FileMode) (writefs.FileWrite, error) {
+	fullpath := filepath.Join(d.dir, path)
+	if flag&os.O_CREATE != 0 {
+		dir := filepath.Dir(fullpath)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, errors.Wrapf(err, "cannot create directory '%s'", dir)
+		}
+	}
+	fp, err := os.OpenFile(fullpath, flag, perm)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot open file '%s'", fullpath)
+	}
+	if flag&(os.O_CREATE|os.O_EXCL) == os.O_CREATE|os.O_EXCL {
+		return &file{File: fp}, nil
+	}
+	return fp, nil
+}
+
+// CreateWriterAt creates path for random access writes
+func (d *osFSRW) CreateWriterAt(path string) (writefs.FileWriterAt, error) {
+	fp, err := d.Create(path)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return fp.(*file), nil
+}
+
+// CreateAtomic writes to a hidden temporary file in the target folder which is renamed to path on Close
+func (d *osFSRW) CreateAtomic(path string) (writefs.FileWrite, error) {
+	fullpath := filepath.Join(d.dir, path)
+	dir, base := filepath.Split(fullpath)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, errors.Wrapf(err, "cannot create directory '%s'", dir)
+	}
+	fp, err := os.CreateTemp(dir, "."+base+".*.tmp")
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot create temporary file for '%s'", fullpath)
+	}
+	return &atomicFile{File: fp, path: fullpath}, nil
+}
+
+func (d *osFSRW) Chtimes(name string, atime, mtime time.Time) error {
+	return errors.WithStack(os.Chtimes(filepath.Join(d.dir, name), atime, mtime))
+}
+
+func (d *osFSRW) Chmod(name string, mode fs.FileMode) error {
+	return errors.WithStack(os.Chmod(filepath.Join(d.dir, name), mode))
+}
+
+// Symlink creates newname as a link to oldname. oldname is not modified,
+// so relative destinations are resolved from the folder of newname.
+func (d *osFSRW) Symlink(oldname, newname string) error {
+	return errors.WithStack(os.Symlink(filepath.FromSlash(oldname), filepath.Join(d.dir, newname)))
+}
+
+func (d *osFSRW) ReadLink(name string) (string, error) {
+	dest, err := os.Readlink(filepath.Join(d.dir, name))
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	return filepath.ToSlash(dest), nil
+}
+
+func (d *osFSRW) Lstat(name string) (fs.FileInfo, error) {
+	fi, err := os.Lstat(filepath.Join(d.dir, name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, errors.WithStack(fs.ErrNotExist)
+		}
+		return nil, errors.WithStack(err)
+	}
+	return fi, nil
 }
 
 func (d *osFSRW) MkDir(path string) error {
 	return errors.WithStack(os.Mkdir(filepath.Join(d.dir, path), 0777))
 }
 
+func (d *osFSRW) MkDirAll(path string) error {
+	return errors.WithStack(os.MkdirAll(filepath.Join(d.dir, path), 0777))
+}
+
 func (d *osFSRW) ReadDir(name string) ([]fs.DirEntry, error) {
 	de, err := os.ReadDir(filepath.Join(d.dir, name))
 	if err != nil && os.IsNotExist(err) {
@@ -111,15 +232,90 @@ func (d *osFSRW) ReadFile(name string) ([]byte, error) {
 	return data, errors.WithStack(err)
 }
 
+// CreateContext checks ctx before creating the file
+func (d *osFSRW) CreateContext(ctx context.Context, path string) (writefs.FileWrite, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return d.Create(path)
+}
+
+// OpenFileContext checks ctx before opening the file
+func (d *osFSRW) OpenFileContext(ctx context.Context, path string, flag int, perm fs.FileMode) (writefs.FileWrite, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return d.OpenFile(path, flag, perm)
+}
+
+// OpenContext checks ctx before opening the file
+func (d *osFSRW) OpenContext(ctx context.Context, name string) (fs.File, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return d.Open(name)
+}
+
+// StatContext checks ctx before stating the file
+func (d *osFSRW) StatContext(ctx context.Context, name string) (fs.FileInfo, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return d.Stat(name)
+}
+
+// RemoveContext checks ctx before removing the file
+func (d *osFSRW) RemoveContext(ctx context.Context, path string) error {
+	if err := ctx.Err(); err != nil {
+		return errors.WithStack(err)
+	}
+	return d.Remove(path)
+}
+
+// RenameContext checks ctx before renaming the file
+func (d *osFSRW) RenameContext(ctx context.Context, oldPath, newPath string) error {
+	if err := ctx.Err(); err != nil {
+		return errors.WithStack(err)
+	}
+	return d.Rename(oldPath, newPath)
+}
+
+// ReadDirContext checks ctx before reading the directory
+func (d *osFSRW) ReadDirContext(ctx context.Context, name string) ([]fs.DirEntry, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return d.ReadDir(name)
+}
+
 var (
-	_ writefs.CreateFS    = &osFSRW{}
-	_ writefs.ReadWriteFS = &osFSRW{}
-	_ writefs.MkDirFS     = &osFSRW{}
-	_ writefs.RenameFS    = &osFSRW{}
-	_ writefs.RemoveFS    = &osFSRW{}
-	_ writefs.FullpathFS  = &osFSRW{}
-	_ fs.ReadDirFS        = &osFSRW{}
-	_ fs.ReadFileFS       = &osFSRW{}
-	_ fs.StatFS           = &osFSRW{}
-	_ fs.SubFS            = &osFSRW{}
+	_ writefs.CreateFS         = &osFSRW{}
+	_ writefs.CreateAtomicFS   = &osFSRW{}
+	_ writefs.ReadWriteFS      = &osFSRW{}
+	_ writefs.OpenFileFS       = &osFSRW{}
+	_ writefs.CreateWriterAtFS = &osFSRW{}
+	_ writefs.SymlinkFS        = &osFSRW{}
+	_ writefs.ReadLinkFS       = &osFSRW{}
+	_ writefs.LstatFS          = &osFSRW{}
+	_ writefs.MkDirFS          = &osFSRW{}
+	_ writefs.RenameFS         = &osFSRW{}
+	_ writefs.RemoveFS         = &osFSRW{}
+	_ writefs.RemoveAllFS      = &osFSRW{}
+	_ writefs.MkDirAllFS       = &osFSRW{}
+	_ writefs.CopyFileFS       = &osFSRW{}
+	_ writefs.ChtimesFS        = &osFSRW{}
+	_ writefs.ChmodFS          = &osFSRW{}
+	_ writefs.FullpathFS       = &osFSRW{}
+	_ fs.ReadDirFS             = &osFSRW{}
+	_ fs.ReadFileFS            = &osFSRW{}
+	_ fs.StatFS                = &osFSRW{}
+	_ fs.SubFS                 = &osFSRW{}
+
+	_ writefs.CreateContextFS   = &osFSRW{}
+	_ writefs.OpenFileContextFS = &osFSRW{}
+	_ writefs.OpenContextFS     = &osFSRW{}
+	_ writefs.StatContextFS     = &osFSRW{}
+	_ writefs.RemoveContextFS   = &osFSRW{}
+	_ writefs.RenameContextFS   = &osFSRW{}
+	_ writefs.ReadDirContextFS  = &osFSRW{}
 )
@@ -0,0 +1,69 @@
+package osfsrw
+
+import (
+	"github.com/je4/filesystem/v3/pkg/writefs"
+	"github.com/je4/filesystem/v3/pkg/writefs/writefstest"
+	"github.com/rs/zerolog"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestOSFSRW(t *testing.T) {
+	logger := zerolog.New(os.Stderr).With().Timestamp().Logger()
+	fsys, err := NewFS(t.TempDir(), &logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writefstest.TestReadWriteFS(t, fsys, nil)
+}
+
+func TestCreateAtomicMode(t *testing.T) {
+	logger := zerolog.New(os.Stderr).With().Timestamp().Logger()
+	dir := t.TempDir()
+	fsys, err := NewFS(dir, &logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fp, err := fsys.CreateAtomic("atomic.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fp.Close(); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(filepath.Join(dir, "atomic.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0666&^umask {
+		t.Fatalf("wrong mode %v", info.Mode().Perm())
+	}
+}
+
+func TestCopyModTime(t *testing.T) {
+	logger := zerolog.New(os.Stderr).With().Timestamp().Logger()
+	fsys, err := NewFS(t.TempDir(), &logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := writefs.WriteFile(fsys, "src.txt", []byte("src")); err != nil {
+		t.Fatal(err)
+	}
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	if err := writefs.Chtimes(fsys, "src.txt", mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := writefs.CopyFS(fsys, "src.txt", fsys, "dst.txt", nil); err != nil {
+		t.Fatal(err)
+	}
+	info, err := fs.Stat(fsys, "dst.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.ModTime().Equal(mtime) {
+		t.Fatalf("wrong modtime %v", info.ModTime())
+	}
+}
@@ -0,0 +1,13 @@
+//go:build linux
+
+package osfsrw
+
+import (
+	"golang.org/x/sys/unix"
+	"os"
+)
+
+// reflink clones the content of src to dst on filesystems with copy on write support (btrfs, xfs, ...)
+func reflink(dst, src *os.File) error {
+	return unix.IoctlFileClone(int(dst.Fd()), int(src.Fd()))
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package osfsrw
+
+import (
+	"github.com/je4/filesystem/v3/pkg/writefs"
+	"os"
+)
+
+// reflink is not supported on this platform
+func reflink(dst, src *os.File) error {
+	return writefs.ErrNotImplemented
+}
@@ -0,0 +1,8 @@
+//go:build !unix
+
+package osfsrw
+
+import "io/fs"
+
+// umask is not supported on this platform
+var umask fs.FileMode = 0
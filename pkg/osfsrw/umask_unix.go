@@ -0,0 +1,15 @@
+//go:build unix
+
+package osfsrw
+
+import (
+	"golang.org/x/sys/unix"
+	"io/fs"
+)
+
+// umask of the process. It is read once during initialization, since reading requires setting it
+var umask = func() fs.FileMode {
+	mask := unix.Umask(0)
+	unix.Umask(mask)
+	return fs.FileMode(mask)
+}()
@@ -0,0 +1,96 @@
+package quotafs
+
+import (
+	"emperror.dev/errors"
+	"github.com/je4/filesystem/v3/pkg/writefs"
+	"io"
+)
+
+// fileWrite reserves the quota for every Write
+type fileWrite struct {
+	writefs.FileWrite
+	q    *quotaFS
+	name string
+	// base is the size of the content, which was kept when the file has been opened
+	base    int64
+	written int64
+	closed  bool
+}
+
+// Write fails with ErrQuotaExceeded without writing anything, if p does not fit into the quota
+func (fw *fileWrite) Write(p []byte) (int, error) {
+	if err := fw.q.reserve(fw.name, int64(len(p))); err != nil {
+		return 0, errors.WithStack(err)
+	}
+	n, err := fw.FileWrite.Write(p)
+	fw.written += int64(n)
+	if n < len(p) {
+		// release the unwritten part
+		fw.q.release(int64(len(p) - n))
+	}
+	return n, err
+}
+
+func (fw *fileWrite) Close() error {
+	err := fw.FileWrite.Close()
+	fw.settle()
+	return errors.WithStack(err)
+}
+
+func (fw *fileWrite) Abort() error {
+	if err := writefs.Abort(fw.FileWrite); err != nil {
+		// the caller will close the file instead
+		return errors.WithStack(err)
+	}
+	fw.settle()
+	return nil
+}
+
+func (fw *fileWrite) settle() {
+	if fw.closed {
+		return
+	}
+	fw.closed = true
+	fw.q.settle(fw.name, fw.base+fw.written)
+}
+
+// fileWriterAt reserves the quota for the bytes beyond the current end of the file
+type fileWriterAt struct {
+	*fileWrite
+	writerAt io.WriterAt
+	pos      int64
+}
+
+// grow reserves the bytes up to end
+func (fw *fileWriterAt) grow(end int64) error {
+	if end <= fw.written {
+		return nil
+	}
+	if err := fw.q.reserve(fw.name, end-fw.written); err != nil {
+		return errors.WithStack(err)
+	}
+	fw.written = end
+	return nil
+}
+
+func (fw *fileWriterAt) Write(p []byte) (int, error) {
+	if err := fw.grow(fw.pos + int64(len(p))); err != nil {
+		return 0, errors.WithStack(err)
+	}
+	n, err := fw.FileWrite.Write(p)
+	fw.pos += int64(n)
+	return n, err
+}
+
+func (fw *fileWriterAt) WriteAt(p []byte, off int64) (int, error) {
+	if err := fw.grow(off + int64(len(p))); err != nil {
+		return 0, errors.WithStack(err)
+	}
+	return fw.writerAt.WriteAt(p, off)
+}
+
+var (
+	_ writefs.AbortFileWrite = &fileWrite{}
+	_ writefs.FileWriterAt   = &fileWriterAt{}
+	_ writefs.AbortFileWrite = &fileWriterAt{}
+)
@@ -0,0 +1,407 @@
+// Package quotafs limits the number of bytes and files which can be written to a filesystem
+package quotafs
+
+import (
+	"context"
+	"emperror.dev/errors"
+	"fmt"
+	"github.com/je4/filesystem/v3/pkg/writefs"
+	"github.com/je4/utils/v2/pkg/zLogger"
+	"io/fs"
+	"os"
+	"sync"
+	"time"
+)
+
+// ErrQuotaExceeded is returned by writes which would exceed the limits of the filesystem.
+// It is the same error as writefs.ErrQuotaExceeded.
+var ErrQuotaExceeded = writefs.ErrQuotaExceeded
+
+// NewFS wraps base with a quota of maxBytes and maxFiles. A limit of 0 means unlimited.
+// The current usage is computed by walking the whole filesystem.
+func NewFS(base writefs.ReadWriteFS, maxBytes, maxFiles int64, logger zLogger.ZLogger) (*quotaFS, error) {
+	_logger := logger.With().Str("class", "quotaFS").Logger()
+	logger = &_logger
+	q := &quotaFS{
+		base:     base,
+		maxBytes: maxBytes,
+		maxFiles: maxFiles,
+		logger:   logger,
+	}
+	var err error
+	if q.bytes, q.files, err = q.scan("."); err != nil {
+		return nil, errors.Wrapf(err, "cannot compute usage of '%v'", base)
+	}
+	logger.Debug().Msgf("usage of %v: %d bytes, %d files", base, q.bytes, q.files)
+	return q, nil
+}
+
+type quotaFS struct {
+	base     writefs.ReadWriteFS
+	maxBytes int64
+	maxFiles int64
+	lock     sync.Mutex
+	bytes    int64
+	files    int64
+	logger   zLogger.ZLogger
+}
+
+// scan returns the size and the number of files below name. A missing name is empty.
+func (q *quotaFS) scan(name string) (bytes, files int64, err error) {
+	err = fs.WalkDir(q.base, name, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == name && errors.Is(err, fs.ErrNotExist) {
+				return fs.SkipAll
+			}
+			return errors.Wrapf(err, "cannot walk '%s'", path)
+		}
+		if d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return errors.Wrapf(err, "cannot stat '%s'", path)
+		}
+		bytes += info.Size()
+		files++
+		return nil
+	})
+	return bytes, files, errors.WithStack(err)
+}
+
+// stat returns the size of the file name and whether it exists
+func (q *quotaFS) stat(ctx context.Context, name string) (int64, bool, error) {
+	info, err := writefs.StatContext(ctx, q.base, name)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return 0, false, nil
+		}
+		return 0, false, errors.Wrapf(err, "cannot stat '%s'", name)
+	}
+	if info.IsDir() {
+		return 0, false, nil
+	}
+	return info.Size(), true, nil
+}
+
+// Usage returns the number of bytes and files in the filesystem
+func (q *quotaFS) Usage() (bytes, files int64) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	return q.bytes, q.files
+}
+
+func (q *quotaFS) String() string {
+	return fmt.Sprintf("quotaFS(%v)", q.base)
+}
+
+func (q *quotaFS) Open(name string) (fs.File, error) {
+	return q.base.Open(name)
+}
+
+func (q *quotaFS) OpenContext(ctx context.Context, name string) (fs.File, error) {
+	return writefs.OpenContext(ctx, q.base, name)
+}
+
+func (q *quotaFS) Stat(name string) (fs.FileInfo, error) {
+	return fs.Stat(q.base, name)
+}
+
+func (q *quotaFS) StatContext(ctx context.Context, name string) (fs.FileInfo, error) {
+	return writefs.StatContext(ctx, q.base, name)
+}
+
+func (q *quotaFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	return fs.ReadDir(q.base, name)
+}
+
+func (q *quotaFS) ReadDirContext(ctx context.Context, name string) ([]fs.DirEntry, error) {
+	return writefs.ReadDirContext(ctx, q.base, name)
+}
+
+func (q *quotaFS) ReadFile(name string) ([]byte, error) {
+	return fs.ReadFile(q.base, name)
+}
+
+func (q *quotaFS) Sub(dir string) (fs.FS, error) {
+	return writefs.NewSubFS(q, dir), nil
+}
+
+// Create counts a new file immediately and the written bytes on every Write
+func (q *quotaFS) Create(name string) (writefs.FileWrite, error) {
+	return q.CreateContext(context.Background(), name)
+}
+
+func (q *quotaFS) CreateContext(ctx context.Context, name string) (writefs.FileWrite, error) {
+	return q.open(ctx, name, true, func() (writefs.FileWrite, error) {
+		return writefs.CreateContext(ctx, q.base, name)
+	})
+}
+
+func (q *quotaFS) CreateAtomic(name string) (writefs.FileWrite, error) {
+	return q.CreateAtomicContext(context.Background(), name)
+}
+
+func (q *quotaFS) CreateAtomicContext(ctx context.Context, name string) (writefs.FileWrite, error) {
+	return q.open(ctx, name, true, func() (writefs.FileWrite, error) {
+		return writefs.CreateAtomicContext(ctx, q.base, name)
+	})
+}
+
+// OpenFile counts the written bytes like Create. Without os.O_TRUNC, the old content stays in the usage.
+func (q *quotaFS) OpenFile(name string, flag int, perm fs.FileMode) (writefs.FileWrite, error) {
+	return q.OpenFileContext(context.Background(), name, flag, perm)
+}
+
+func (q *quotaFS) OpenFileContext(ctx context.Context, name string, flag int, perm fs.FileMode) (writefs.FileWrite, error) {
+	return q.open(ctx, name, flag&os.O_TRUNC != 0, func() (writefs.FileWrite, error) {
+		return writefs.OpenFileContext(ctx, q.base, name, flag, perm)
+	})
+}
+
+// CreateWriterAt counts the bytes beyond the current end of the file on every Write and WriteAt
+func (q *quotaFS) CreateWriterAt(name string) (writefs.FileWriterAt, error) {
+	var writerAt writefs.FileWriterAt
+	fw, err := q.open(context.Background(), name, true, func() (writefs.FileWrite, error) {
+		fp, err := writefs.CreateWriterAt(q.base, name)
+		writerAt = fp
+		return fp, err
+	})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return &fileWriterAt{fileWrite: fw, writerAt: writerAt}, nil
+}
+
+// open counts the file name, before it is opened with fn.
+// If truncate is set, the size of an existing file is subtracted from the usage immediately.
+func (q *quotaFS) open(ctx context.Context, name string, truncate bool, fn func() (writefs.FileWrite, error)) (*fileWrite, error) {
+	oldSize, exists, err := q.stat(ctx, name)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	var base, delta int64 = oldSize, 0
+	if truncate {
+		base, delta = 0, -oldSize
+	}
+	if err := q.reserveFile(name, exists, delta); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	fp, err := fn()
+	if err != nil {
+		q.releaseFile(exists, delta)
+		return nil, errors.WithStack(err)
+	}
+	return &fileWrite{FileWrite: fp, q: q, name: name, base: base}, nil
+}
+
+// reserveFile counts a new file and adds delta bytes to the usage if the limits allow it
+func (q *quotaFS) reserveFile(name string, exists bool, delta int64) error {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if !exists && q.maxFiles > 0 && q.files+1 > q.maxFiles {
+		return errors.Wrapf(ErrQuotaExceeded, "cannot create '%s': limit of %d files reached", name, q.maxFiles)
+	}
+	if delta > 0 && q.maxBytes > 0 && q.bytes+delta > q.maxBytes {
+		return errors.Wrapf(ErrQuotaExceeded, "cannot write '%s': limit of %d bytes reached", name, q.maxBytes)
+	}
+	if !exists {
+		q.files++
+	}
+	q.bytes += delta
+	return nil
+}
+
+// releaseFile reverts reserveFile
+func (q *quotaFS) releaseFile(exists bool, delta int64) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if !exists {
+		q.files--
+	}
+	q.bytes -= delta
+}
+
+// reserve adds size bytes to the usage if the limit allows it
+func (q *quotaFS) reserve(name string, size int64) error {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if q.maxBytes > 0 && q.bytes+size > q.maxBytes {
+		return errors.Wrapf(ErrQuotaExceeded, "cannot write '%s': limit of %d bytes reached", name, q.maxBytes)
+	}
+	q.bytes += size
+	return nil
+}
+
+// release subtracts size bytes from the usage
+func (q *quotaFS) release(size int64) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	q.bytes -= size
+}
+
+// settle replaces the expected size of name with the real size after the file has been closed or aborted
+func (q *quotaFS) settle(name string, expected int64) {
+	size, exists, err := q.stat(context.Background(), name)
+	if err != nil {
+		q.logger.Error().Err(err).Msgf("cannot update usage of '%s'", name)
+		return
+	}
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	q.bytes += size - expected
+	if !exists {
+		q.files--
+	}
+}
+
+func (q *quotaFS) MkDir(name string) error {
+	return q.MkDirContext(context.Background(), name)
+}
+
+func (q *quotaFS) MkDirContext(ctx context.Context, name string) error {
+	return errors.WithStack(writefs.MkDirContext(ctx, q.base, name))
+}
+
+func (q *quotaFS) MkDirAll(name string) error {
+	return q.MkDirAllContext(context.Background(), name)
+}
+
+func (q *quotaFS) MkDirAllContext(ctx context.Context, name string) error {
+	return errors.WithStack(writefs.MkDirAllContext(ctx, q.base, name))
+}
+
+func (q *quotaFS) Remove(name string) error {
+	return q.RemoveContext(context.Background(), name)
+}
+
+func (q *quotaFS) RemoveContext(ctx context.Context, name string) error {
+	size, exists, err := q.stat(ctx, name)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if err := writefs.RemoveContext(ctx, q.base, name); err != nil {
+		return errors.WithStack(err)
+	}
+	if exists {
+		q.lock.Lock()
+		q.bytes -= size
+		q.files--
+		q.lock.Unlock()
+	}
+	return nil
+}
+
+// RemoveAll removes name and rescans it afterwards, because the removal may have failed partially
+func (q *quotaFS) RemoveAll(name string) error {
+	return q.RemoveAllContext(context.Background(), name)
+}
+
+func (q *quotaFS) RemoveAllContext(ctx context.Context, name string) error {
+	bytesBefore, filesBefore, err := q.scan(name)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	removeErr := writefs.RemoveAllContext(ctx, q.base, name)
+	bytesAfter, filesAfter, err := q.scan(name)
+	if err != nil {
+		return errors.Combine(errors.WithStack(removeErr), errors.WithStack(err))
+	}
+	q.lock.Lock()
+	q.bytes += bytesAfter - bytesBefore
+	q.files += filesAfter - filesBefore
+	q.lock.Unlock()
+	return errors.WithStack(removeErr)
+}
+
+// Rename moves oldPath to newPath. A replaced file at newPath is subtracted from the usage.
+func (q *quotaFS) Rename(oldPath, newPath string) error {
+	return q.RenameContext(context.Background(), oldPath, newPath)
+}
+
+func (q *quotaFS) RenameContext(ctx context.Context, oldPath, newPath string) error {
+	size, exists, err := q.stat(ctx, newPath)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if err := writefs.RenameContext(ctx, q.base, oldPath, newPath); err != nil {
+		return errors.WithStack(err)
+	}
+	if exists && oldPath != newPath {
+		q.lock.Lock()
+		q.bytes -= size
+		q.files--
+		q.lock.Unlock()
+	}
+	return nil
+}
+
+// CopyFile reserves the size of src for dst before the copy. A replaced file at dst is subtracted from the usage.
+func (q *quotaFS) CopyFile(src, dst string) error {
+	cfs, ok := q.base.(writefs.CopyFileFS)
+	if !ok {
+		return errors.Wrap(writefs.ErrNotImplemented, "CopyFile")
+	}
+	ctx := context.Background()
+	size, _, err := q.stat(ctx, src)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	oldSize, exists, err := q.stat(ctx, dst)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if err := q.reserveFile(dst, exists, size-oldSize); err != nil {
+		return errors.WithStack(err)
+	}
+	if err := cfs.CopyFile(src, dst); err != nil {
+		q.releaseFile(exists, size-oldSize)
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+func (q *quotaFS) Chtimes(name string, atime, mtime time.Time) error {
+	return errors.WithStack(writefs.Chtimes(q.base, name, atime, mtime))
+}
+
+func (q *quotaFS) Chmod(name string, mode fs.FileMode) error {
+	return errors.WithStack(writefs.Chmod(q.base, name, mode))
+}
+
+func (q *quotaFS) Close() error {
+	return errors.WithStack(writefs.Close(q.base))
+}
+
+var (
+	_ writefs.ReadWriteFS      = &quotaFS{}
+	_ writefs.CreateAtomicFS   = &quotaFS{}
+	_ writefs.OpenFileFS       = &quotaFS{}
+	_ writefs.CreateWriterAtFS = &quotaFS{}
+	_ writefs.MkDirFS          = &quotaFS{}
+	_ writefs.MkDirAllFS       = &quotaFS{}
+	_ writefs.RemoveFS         = &quotaFS{}
+	_ writefs.RemoveAllFS      = &quotaFS{}
+	_ writefs.RenameFS         = &quotaFS{}
+	_ writefs.CopyFileFS       = &quotaFS{}
+	_ writefs.ChtimesFS        = &quotaFS{}
+	_ writefs.ChmodFS          = &quotaFS{}
+	_ writefs.CloseFS          = &quotaFS{}
+	_ fs.ReadDirFS             = &quotaFS{}
+	_ fs.ReadFileFS            = &quotaFS{}
+	_ fs.StatFS                = &quotaFS{}
+	_ fs.SubFS                 = &quotaFS{}
+	_ fmt.Stringer             = &quotaFS{}
+
+	_ writefs.CreateContextFS       = &quotaFS{}
+	_ writefs.CreateAtomicContextFS = &quotaFS{}
+	_ writefs.OpenFileContextFS     = &quotaFS{}
+	_ writefs.OpenContextFS         = &quotaFS{}
+	_ writefs.StatContextFS         = &quotaFS{}
+	_ writefs.ReadDirContextFS      = &quotaFS{}
+	_ writefs.MkDirContextFS        = &quotaFS{}
+	_ writefs.MkDirAllContextFS     = &quotaFS{}
+	_ writefs.RemoveContextFS       = &quotaFS{}
+	_ writefs.RemoveAllContextFS    = &quotaFS{}
+	_ writefs.RenameContextFS       = &quotaFS{}
+)
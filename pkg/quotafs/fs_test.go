@@ -0,0 +1,102 @@
+package quotafs
+
+import (
+	"emperror.dev/errors"
+	"github.com/je4/filesystem/v3/pkg/memfsrw"
+	"github.com/je4/filesystem/v3/pkg/writefs"
+	"github.com/rs/zerolog"
+	"os"
+	"testing"
+)
+
+func TestQuotaFS(t *testing.T) {
+	logger := zerolog.New(os.Stderr).With().Timestamp().Logger()
+	memFS, err := memfsrw.NewFS("quota", &logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := writefs.WriteFile(memFS, "existing/file.txt", []byte("0123456789")); err != nil {
+		t.Fatal(err)
+	}
+	quotaFS, err := NewFS(memFS, 30, 3, &logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkUsage := func(t *testing.T, bytes, files int64) {
+		t.Helper()
+		if b, f := quotaFS.Usage(); b != bytes || f != files {
+			t.Errorf("wrong usage: expected %d bytes and %d files, got %d bytes and %d files", bytes, files, b, f)
+		}
+	}
+	checkUsage(t, 10, 1)
+
+	t.Run("bytes", func(t *testing.T) {
+		if _, err := writefs.WriteFile(quotaFS, "a.txt", []byte("0123456789")); err != nil {
+			t.Fatal(err)
+		}
+		checkUsage(t, 20, 2)
+		if _, err := writefs.WriteFile(quotaFS, "b.txt", []byte("0123456789ABCDEF")); !errors.Is(err, ErrQuotaExceeded) {
+			t.Errorf("expected ErrQuotaExceeded, got %v", err)
+		}
+		// the empty file has been created
+		checkUsage(t, 20, 3)
+		// replacing a file frees its old size
+		if _, err := writefs.WriteFile(quotaFS, "a.txt", []byte("0123456789ABCDEF")); err != nil {
+			t.Fatal(err)
+		}
+		checkUsage(t, 26, 3)
+	})
+
+	t.Run("files", func(t *testing.T) {
+		if _, err := writefs.Create(quotaFS, "c.txt"); !errors.Is(err, ErrQuotaExceeded) {
+			t.Errorf("expected ErrQuotaExceeded, got %v", err)
+		}
+		if err := writefs.Remove(quotaFS, "b.txt"); err != nil {
+			t.Fatal(err)
+		}
+		checkUsage(t, 26, 2)
+		fp, err := writefs.Create(quotaFS, "c.txt")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := fp.Write([]byte("abc")); err != nil {
+			t.Fatal(err)
+		}
+		if err := writefs.Abort(fp); err != nil {
+			t.Fatal(err)
+		}
+		checkUsage(t, 26, 2)
+	})
+
+	t.Run("rename & removeall", func(t *testing.T) {
+		if err := writefs.Rename(quotaFS, "existing/file.txt", "a.txt"); err != nil {
+			t.Fatal(err)
+		}
+		checkUsage(t, 10, 1)
+		if err := writefs.RemoveAll(quotaFS, "."); err != nil {
+			t.Fatal(err)
+		}
+		checkUsage(t, 0, 0)
+	})
+
+	t.Run("writer at", func(t *testing.T) {
+		fp, err := writefs.CreateWriterAt(quotaFS, "sparse.bin")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := fp.WriteAt([]byte("0123456789"), 20); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := fp.WriteAt([]byte("A"), 30); !errors.Is(err, ErrQuotaExceeded) {
+			t.Errorf("expected ErrQuotaExceeded, got %v", err)
+		}
+		// overwriting does not need additional quota
+		if _, err := fp.Write([]byte("abc")); err != nil {
+			t.Fatal(err)
+		}
+		if err := fp.Close(); err != nil {
+			t.Fatal(err)
+		}
+		checkUsage(t, 30, 1)
+	})
+}
@@ -8,20 +8,34 @@ import (
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/je4/filesystem/v3/pkg/eventfs"
+	"github.com/je4/filesystem/v3/pkg/throttlefs"
+	"github.com/je4/filesystem/v3/pkg/tracefs"
 	"github.com/je4/filesystem/v3/pkg/writefs"
 	"github.com/je4/utils/v2/pkg/zLogger"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 	"golang.org/x/exp/slices"
 	"io"
 	"io/fs"
 	"net/http"
 	"net/url"
+	"os"
 	"regexp"
 	"strings"
 	"sync"
-	"time"
 )
 
-func NewMainController(addr, extAddr string, tlsConfig *tls.Config, jwtAlgs []string, jwtKeys map[string]string, vfs fs.FS, logger zLogger.ZLogger) (*mainController, error) {
+// NewMainController creates the rest controller for vfs.
+// The requests of a client with an entry in throttles are limited by its limiter. Clients are identified
+// by the client_id claim of the jwt, the jwt subject "vfs.<vfs>" if there is no client_id,
+// or the subject of the client certificate.
+// If metricsAddr is not empty, the prometheus metrics are served on a separate listener at metricsAddr.
+func NewMainController(addr, extAddr, metricsAddr string, tlsConfig *tls.Config, jwtAlgs []string, jwtKeys map[string]string, vfs fs.FS, throttles map[string]*throttlefs.Limiter, logger zLogger.ZLogger) (*mainController, error) {
 	u, err := url.Parse(extAddr)
 	if err != nil {
 		return nil, errors.Wrapf(err, "invalid external address '%s'", extAddr)
@@ -32,15 +46,25 @@ func NewMainController(addr, extAddr string, tlsConfig *tls.Config, jwtAlgs []st
 	router := gin.Default()
 
 	_logger := logger.With().Str("httpService", "mainController").Logger()
+	throttled := map[string]fs.FS{}
+	for client, limiter := range throttles {
+		tFS, err := throttlefs.NewFS(vfs, limiter, &_logger)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot throttle client '%s'", client)
+		}
+		throttled[client] = tFS
+	}
 	c := &mainController{
-		addr:    addr,
-		extAddr: extAddr,
-		jwtAlgs: jwtAlgs,
-		jwtKeys: jwtKeys,
-		router:  router,
-		subpath: subpath,
-		logger:  &_logger,
-		vfs:     vfs,
+		addr:        addr,
+		extAddr:     extAddr,
+		metricsAddr: metricsAddr,
+		jwtAlgs:     jwtAlgs,
+		jwtKeys:     jwtKeys,
+		router:      router,
+		subpath:     subpath,
+		logger:      &_logger,
+		vfs:         vfs,
+		throttled:   throttled,
 	}
 	if err := c.Init(tlsConfig); err != nil {
 		return nil, errors.Wrap(err, "cannot initialize rest controller")
@@ -49,18 +73,23 @@ func NewMainController(addr, extAddr string, tlsConfig *tls.Config, jwtAlgs []st
 }
 
 type mainController struct {
-	server  http.Server
-	router  *gin.Engine
-	addr    string
-	subpath string
-	logger  zLogger.ZLogger
-	vfs     fs.FS
-	jwtAlgs []string
-	extAddr string
-	jwtKeys map[string]string
+	server        http.Server
+	metricsServer *http.Server
+	router        *gin.Engine
+	addr          string
+	metricsAddr   string
+	subpath       string
+	logger        zLogger.ZLogger
+	vfs           fs.FS
+	jwtAlgs       []string
+	extAddr       string
+	jwtKeys       map[string]string
+	throttled     map[string]fs.FS
 }
 
 func (ctrl *mainController) Init(tlsConfig *tls.Config) error {
+	ctrl.router.Use(ctrl.trace)
+
 	if len(ctrl.jwtKeys) == 0 {
 		ctrl.router.Use(ctrl.checkAccessMTLS)
 	} else {
@@ -71,32 +100,57 @@ func (ctrl *mainController) Init(tlsConfig *tls.Config) error {
 	ctrl.router.GET("/:vfs/*path", ctrl.read)
 	ctrl.router.PUT("/:vfs/*path", ctrl.create)
 	ctrl.router.DELETE("/:vfs/*path", ctrl.delete)
+	ctrl.router.POST("/:vfs/*path", ctrl.rename)
 
 	ctrl.server = http.Server{
 		Addr:      ctrl.addr,
 		Handler:   ctrl.router,
 		TLSConfig: tlsConfig,
 	}
+	// the metrics are not part of the vfs namespace and its access checks
+	if ctrl.metricsAddr != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", promhttp.Handler())
+		ctrl.metricsServer = &http.Server{
+			Addr:    ctrl.metricsAddr,
+			Handler: mux,
+		}
+	}
 
 	return nil
 }
 
+// Handler returns the http handler of the controller, e.g. for tests with httptest
+func (ctrl *mainController) Handler() http.Handler {
+	return ctrl.router
+}
+
 func (ctrl *mainController) Start(wg *sync.WaitGroup) {
-	go func() {
+	if ctrl.metricsServer != nil {
 		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			fmt.Printf("starting metrics server at http://%s/metrics\n", ctrl.metricsAddr)
+			if err := ctrl.metricsServer.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+				ctrl.logger.Error().Err(err).Msgf("metrics server on '%s' ended", ctrl.metricsAddr)
+			}
+		}()
+	}
+	wg.Add(1)
+	go func() {
 		defer wg.Done() // let main know we are done cleaning up
 
 		if ctrl.server.TLSConfig == nil {
 			fmt.Printf("starting server at http://%s\n", ctrl.addr)
 			if err := ctrl.server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
 				// unexpected error. port in use?
-				fmt.Errorf("server on '%s' ended: %v", ctrl.addr, err)
+				ctrl.logger.Error().Err(err).Msgf("server on '%s' ended", ctrl.addr)
 			}
 		} else {
 			fmt.Printf("starting server at https://%s\n", ctrl.addr)
 			if err := ctrl.server.ListenAndServeTLS("", ""); !errors.Is(err, http.ErrServerClosed) {
 				// unexpected error. port in use?
-				fmt.Errorf("server on '%s' ended: %v", ctrl.addr, err)
+				ctrl.logger.Error().Err(err).Msgf("server on '%s' ended", ctrl.addr)
 			}
 		}
 		// always returns error. ErrServerClosed on graceful close
@@ -104,11 +158,14 @@ func (ctrl *mainController) Start(wg *sync.WaitGroup) {
 }
 
 func (ctrl *mainController) Stop() {
-	ctrl.server.Shutdown(context.Background())
+	ctrl.GracefulStop()
 }
 
 func (ctrl *mainController) GracefulStop() {
 	ctrl.server.Shutdown(context.Background())
+	if ctrl.metricsServer != nil {
+		ctrl.metricsServer.Shutdown(context.Background())
+	}
 }
 
 var isUrlRegexp = regexp.MustCompile(`^[a-z]+://`)
@@ -122,6 +179,8 @@ func (ctrl *mainController) checkAccessMTLS(c *gin.Context) {
 	for _, cert := range c.Request.TLS.PeerCertificates {
 		for _, u := range cert.URIs {
 			if slices.Contains(allowedURIs, u.String()) {
+				c.Set("actor", cert.Subject.String())
+				c.Set("client", cert.Subject.String())
 				return
 			}
 		}
@@ -132,6 +191,14 @@ func (ctrl *mainController) checkAccessMTLS(c *gin.Context) {
 	return
 }
 
+// accessClaims are the claims of the access tokens. The subject "vfs.<vfs>" grants access to a vfs,
+// client_id identifies the caller (RFC 9068). Since the subject is the same for all callers of a vfs,
+// audit events and limits use the client_id, and the subject only for tokens without client_id.
+type accessClaims struct {
+	jwt.RegisteredClaims
+	ClientID string `json:"client_id,omitempty"`
+}
+
 func (ctrl *mainController) checkAccessJWT(c *gin.Context) {
 	token := c.Query("token")
 	if token == "" {
@@ -145,7 +212,8 @@ func (ctrl *mainController) checkAccessJWT(c *gin.Context) {
 		token = strings.TrimPrefix(authHeader, "Bearer ")
 	}
 	vfs := c.Param("vfs")
-	jwtToken, err := jwt.ParseWithClaims(token, &jwt.RegisteredClaims{}, func(token *jwt.Token) (interface{}, error) {
+	claims := &accessClaims{}
+	jwtToken, err := jwt.ParseWithClaims(token, claims, func(token *jwt.Token) (interface{}, error) {
 		jwtKey, ok := ctrl.jwtKeys[vfs]
 		if !ok {
 			return nil, fmt.Errorf("no jwt key for vfs '%s'", vfs)
@@ -183,34 +251,97 @@ func (ctrl *mainController) checkAccessJWT(c *gin.Context) {
 		})
 		return
 	}
+	// tokens without client_id are identified by their subject
+	client := claims.ClientID
+	if client == "" {
+		client = subject
+	}
+	c.Set("actor", client)
+	c.Set("client", client)
 	return
 }
+
+// trace continues the trace of the client and creates a server span for the request
+func (ctrl *mainController) trace(c *gin.Context) {
+	ctx := tracefs.Propagator.Extract(c.Request.Context(), propagation.HeaderCarrier(c.Request.Header))
+	ctx, span := otel.Tracer(tracefs.InstrumentationName).Start(ctx, c.Request.Method+" "+c.FullPath(),
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			attribute.String("http.method", c.Request.Method),
+			attribute.String("vfs", c.Param("vfs")),
+			attribute.String("path", c.Param("path")),
+		),
+	)
+	defer span.End()
+	c.Request = c.Request.WithContext(ctx)
+	c.Next()
+	status := c.Writer.Status()
+	span.SetAttributes(attribute.Int("http.status_code", status))
+	if status >= http.StatusInternalServerError {
+		span.SetStatus(codes.Error, http.StatusText(status))
+	}
+}
+
+// requestContext returns the request context with the authenticated actor for the audit events
+func (ctrl *mainController) requestContext(c *gin.Context) context.Context {
+	return eventfs.WithActor(c.Request.Context(), c.GetString("actor"))
+}
+
+// fsys returns the vfs of the request, which is throttled if the client has limits
+func (ctrl *mainController) fsys(c *gin.Context) fs.FS {
+	if tFS, ok := ctrl.throttled[c.GetString("client")]; ok {
+		return tFS
+	}
+	return ctrl.vfs
+}
+
 func (ctrl *mainController) read(c *gin.Context) {
 	vfs := c.Param("vfs")
 	path := strings.Trim(c.Param("path"), "/")
 	_, stat := c.GetQuery("stat")
+	_, readdir := c.GetQuery("readdir")
 
 	vfsPath := fmt.Sprintf("vfs://%s/%s", vfs, path)
 	ctrl.logger.Debug().Str("vfsPath", vfsPath).Msg("read")
 	if stat {
-		info, err := fs.Stat(ctrl.vfs, vfsPath)
+		info, err := writefs.StatContext(c.Request.Context(), ctrl.fsys(c), vfsPath)
 		if err != nil {
-			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+			status := http.StatusNotFound
+			if writefs.IsTransient(err) {
+				status = http.StatusServiceUnavailable
+			}
+			c.AbortWithStatusJSON(status, gin.H{
 				"error": fmt.Sprintf("cannot stat '%s': %v", vfsPath, err),
 			})
 			return
 		}
-		c.JSON(http.StatusOK, fileInfo{
-			Name_:    info.Name(),
-			Size_:    info.Size(),
-			Mode_:    info.Mode(),
-			ModTime_: info.ModTime().Format(time.RFC3339),
-			IsDir_:   info.IsDir(),
-		})
+		c.JSON(http.StatusOK, newFileInfo(info))
+		return
+	}
+	if readdir {
+		entries, err := writefs.ReadDirContext(c.Request.Context(), ctrl.fsys(c), vfsPath)
+		if err != nil {
+			c.AbortWithStatusJSON(ctrl.errorStatus(err), gin.H{
+				"error": fmt.Sprintf("cannot read directory '%s': %v", vfsPath, err),
+			})
+			return
+		}
+		infos := make([]fileInfo, 0, len(entries))
+		for _, entry := range entries {
+			info, err := entry.Info()
+			if err != nil {
+				c.AbortWithStatusJSON(ctrl.errorStatus(err), gin.H{
+					"error": fmt.Sprintf("cannot get info of '%s/%s': %v", vfsPath, entry.Name(), err),
+				})
+				return
+			}
+			infos = append(infos, newFileInfo(info))
+		}
+		c.JSON(http.StatusOK, infos)
 		return
 	}
 	//c.Header("Content-Type", mime)
-	c.FileFromFS(vfsPath, http.FS(ctrl.vfs))
+	c.FileFromFS(vfsPath, http.FS(ctrl.fsys(c)))
 	return
 }
 
@@ -220,15 +351,24 @@ func (ctrl *mainController) create(c *gin.Context) {
 
 	vfsPath := fmt.Sprintf("vfs://%s/%s", vfs, path)
 	ctrl.logger.Debug().Str("vfsPath", vfsPath).Msg("create")
-	_, err := fs.Stat(ctrl.vfs, vfsPath)
-	if !errors.Is(err, fs.ErrNotExist) {
+	_, atomic := c.GetQuery("atomic")
+	ctx := ctrl.requestContext(c)
+	fsys := ctrl.fsys(c)
+	fp, err := ctrl.createExclusive(ctx, fsys, vfsPath, atomic)
+	if errors.Is(err, fs.ErrExist) {
 		ctrl.logger.Error().Err(err).Msgf("'%s' already exists", vfsPath)
 		c.AbortWithStatusJSON(http.StatusConflict, gin.H{
 			"error": fmt.Sprintf("'%s' already exists", vfsPath),
 		})
 		return
 	}
-	fp, err := writefs.Create(ctrl.vfs, vfsPath)
+	if errors.Is(err, writefs.ErrQuotaExceeded) {
+		ctrl.logger.Error().Err(err).Msgf("cannot create '%s'", vfsPath)
+		c.AbortWithStatusJSON(http.StatusInsufficientStorage, gin.H{
+			"error": fmt.Sprintf("cannot create '%s': %v", vfsPath, err),
+		})
+		return
+	}
 	if err != nil {
 		ctrl.logger.Error().Err(err).Msgf("cannot create '%s'", vfsPath)
 		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
@@ -240,19 +380,44 @@ func (ctrl *mainController) create(c *gin.Context) {
 	written, err := io.Copy(fp, c.Request.Body)
 	if err != nil {
 		errs := []error{err}
-		if err := writefs.Remove(ctrl.vfs, vfsPath); err != nil {
-			errs = append(errs, err)
+		if err := writefs.Abort(fp); err != nil {
+			if !errors.Is(err, writefs.ErrNotImplemented) {
+				errs = append(errs, err)
+			} else {
+				if err := fp.Close(); err != nil {
+					errs = append(errs, err)
+				}
+				if err := writefs.RemoveContext(context.WithoutCancel(ctx), fsys, vfsPath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+					errs = append(errs, err)
+				}
+			}
 		}
 		ctrl.logger.Error().Err(errors.Combine(errs...)).Msgf("cannot write '%s'", vfsPath)
-		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+		status := http.StatusInternalServerError
+		if errors.Is(err, writefs.ErrQuotaExceeded) {
+			status = http.StatusInsufficientStorage
+		}
+		c.AbortWithStatusJSON(status, gin.H{
 			"error": fmt.Sprintf("cannot write '%s': %v", vfsPath, errors.Combine(errs...)),
 		})
-		fp.Close()
 		return
 	}
 
 	if err := fp.Close(); err != nil {
 		ctrl.logger.Error().Err(err).Msgf("cannot close '%s'", vfsPath)
+		if errors.Is(err, fs.ErrExist) {
+			// conditional uploads fail on completion
+			c.AbortWithStatusJSON(http.StatusConflict, gin.H{
+				"error": fmt.Sprintf("'%s' already exists", vfsPath),
+			})
+			return
+		}
+		if errors.Is(err, writefs.ErrQuotaExceeded) {
+			c.AbortWithStatusJSON(http.StatusInsufficientStorage, gin.H{
+				"error": fmt.Sprintf("cannot close '%s': %v", vfsPath, err),
+			})
+			return
+		}
 		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
 			"error": fmt.Sprintf("cannot close '%s': %v", vfsPath, err),
 		})
@@ -266,15 +431,91 @@ func (ctrl *mainController) create(c *gin.Context) {
 
 }
 
+// createExclusive creates vfsPath if it does not exist. If the filesystem cannot open files with os.O_EXCL,
+// the existence is checked with Stat before creating the file.
+// Atomic creation replaces the file on Close, so it is always checked with Stat.
+func (ctrl *mainController) createExclusive(ctx context.Context, fsys fs.FS, vfsPath string, atomic bool) (writefs.FileWrite, error) {
+	if !atomic {
+		fp, err := writefs.OpenFileContext(ctx, fsys, vfsPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if !errors.Is(err, writefs.ErrNotImplemented) {
+			return fp, errors.WithStack(err)
+		}
+	}
+	if _, err := writefs.StatContext(ctx, fsys, vfsPath); !errors.Is(err, fs.ErrNotExist) {
+		if err == nil {
+			err = fs.ErrExist
+		}
+		return nil, errors.Wrapf(err, "cannot check '%s'", vfsPath)
+	}
+	if atomic {
+		return writefs.CreateAtomicContext(ctx, fsys, vfsPath)
+	}
+	return writefs.CreateContext(ctx, fsys, vfsPath)
+}
+
+// errorStatus maps err to the http status of the response
+func (ctrl *mainController) errorStatus(err error) int {
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return http.StatusNotFound
+	case errors.Is(err, fs.ErrExist):
+		return http.StatusConflict
+	case errors.Is(err, writefs.ErrQuotaExceeded):
+		return http.StatusInsufficientStorage
+	case writefs.IsTransient(err):
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
+
+// rename renames path to the path in the query parameter rename within the same vfs
+func (ctrl *mainController) rename(c *gin.Context) {
+	vfs := c.Param("vfs")
+	path := strings.Trim(c.Param("path"), "/")
+	newPath := strings.Trim(c.Query("rename"), "/")
+	if newPath == "" {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": "no rename target",
+		})
+		return
+	}
+
+	vfsPath := fmt.Sprintf("vfs://%s/%s", vfs, path)
+	newVFSPath := fmt.Sprintf("vfs://%s/%s", vfs, newPath)
+	ctrl.logger.Debug().Str("vfsPath", vfsPath).Str("newPath", newVFSPath).Msg("rename")
+	if err := writefs.RenameContext(ctrl.requestContext(c), ctrl.fsys(c), vfsPath, newVFSPath); err != nil {
+		ctrl.logger.Error().Err(err).Msgf("cannot rename '%s' to '%s'", vfsPath, newVFSPath)
+		c.AbortWithStatusJSON(ctrl.errorStatus(err), gin.H{
+			"error": fmt.Sprintf("cannot rename '%s' to '%s': %v", vfsPath, newVFSPath, err),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"path":    vfsPath,
+		"renamed": newVFSPath,
+	})
+}
+
 func (ctrl *mainController) delete(c *gin.Context) {
 	vfs := c.Param("vfs")
 	path := strings.Trim(c.Param("path"), "/")
 
 	vfsPath := fmt.Sprintf("vfs://%s/%s", vfs, path)
 	ctrl.logger.Debug().Str("vfsPath", vfsPath).Msg("delete")
-	if err := writefs.Remove(ctrl.vfs, vfsPath); err != nil {
+	if err := writefs.RemoveContext(ctrl.requestContext(c), ctrl.fsys(c), vfsPath); err != nil {
 		ctrl.logger.Error().Err(err).Msgf("cannot remove '%s'", vfsPath)
-		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+		if errors.Is(err, fs.ErrNotExist) {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+				"error": fmt.Sprintf("'%s' does not exist", vfsPath),
+			})
+			return
+		}
+		status := http.StatusInternalServerError
+		if writefs.IsTransient(err) {
+			status = http.StatusServiceUnavailable
+		}
+		c.AbortWithStatusJSON(status, gin.H{
 			"error": fmt.Sprintf("cannot remove '%s': %v", vfsPath, err),
 		})
 		return
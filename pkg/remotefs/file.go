@@ -1,7 +1,9 @@
 package remotefs
 
 import (
+	"context"
 	"emperror.dev/errors"
+	"github.com/je4/filesystem/v3/pkg/writefs"
 	"io"
 	"io/fs"
 	"time"
@@ -26,26 +28,65 @@ func (f *file) Stat() (info fs.FileInfo, err error) {
 }
 
 type fileWrite struct {
-	d    *remoteFSRW
-	name string
-	wc   io.WriteCloser
-	done chan error
+	d *remoteFSRW
+	// ctx is the context of the caller, which is not cancelled by Abort
+	ctx    context.Context
+	name   string
+	wc     *io.PipeWriter
+	done   chan error
+	cancel context.CancelCauseFunc
 }
 
 func (f *fileWrite) Write(p []byte) (n int, err error) {
 	return f.wc.Write(p)
 }
 
+// Close finishes the upload and waits for the server to commit it until the context of the caller is done
+// or the close timeout of the filesystem is reached. A timeout does not cancel the request, so that a slow
+// commit on the server side still completes.
 func (f *fileWrite) Close() error {
 	if err := f.wc.Close(); err != nil {
+		f.cancel(nil)
 		return err
 	}
+	timer := time.NewTimer(f.d.closeTimeout)
+	defer timer.Stop()
 	select {
 	case err := <-f.done:
+		f.cancel(nil)
 		return err
-	case <-time.After(3 * time.Second):
-		return errors.New("timeout")
+	case <-f.ctx.Done():
+		return errors.Wrapf(context.Cause(f.ctx), "cannot close upload of '%s'", f.name)
+	case <-timer.C:
+		// release the request context when the request is finished
+		go func() {
+			<-f.done
+			f.cancel(nil)
+		}()
+		return errors.Errorf("timeout after %v waiting for commit of upload '%s'", f.d.closeTimeout, f.name)
+	}
+}
+
+// Abort cancels the upload request, so that the server discards the upload.
+// It waits for the end of the request until the context of the caller is done or the abort timeout of the filesystem is reached.
+func (f *fileWrite) Abort() error {
+	f.cancel(writefs.ErrAborted)
+	f.wc.CloseWithError(writefs.ErrAborted)
+	timer := time.NewTimer(f.d.abortTimeout)
+	defer timer.Stop()
+	select {
+	case err := <-f.done:
+		if err == nil {
+			return errors.Errorf("upload of '%s' completed before abort", f.name)
+		}
+		return nil
+	case <-f.ctx.Done():
+		return errors.Wrapf(context.Cause(f.ctx), "cannot abort upload of '%s'", f.name)
+	case <-timer.C:
+		return errors.Errorf("timeout after %v waiting for abort of upload '%s'", f.d.abortTimeout, f.name)
 	}
 }
 
 var _ fs.File = (*file)(nil)
+
+var _ writefs.AbortFileWrite = (*fileWrite)(nil)
@@ -5,6 +5,17 @@ import (
 	"time"
 )
 
+// newFileInfo converts info for the json response of the server
+func newFileInfo(info fs.FileInfo) fileInfo {
+	return fileInfo{
+		Name_:    info.Name(),
+		Size_:    info.Size(),
+		Mode_:    info.Mode(),
+		ModTime_: info.ModTime().Format(time.RFC3339),
+		IsDir_:   info.IsDir(),
+	}
+}
+
 type fileInfo struct {
 	Name_    string      `json:"name"`
 	Size_    int64       `json:"size"`
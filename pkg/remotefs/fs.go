@@ -1,49 +1,83 @@
 package remotefs
 
 import (
+	"context"
 	"crypto/tls"
 	"emperror.dev/errors"
 	"encoding/json"
 	"fmt"
+	"github.com/je4/filesystem/v3/pkg/tracefs"
 	"github.com/je4/filesystem/v3/pkg/writefs"
 	"github.com/je4/utils/v2/pkg/zLogger"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 	"io"
 	"io/fs"
 	"net/http"
+	"net/url"
+	"os"
 	"path/filepath"
+	"time"
 )
 
-func NewFS(tlsConfig *tls.Config, addr string, dir, vfs string, closer []io.Closer, logger zLogger.ZLogger) (*remoteFSRW, error) {
+// DefaultAbortTimeout is the time Abort waits for the server to confirm the cancellation of an upload
+const DefaultAbortTimeout = 3 * time.Second
+
+// DefaultCloseTimeout is the time Close waits for the server to commit an upload
+const DefaultCloseTimeout = 3 * time.Second
+
+// NewFS creates a client for the vfs of a remotefs server. abortTimeout limits the time Abort waits for
+// the cancellation of an upload (0: DefaultAbortTimeout), closeTimeout the time Close waits for the
+// commit of an upload (0: DefaultCloseTimeout).
+func NewFS(tlsConfig *tls.Config, addr string, dir, vfs string, abortTimeout, closeTimeout time.Duration, closer []io.Closer, logger zLogger.ZLogger) (*remoteFSRW, error) {
 	_logger := logger.With().Str("class", "remoteFSRW").Logger()
 	logger = &_logger
+	if abortTimeout <= 0 {
+		abortTimeout = DefaultAbortTimeout
+	}
+	if closeTimeout <= 0 {
+		closeTimeout = DefaultCloseTimeout
+	}
 
 	return &remoteFSRW{
 		client: &http.Client{
-			Transport: &http.Transport{
-				TLSClientConfig: tlsConfig,
-			},
+			// the trace context of the requests is sent to the server
+			Transport: otelhttp.NewTransport(
+				&http.Transport{
+					TLSClientConfig: tlsConfig,
+				},
+				otelhttp.WithPropagators(tracefs.Propagator),
+			),
 		},
-		addr:   addr,
-		dir:    dir,
-		vfs:    vfs,
-		close:  closer,
-		logger: logger,
+		addr:         addr,
+		dir:          dir,
+		vfs:          vfs,
+		abortTimeout: abortTimeout,
+		closeTimeout: closeTimeout,
+		close:        closer,
+		logger:       logger,
 	}, nil
 }
 
 type remoteFSRW struct {
-	logger zLogger.ZLogger
-	client *http.Client
-	addr   string
-	vfs    string
-	dir    string
-	close  []io.Closer
+	logger       zLogger.ZLogger
+	client       *http.Client
+	addr         string
+	vfs          string
+	dir          string
+	abortTimeout time.Duration
+	closeTimeout time.Duration
+	close        []io.Closer
 }
 
 func (d *remoteFSRW) Fullpath(name string) (string, error) {
 	return fmt.Sprintf("vfs://%s/%s", d.vfs, filepath.ToSlash(filepath.Join(d.dir, name))), nil
 }
 
+// url returns the server url of name within the folder of d
+func (d *remoteFSRW) url(name string) string {
+	return fmt.Sprintf("%s/%s/%s", d.addr, d.vfs, filepath.ToSlash(filepath.Join(d.dir, name)))
+}
+
 func (d *remoteFSRW) Close() error {
 	var errs = []error{}
 	for _, c := range d.close {
@@ -60,45 +94,120 @@ func (d *remoteFSRW) String() string {
 
 func (d *remoteFSRW) Sub(dir string) (fs.FS, error) {
 	return &remoteFSRW{
-		client: d.client,
-		addr:   d.addr,
-		dir:    filepath.Join(d.dir, dir),
-		vfs:    d.vfs,
-		logger: d.logger,
+		client:       d.client,
+		addr:         d.addr,
+		dir:          filepath.Join(d.dir, dir),
+		vfs:          d.vfs,
+		abortTimeout: d.abortTimeout,
+		closeTimeout: d.closeTimeout,
+		logger:       d.logger,
 	}, nil
 }
 
 func (d *remoteFSRW) Remove(path string) error {
-	req, err := http.NewRequest(http.MethodDelete, fmt.Sprintf("%s/%s/%s", d.addr, d.vfs, path), nil)
+	return d.RemoveContext(context.Background(), path)
+}
+
+func (d *remoteFSRW) RemoveContext(ctx context.Context, path string) error {
+	url := d.url(path)
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, url, nil)
 	if err != nil {
-		return errors.Wrapf(err, "cannot create delete request for '%s/%s/%s'", d.addr, d.vfs, path)
+		return errors.Wrapf(err, "cannot create delete request for '%s'", url)
 	}
 	resp, err := d.client.Do(req)
 	if err != nil {
-		return errors.Wrapf(err, "cannot delete '%s/%s/%s'", d.addr, d.vfs, path)
+		return errors.Wrapf(transient(err), "cannot delete '%s'", url)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return errors.Wrapf(fs.ErrNotExist, "cannot delete '%s'", url)
 	}
 	if resp.StatusCode != http.StatusOK {
-		return errors.Errorf("cannot delete '%s/%s/%s': %d", d.addr, d.vfs, path, resp.StatusCode)
+		return statusError(resp.StatusCode, "cannot delete '%s'", url)
 	}
 	return nil
 }
 
 func (d *remoteFSRW) Rename(oldPath, newPath string) error {
-	return errors.Errorf("rename not supported for remoteFSRW")
+	return d.RenameContext(context.Background(), oldPath, newPath)
+}
+
+// RenameContext renames oldPath to newPath within the vfs of d
+func (d *remoteFSRW) RenameContext(ctx context.Context, oldPath, newPath string) error {
+	u := d.url(oldPath) + "?rename=" + url.QueryEscape(filepath.ToSlash(filepath.Join(d.dir, newPath)))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, nil)
+	if err != nil {
+		return errors.Wrapf(err, "cannot create rename request for '%s'", u)
+	}
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return errors.Wrapf(transient(err), "cannot rename '%s'", u)
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return nil
+	case http.StatusNotFound:
+		return errors.Wrapf(fs.ErrNotExist, "cannot rename '%s'", u)
+	case http.StatusConflict:
+		return errors.Wrapf(fs.ErrExist, "cannot rename '%s'", u)
+	}
+	return statusError(resp.StatusCode, "cannot rename '%s'", u)
+}
+
+func (d *remoteFSRW) ReadDir(name string) ([]fs.DirEntry, error) {
+	return d.ReadDirContext(context.Background(), name)
+}
+
+func (d *remoteFSRW) ReadDirContext(ctx context.Context, name string) ([]fs.DirEntry, error) {
+	u := d.url(name) + "?readdir"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot create readdir request for '%s'", u)
+	}
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return nil, errors.Wrapf(transient(err), "cannot read directory '%s'", u)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, errors.Wrapf(fs.ErrNotExist, "cannot read directory '%s'", u)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, statusError(resp.StatusCode, "cannot read directory '%s'", u)
+	}
+	infos := []*fileInfo{}
+	if err := json.NewDecoder(resp.Body).Decode(&infos); err != nil {
+		return nil, errors.Wrapf(err, "cannot decode directory '%s'", u)
+	}
+	entries := make([]fs.DirEntry, 0, len(infos))
+	for _, info := range infos {
+		entries = append(entries, fs.FileInfoToDirEntry(info))
+	}
+	return entries, nil
 }
 
 func (d *remoteFSRW) Open(name string) (fs.File, error) {
-	url := fmt.Sprintf("%s/%s/%s", d.addr, d.vfs, name)
-	req, err := http.NewRequest(http.MethodGet, url, nil)
+	return d.OpenContext(context.Background(), name)
+}
+
+// OpenContext opens name. The download is aborted if ctx is cancelled.
+func (d *remoteFSRW) OpenContext(ctx context.Context, name string) (fs.File, error) {
+	url := d.url(name)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return nil, errors.Wrapf(err, "cannot create stat request for '%s'", url)
 	}
 	resp, err := d.client.Do(req)
 	if err != nil {
-		return nil, errors.Wrapf(err, "cannot stat '%s'", url)
+		return nil, errors.Wrapf(transient(err), "cannot open '%s'", url)
 	}
 	if resp.StatusCode != http.StatusOK {
-		return nil, errors.Errorf("cannot stat '%s': %d", url, resp.StatusCode)
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusNotFound {
+			return nil, errors.Wrapf(fs.ErrNotExist, "cannot open '%s'", url)
+		}
+		return nil, statusError(resp.StatusCode, "cannot open '%s'", url)
 	}
 	return &file{
 		d:    d,
@@ -108,18 +217,25 @@ func (d *remoteFSRW) Open(name string) (fs.File, error) {
 }
 
 func (d *remoteFSRW) Stat(name string) (fs.FileInfo, error) {
-	url := fmt.Sprintf("%s/%s/%s?stat", d.addr, d.vfs, name)
-	req, err := http.NewRequest(http.MethodGet, url, nil)
+	return d.StatContext(context.Background(), name)
+}
+
+func (d *remoteFSRW) StatContext(ctx context.Context, name string) (fs.FileInfo, error) {
+	url := d.url(name) + "?stat"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return nil, errors.Wrapf(err, "cannot create stat request for '%s'", url)
 	}
 	resp, err := d.client.Do(req)
 	if err != nil {
-		return nil, errors.Wrapf(err, "cannot stat '%s'", url)
+		return nil, errors.Wrapf(transient(err), "cannot stat '%s'", url)
 	}
 	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, errors.Wrapf(fs.ErrNotExist, "cannot stat '%s'", url)
+	}
 	if resp.StatusCode != http.StatusOK {
-		return nil, errors.Errorf("cannot stat '%s': %d", url, resp.StatusCode)
+		return nil, statusError(resp.StatusCode, "cannot stat '%s'", url)
 	}
 	fi := &fileInfo{}
 	if err := json.NewDecoder(resp.Body).Decode(fi); err != nil {
@@ -129,32 +245,96 @@ func (d *remoteFSRW) Stat(name string) (fs.FileInfo, error) {
 }
 
 func (d *remoteFSRW) Create(path string) (writefs.FileWrite, error) {
-	url := fmt.Sprintf("%s/%s/%s", d.addr, d.vfs, path)
+	return d.CreateContext(context.Background(), path)
+}
+
+// CreateContext uploads path. The upload is aborted if ctx is cancelled.
+func (d *remoteFSRW) CreateContext(ctx context.Context, path string) (writefs.FileWrite, error) {
+	return d.create(ctx, d.url(path), path)
+}
+
+func (d *remoteFSRW) OpenFile(path string, flag int, perm fs.FileMode) (writefs.FileWrite, error) {
+	return d.OpenFileContext(context.Background(), path, flag, perm)
+}
+
+// OpenFileContext supports exclusive creation only (os.O_WRONLY|os.O_CREATE|os.O_EXCL),
+// because the server does not overwrite existing files. perm is ignored.
+func (d *remoteFSRW) OpenFileContext(ctx context.Context, path string, flag int, perm fs.FileMode) (writefs.FileWrite, error) {
+	if flag&^os.O_TRUNC != os.O_WRONLY|os.O_CREATE|os.O_EXCL {
+		return nil, errors.Wrapf(writefs.ErrNotImplemented, "OpenFile '%s' with flags %#x", path, flag)
+	}
+	return d.CreateContext(ctx, path)
+}
+
+// CreateAtomic uploads path. The server publishes the file only after the upload is complete.
+func (d *remoteFSRW) CreateAtomic(path string) (writefs.FileWrite, error) {
+	return d.create(context.Background(), d.url(path)+"?atomic", path)
+}
+
+func (d *remoteFSRW) create(ctx context.Context, url, path string) (writefs.FileWrite, error) {
+	reqCtx, cancel := context.WithCancelCause(ctx)
 	pr, pw := io.Pipe()
-	req, err := http.NewRequest(http.MethodPut, url, pr)
+	req, err := http.NewRequestWithContext(reqCtx, http.MethodPut, url, pr)
 	if err != nil {
+		cancel(err)
 		return nil, errors.Wrapf(err, "cannot create create request for '%s'", url)
 	}
-	done := make(chan error)
+	done := make(chan error, 1)
 	go func() {
 		resp, err := d.client.Do(req)
 		if err != nil {
+			pr.CloseWithError(err)
 			done <- errors.Wrapf(err, "cannot create '%s'", url)
+			return
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode == http.StatusConflict {
+			pr.CloseWithError(fs.ErrExist)
+			done <- errors.Wrapf(fs.ErrExist, "cannot create '%s'", url)
+			return
+		}
+		if resp.StatusCode == http.StatusInsufficientStorage {
+			pr.CloseWithError(writefs.ErrQuotaExceeded)
+			done <- errors.Wrapf(writefs.ErrQuotaExceeded, "cannot create '%s'", url)
+			return
 		}
 		if resp.StatusCode != http.StatusOK {
+			pr.CloseWithError(errors.Errorf("status %d", resp.StatusCode))
 			done <- errors.Errorf("cannot create '%s': %d", url, resp.StatusCode)
+			return
 		}
 		done <- nil
 	}()
 	result := &fileWrite{
-		d:    d,
-		name: path,
-		wc:   pw,
-		done: done,
+		d:      d,
+		ctx:    ctx,
+		name:   path,
+		wc:     pw,
+		done:   done,
+		cancel: cancel,
 	}
 	return result, nil
 }
 
+// transient marks broken connections and timeouts as transient
+func transient(err error) error {
+	if writefs.IsNetworkFailure(err) {
+		return writefs.Transient(err)
+	}
+	return err
+}
+
+// statusError returns the error of an unexpected response status.
+// Unavailable servers, gateway failures and throttling are transient
+func statusError(status int, format string, args ...any) error {
+	err := errors.Errorf("%s: %d", fmt.Sprintf(format, args...), status)
+	switch status {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout, http.StatusTooManyRequests:
+		return writefs.Transient(err)
+	}
+	return err
+}
+
 func (d *remoteFSRW) ReadFile(name string) ([]byte, error) {
 	fp, err := d.Open(name)
 	if err != nil {
@@ -165,14 +345,24 @@ func (d *remoteFSRW) ReadFile(name string) ([]byte, error) {
 }
 
 var (
-	_ writefs.CreateFS    = &remoteFSRW{}
-	_ writefs.ReadWriteFS = &remoteFSRW{}
+	_ writefs.CreateFS       = &remoteFSRW{}
+	_ writefs.CreateAtomicFS = &remoteFSRW{}
+	_ writefs.OpenFileFS     = &remoteFSRW{}
+	_ writefs.ReadWriteFS    = &remoteFSRW{}
 	//_ writefs.MkDirFS     = &remoteFSRW{}
 	_ writefs.RenameFS   = &remoteFSRW{}
 	_ writefs.RemoveFS   = &remoteFSRW{}
 	_ writefs.FullpathFS = &remoteFSRW{}
-	//_ fs.ReadDirFS        = &remoteFSRW{}
-	_ fs.ReadFileFS = &remoteFSRW{}
-	_ fs.StatFS     = &remoteFSRW{}
-	_ fs.SubFS      = &remoteFSRW{}
+	_ fs.ReadDirFS       = &remoteFSRW{}
+	_ fs.ReadFileFS      = &remoteFSRW{}
+	_ fs.StatFS          = &remoteFSRW{}
+	_ fs.SubFS           = &remoteFSRW{}
+
+	_ writefs.CreateContextFS   = &remoteFSRW{}
+	_ writefs.OpenFileContextFS = &remoteFSRW{}
+	_ writefs.OpenContextFS     = &remoteFSRW{}
+	_ writefs.StatContextFS     = &remoteFSRW{}
+	_ writefs.RemoveContextFS   = &remoteFSRW{}
+	_ writefs.RenameContextFS   = &remoteFSRW{}
+	_ writefs.ReadDirContextFS  = &remoteFSRW{}
 )
@@ -0,0 +1,130 @@
+package remotefs_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"emperror.dev/errors"
+	"github.com/je4/filesystem/v3/pkg/quotafs"
+	"github.com/je4/filesystem/v3/pkg/remotefs"
+	"github.com/je4/filesystem/v3/pkg/tracefs"
+	"github.com/je4/filesystem/v3/pkg/vfsrw"
+	"github.com/je4/filesystem/v3/pkg/writefs"
+	"github.com/je4/filesystem/v3/pkg/writefs/writefstest"
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace/noop"
+	"io/fs"
+	"math/big"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+)
+
+// clientCertificate creates a self-signed client certificate with access to vfs://test and vfs://quota
+func clientCertificate(t *testing.T) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "remotefs test client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		URIs:         []*url.URL{{Scheme: "vfs", Host: "test"}, {Scheme: "vfs", Host: "quota"}},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func TestRemoteFS(t *testing.T) {
+	logger := zerolog.New(os.Stderr).With().Timestamp().Logger()
+	vfs, err := vfsrw.NewFS(vfsrw.Config{
+		"test": &vfsrw.VFS{
+			Name: "test",
+			Type: "os",
+			OS:   &vfsrw.OS{BaseDir: t.TempDir()},
+		},
+		"quota": &vfsrw.VFS{
+			Name:  "quota",
+			Type:  "os",
+			OS:    &vfsrw.OS{BaseDir: t.TempDir()},
+			Quota: &vfsrw.Quota{MaxBytes: 10},
+		},
+	}, &logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer vfs.Close()
+
+	ctrl, err := remotefs.NewMainController("", "https://localhost", "", nil, nil, nil, vfs, nil, &logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewUnstartedServer(ctrl.Handler())
+	srv.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	srv.StartTLS()
+	defer srv.Close()
+
+	rootCAs := x509.NewCertPool()
+	rootCAs.AddCert(srv.Certificate())
+	clientTLS := &tls.Config{
+		Certificates: []tls.Certificate{clientCertificate(t)},
+		RootCAs:      rootCAs,
+	}
+	rFS, err := remotefs.NewFS(clientTLS, srv.URL, "", "test", 0, 0, nil, &logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rFS.Close()
+
+	writefstest.TestReadWriteFS(t, rFS, nil)
+
+	t.Run("quota", func(t *testing.T) {
+		quotaFS, err := remotefs.NewFS(clientTLS, srv.URL, "", "quota", 0, 0, nil, &logger)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := writefs.WriteFile(quotaFS, "large.txt", []byte("more than ten bytes")); !errors.Is(err, quotafs.ErrQuotaExceeded) {
+			t.Errorf("expected ErrQuotaExceeded, got %v", err)
+		}
+	})
+
+	t.Run("trace", func(t *testing.T) {
+		provider, exporter := tracefs.NewInMemoryTracerProvider()
+		otel.SetTracerProvider(provider)
+		defer otel.SetTracerProvider(noop.NewTracerProvider())
+
+		ctx, span := provider.Tracer("test").Start(context.Background(), "test")
+		if _, err := writefs.StatContext(ctx, rFS, "missing.txt"); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("expected fs.ErrNotExist, got %v", err)
+		}
+		span.End()
+
+		// client request, server request and stat of the mount belong to the trace of the test
+		names := map[string]bool{}
+		for _, s := range exporter.GetSpans() {
+			if s.SpanContext.TraceID() == span.SpanContext().TraceID() {
+				names[s.Name] = true
+			}
+		}
+		for _, name := range []string{"HTTP GET", "GET /:vfs/*path", "fs.Stat"} {
+			if !names[name] {
+				t.Errorf("span '%s' not found in %v", name, names)
+			}
+		}
+	})
+}
@@ -0,0 +1,50 @@
+package retryfs
+
+import (
+	"math/rand"
+	"time"
+)
+
+// Backoff configures the delays between the retries
+type Backoff struct {
+	// Retries is the maximum number of retries after the first attempt
+	Retries int
+	// Initial is the delay before the first retry
+	Initial time.Duration
+	// Max limits the delay. 0 means unlimited
+	Max time.Duration
+	// Multiplier increases the delay after every retry. Values below 1 mean 2
+	Multiplier float64
+	// Jitter is the random fraction [0..1] of the delay, which is subtracted to spread the retries of concurrent callers
+	Jitter float64
+}
+
+// DefaultBackoff retries three times after 100ms, 200ms and 400ms with 20% jitter
+var DefaultBackoff = Backoff{
+	Retries:    3,
+	Initial:    100 * time.Millisecond,
+	Max:        5 * time.Second,
+	Multiplier: 2,
+	Jitter:     0.2,
+}
+
+// Delay returns the delay before retry number retry (starting with 0)
+func (b Backoff) Delay(retry int) time.Duration {
+	multiplier := b.Multiplier
+	if multiplier < 1 {
+		multiplier = 2
+	}
+	delay := float64(b.Initial)
+	for i := 0; i < retry; i++ {
+		delay *= multiplier
+		if b.Max > 0 && delay >= float64(b.Max) {
+			break
+		}
+	}
+	if b.Max > 0 && delay > float64(b.Max) {
+		delay = float64(b.Max)
+	}
+	jitter := min(max(b.Jitter, 0), 1)
+	delay -= delay * jitter * rand.Float64()
+	return time.Duration(delay)
+}
@@ -0,0 +1,261 @@
+// Package retryfs retries idempotent operations of a filesystem, which failed with a transient error
+package retryfs
+
+import (
+	"context"
+	"emperror.dev/errors"
+	"fmt"
+	"github.com/je4/filesystem/v3/pkg/writefs"
+	"github.com/je4/utils/v2/pkg/zLogger"
+	"io/fs"
+	"time"
+)
+
+// NewFS wraps base and retries Open, Stat, ReadDir, ReadFile and Remove with exponential backoff,
+// as long as they fail with an error for which writefs.IsTransient is true.
+// Remove succeeds if the file is missing after a failed attempt, because the attempt may have removed it.
+// All other operations are passed through once.
+func NewFS(base fs.FS, backoff Backoff, logger zLogger.ZLogger) (*retryFS, error) {
+	_logger := logger.With().Str("class", "retryFS").Logger()
+	logger = &_logger
+	if backoff.Retries < 0 {
+		return nil, errors.Errorf("invalid number of retries %d", backoff.Retries)
+	}
+	return &retryFS{
+		base:    base,
+		backoff: backoff,
+		logger:  logger,
+	}, nil
+}
+
+type retryFS struct {
+	base    fs.FS
+	backoff Backoff
+	logger  zLogger.ZLogger
+}
+
+// retry calls fn until it succeeds, fails with a permanent error, the retries are exhausted or ctx is done
+func (r *retryFS) retry(ctx context.Context, op, name string, fn func() error) error {
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		if err == nil || !writefs.IsTransient(err) || attempt >= r.backoff.Retries {
+			return err
+		}
+		delay := r.backoff.Delay(attempt)
+		r.logger.Debug().Err(err).Msgf("%s '%s' failed, retry %d/%d in %v", op, name, attempt+1, r.backoff.Retries, delay)
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return errors.Wrapf(ctx.Err(), "%s '%s' cancelled after %d attempts: %v", op, name, attempt+1, err)
+		case <-timer.C:
+		}
+	}
+}
+
+func (r *retryFS) String() string {
+	return fmt.Sprintf("retryFS(%v)", r.base)
+}
+
+func (r *retryFS) Sub(dir string) (fs.FS, error) {
+	return writefs.NewSubFS(r, dir), nil
+}
+
+func (r *retryFS) Open(name string) (fs.File, error) {
+	return r.OpenContext(context.Background(), name)
+}
+
+func (r *retryFS) OpenContext(ctx context.Context, name string) (fs.File, error) {
+	var fp fs.File
+	err := r.retry(ctx, "Open", name, func() (err error) {
+		fp, err = writefs.OpenContext(ctx, r.base, name)
+		return err
+	})
+	return fp, err
+}
+
+func (r *retryFS) ReadFile(name string) ([]byte, error) {
+	var data []byte
+	err := r.retry(context.Background(), "ReadFile", name, func() (err error) {
+		data, err = fs.ReadFile(r.base, name)
+		return err
+	})
+	return data, err
+}
+
+func (r *retryFS) Stat(name string) (fs.FileInfo, error) {
+	return r.StatContext(context.Background(), name)
+}
+
+func (r *retryFS) StatContext(ctx context.Context, name string) (fs.FileInfo, error) {
+	var info fs.FileInfo
+	err := r.retry(ctx, "Stat", name, func() (err error) {
+		info, err = writefs.StatContext(ctx, r.base, name)
+		return err
+	})
+	return info, err
+}
+
+func (r *retryFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	return r.ReadDirContext(context.Background(), name)
+}
+
+func (r *retryFS) ReadDirContext(ctx context.Context, name string) ([]fs.DirEntry, error) {
+	var entries []fs.DirEntry
+	err := r.retry(ctx, "ReadDir", name, func() (err error) {
+		entries, err = writefs.ReadDirContext(ctx, r.base, name)
+		return err
+	})
+	return entries, err
+}
+
+func (r *retryFS) Remove(name string) error {
+	return r.RemoveContext(context.Background(), name)
+}
+
+// RemoveContext treats a missing file after a transient failure as removed
+func (r *retryFS) RemoveContext(ctx context.Context, name string) error {
+	var failed bool
+	return r.retry(ctx, "Remove", name, func() error {
+		err := writefs.RemoveContext(ctx, r.base, name)
+		if failed && errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		failed = err != nil
+		return err
+	})
+}
+
+// the following operations are not idempotent and passed through without retries
+
+func (r *retryFS) Create(name string) (writefs.FileWrite, error) {
+	return writefs.Create(r.base, name)
+}
+
+func (r *retryFS) CreateContext(ctx context.Context, name string) (writefs.FileWrite, error) {
+	return writefs.CreateContext(ctx, r.base, name)
+}
+
+func (r *retryFS) CreateAtomic(name string) (writefs.FileWrite, error) {
+	return writefs.CreateAtomic(r.base, name)
+}
+
+func (r *retryFS) CreateAtomicContext(ctx context.Context, name string) (writefs.FileWrite, error) {
+	return writefs.CreateAtomicContext(ctx, r.base, name)
+}
+
+func (r *retryFS) OpenFile(name string, flag int, perm fs.FileMode) (writefs.FileWrite, error) {
+	return writefs.OpenFile(r.base, name, flag, perm)
+}
+
+func (r *retryFS) OpenFileContext(ctx context.Context, name string, flag int, perm fs.FileMode) (writefs.FileWrite, error) {
+	return writefs.OpenFileContext(ctx, r.base, name, flag, perm)
+}
+
+func (r *retryFS) CreateWriterAt(name string) (writefs.FileWriterAt, error) {
+	return writefs.CreateWriterAt(r.base, name)
+}
+
+func (r *retryFS) Rename(oldPath, newPath string) error {
+	return writefs.Rename(r.base, oldPath, newPath)
+}
+
+func (r *retryFS) RenameContext(ctx context.Context, oldPath, newPath string) error {
+	return writefs.RenameContext(ctx, r.base, oldPath, newPath)
+}
+
+func (r *retryFS) MkDir(name string) error {
+	return writefs.MkDir(r.base, name)
+}
+
+func (r *retryFS) MkDirContext(ctx context.Context, name string) error {
+	return writefs.MkDirContext(ctx, r.base, name)
+}
+
+func (r *retryFS) MkDirAll(name string) error {
+	return writefs.MkDirAll(r.base, name)
+}
+
+func (r *retryFS) MkDirAllContext(ctx context.Context, name string) error {
+	return writefs.MkDirAllContext(ctx, r.base, name)
+}
+
+func (r *retryFS) RemoveAll(name string) error {
+	return writefs.RemoveAll(r.base, name)
+}
+
+func (r *retryFS) RemoveAllContext(ctx context.Context, name string) error {
+	return writefs.RemoveAllContext(ctx, r.base, name)
+}
+
+func (r *retryFS) CopyFile(src, dst string) error {
+	cfs, ok := r.base.(writefs.CopyFileFS)
+	if !ok {
+		return errors.Wrap(writefs.ErrNotImplemented, "CopyFile")
+	}
+	return cfs.CopyFile(src, dst)
+}
+
+func (r *retryFS) Chtimes(name string, atime, mtime time.Time) error {
+	return writefs.Chtimes(r.base, name, atime, mtime)
+}
+
+func (r *retryFS) Chmod(name string, mode fs.FileMode) error {
+	return writefs.Chmod(r.base, name, mode)
+}
+
+func (r *retryFS) Symlink(oldname, newname string) error {
+	return writefs.Symlink(r.base, oldname, newname)
+}
+
+func (r *retryFS) ReadLink(name string) (string, error) {
+	return writefs.ReadLink(r.base, name)
+}
+
+func (r *retryFS) Lstat(name string) (fs.FileInfo, error) {
+	return writefs.Lstat(r.base, name)
+}
+
+func (r *retryFS) Fullpath(name string) (string, error) {
+	return writefs.Fullpath(r.base, name)
+}
+
+func (r *retryFS) Close() error {
+	return writefs.Close(r.base)
+}
+
+var (
+	_ writefs.ReadWriteFS           = &retryFS{}
+	_ writefs.CreateContextFS       = &retryFS{}
+	_ writefs.CreateAtomicFS        = &retryFS{}
+	_ writefs.CreateAtomicContextFS = &retryFS{}
+	_ writefs.OpenFileFS            = &retryFS{}
+	_ writefs.OpenFileContextFS     = &retryFS{}
+	_ writefs.OpenContextFS         = &retryFS{}
+	_ writefs.StatContextFS         = &retryFS{}
+	_ writefs.ReadDirContextFS      = &retryFS{}
+	_ writefs.CreateWriterAtFS      = &retryFS{}
+	_ writefs.MkDirFS               = &retryFS{}
+	_ writefs.MkDirAllFS            = &retryFS{}
+	_ writefs.RemoveFS              = &retryFS{}
+	_ writefs.RemoveContextFS       = &retryFS{}
+	_ writefs.RemoveAllFS           = &retryFS{}
+	_ writefs.MkDirContextFS        = &retryFS{}
+	_ writefs.MkDirAllContextFS     = &retryFS{}
+	_ writefs.RemoveAllContextFS    = &retryFS{}
+	_ writefs.RenameFS              = &retryFS{}
+	_ writefs.RenameContextFS       = &retryFS{}
+	_ writefs.CopyFileFS            = &retryFS{}
+	_ writefs.ChtimesFS             = &retryFS{}
+	_ writefs.ChmodFS               = &retryFS{}
+	_ writefs.SymlinkFS             = &retryFS{}
+	_ writefs.ReadLinkFS            = &retryFS{}
+	_ writefs.LstatFS               = &retryFS{}
+	_ writefs.FullpathFS            = &retryFS{}
+	_ writefs.CloseFS               = &retryFS{}
+	_ fs.ReadDirFS                  = &retryFS{}
+	_ fs.ReadFileFS                 = &retryFS{}
+	_ fs.StatFS                     = &retryFS{}
+	_ fs.SubFS                      = &retryFS{}
+	_ fmt.Stringer                  = &retryFS{}
+)
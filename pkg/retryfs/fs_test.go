@@ -0,0 +1,117 @@
+package retryfs
+
+import (
+	"context"
+	"emperror.dev/errors"
+	"github.com/je4/filesystem/v3/pkg/memfsrw"
+	"github.com/je4/filesystem/v3/pkg/writefs"
+	"github.com/rs/zerolog"
+	"io/fs"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// flakyFS fails the first failures calls of every operation with a transient error
+type flakyFS struct {
+	writefs.ReadWriteFS
+	failures int
+	calls    map[string]int
+	// removeFails removes the file before failing, like a lost response
+	removeFails bool
+}
+
+func (f *flakyFS) fail(op string) error {
+	f.calls[op]++
+	if f.calls[op] <= f.failures {
+		return writefs.Transient(errors.Wrapf(syscall.ECONNRESET, "%s", op))
+	}
+	return nil
+}
+
+func (f *flakyFS) Open(name string) (fs.File, error) {
+	if err := f.fail("Open"); err != nil {
+		return nil, err
+	}
+	return f.ReadWriteFS.Open(name)
+}
+
+func (f *flakyFS) Stat(name string) (fs.FileInfo, error) {
+	if err := f.fail("Stat"); err != nil {
+		return nil, err
+	}
+	return fs.Stat(f.ReadWriteFS, name)
+}
+
+func (f *flakyFS) Remove(name string) error {
+	if err := f.fail("Remove"); err != nil {
+		if f.removeFails {
+			writefs.Remove(f.ReadWriteFS, name)
+		}
+		return err
+	}
+	return writefs.Remove(f.ReadWriteFS, name)
+}
+
+func TestRetryFS(t *testing.T) {
+	logger := zerolog.New(os.Stderr).With().Timestamp().Logger()
+	memFS, err := memfsrw.NewFS("retry", &logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := writefs.WriteFile(memFS, "a.txt", []byte("0123456789")); err != nil {
+		t.Fatal(err)
+	}
+	flaky := &flakyFS{ReadWriteFS: memFS, failures: 2, calls: map[string]int{}, removeFails: true}
+	backoff := Backoff{Retries: 2, Initial: time.Millisecond, Max: 2 * time.Millisecond, Jitter: 0.5}
+	retryFS, err := NewFS(flaky, backoff, &logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if data, err := fs.ReadFile(retryFS, "a.txt"); err != nil || string(data) != "0123456789" {
+		t.Fatalf("cannot read 'a.txt': %v", err)
+	}
+	if _, err := fs.Stat(retryFS, "missing.txt"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected fs.ErrNotExist, got %v", err)
+	}
+	if _, err := fs.Stat(retryFS, "missing.txt"); !errors.Is(err, fs.ErrNotExist) || flaky.calls["Stat"] != 4 {
+		t.Errorf("permanent error retried: %d calls, %v", flaky.calls["Stat"], err)
+	}
+	if err := writefs.Remove(retryFS, "a.txt"); err != nil {
+		t.Errorf("missing file after failed remove: %v", err)
+	}
+	if err := writefs.Remove(retryFS, "a.txt"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected fs.ErrNotExist, got %v", err)
+	}
+
+	flaky.failures = 10
+	if _, err := fs.Stat(retryFS, "a.txt"); !writefs.IsTransient(err) {
+		t.Errorf("expected transient error after retries, got %v", err)
+	}
+	if flaky.calls["Stat"] != 7 {
+		t.Errorf("expected 3 attempts, got %d", flaky.calls["Stat"]-4)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := writefs.StatContext(ctx, retryFS, "a.txt"); !errors.Is(err, context.Canceled) || writefs.IsTransient(err) {
+		t.Errorf("expected permanent context.Canceled, got %v", err)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	backoff := Backoff{Initial: 100 * time.Millisecond, Max: time.Second, Multiplier: 3}
+	for retry, expected := range []time.Duration{100 * time.Millisecond, 300 * time.Millisecond, 900 * time.Millisecond, time.Second, time.Second} {
+		if delay := backoff.Delay(retry); delay != expected {
+			t.Errorf("retry %d: expected %v, got %v", retry, expected, delay)
+		}
+	}
+	backoff.Jitter = 1
+	for i := 0; i < 100; i++ {
+		if delay := backoff.Delay(1); delay < 0 || delay > 300*time.Millisecond {
+			t.Fatalf("delay %v out of range", delay)
+		}
+	}
+}
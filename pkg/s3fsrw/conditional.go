@@ -0,0 +1,50 @@
+package s3fsrw
+
+import (
+	"context"
+	"github.com/minio/minio-go/v7"
+	"net/http"
+)
+
+type ifNoneMatchKey struct{}
+
+// withIfNoneMatch marks the requests of ctx as conditional writes, which fail if the object already exists
+func withIfNoneMatch(ctx context.Context) context.Context {
+	return context.WithValue(ctx, ifNoneMatchKey{}, true)
+}
+
+// conditionalRoundTripper adds "If-None-Match: *" to the object writing requests of contexts marked by withIfNoneMatch.
+// minio-go quotes the etag of PutObjectOptions.SetMatchETagExcept, which does not work for the wildcard.
+type conditionalRoundTripper struct {
+	http.RoundTripper
+}
+
+func (rt *conditionalRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Context().Value(ifNoneMatchKey{}) != nil && isObjectWrite(req) {
+		req = req.Clone(req.Context())
+		req.Header.Set("If-None-Match", "*")
+	}
+	return rt.RoundTripper.RoundTrip(req)
+}
+
+// isObjectWrite checks for PutObject and CompleteMultipartUpload, the only requests supporting "If-None-Match: *".
+// Part uploads (PUT with uploadId) and other POST requests like CreateMultipartUpload are not conditional.
+func isObjectWrite(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodPut:
+		return !req.URL.Query().Has("uploadId")
+	case http.MethodPost:
+		return req.URL.Query().Has("uploadId")
+	default:
+		return false
+	}
+}
+
+// isPreconditionFailed checks whether a conditional write failed because the object exists
+func isPreconditionFailed(err error) bool {
+	errResp, ok := err.(minio.ErrorResponse)
+	if !ok {
+		return false
+	}
+	return errResp.StatusCode == http.StatusPreconditionFailed
+}
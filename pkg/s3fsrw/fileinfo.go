@@ -4,9 +4,16 @@ import (
 	"github.com/minio/minio-go/v7"
 	"io/fs"
 	"path/filepath"
+	"strconv"
 	"time"
 )
 
+// user metadata keys for attributes set with Chtimes and Chmod
+const (
+	metaModTime = "Mtime"
+	metaMode    = "Mode"
+)
+
 func NewFileInfo(o *minio.ObjectInfo) fs.FileInfo {
 	return &fileInfo{o}
 }
@@ -23,15 +30,26 @@ func (s3fi fileInfo) Name() string {
 	return filepath.Base(s3fi.Key)
 }
 
+// ETag returns the entity tag of the object
+func (s3fi fileInfo) ETag() string {
+	return s3fi.ObjectInfo.ETag
+}
+
 func (s3fi fileInfo) Size() int64 {
 	return s3fi.ObjectInfo.Size
 }
 
 func (s3fi fileInfo) Mode() fs.FileMode {
+	if mode, err := strconv.ParseUint(s3fi.UserMetadata[metaMode], 8, 32); err == nil {
+		return fs.FileMode(mode).Perm()
+	}
 	return 0
 }
 
 func (s3fi fileInfo) ModTime() time.Time {
+	if mtime, err := time.Parse(time.RFC3339Nano, s3fi.UserMetadata[metaModTime]); err == nil {
+		return mtime
+	}
 	return s3fi.LastModified
 }
 
@@ -10,10 +10,15 @@ import (
 	"github.com/je4/utils/v2/pkg/zLogger"
 	"github.com/minio/minio-go/v7"
 	"github.com/minio/minio-go/v7/pkg/credentials"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/propagation"
 	"golang.org/x/exp/slices"
 	"io"
 	"io/fs"
 	"net/http"
+	"os"
+	"strconv"
+	"time"
 )
 
 func NewFS(endpoint, accessKeyID, secretAccessKey, region string, useSSL, debug bool, tlsConfig *tls.Config, logger zLogger.ZLogger) (*s3FSRW, error) {
@@ -42,6 +47,14 @@ func NewFS(endpoint, accessKeyID, secretAccessKey, region string, useSSL, debug
 			// ResponseHeaders,
 		)
 	}
+	// child spans for the http requests of minio. The trace context is not sent to s3.
+	tr = otelhttp.NewTransport(
+		&conditionalRoundTripper{tr},
+		otelhttp.WithPropagators(propagation.NewCompositeTextMapPropagator()),
+		otelhttp.WithSpanNameFormatter(func(_ string, req *http.Request) string {
+			return "s3 " + req.Method
+		}),
+	)
 	fs.client, err = minio.New(endpoint, &minio.Options{
 		Creds:     credentials.NewStaticV4(accessKeyID, secretAccessKey, ""),
 		Secure:    useSSL,
@@ -70,15 +83,35 @@ func (s3FS *s3FSRW) MkDir(path string) error {
 	return errors.Wrapf(s3FS.client.MakeBucket(context.Background(), bucket, minio.MakeBucketOptions{Region: s3FS.region}), "cannot create bucket '%s'", bucket)
 }
 
+// MkDirAll creates the bucket if it does not exist. Subfolders do not exist in s3 and are ignored
+func (s3FS *s3FSRW) MkDirAll(path string) error {
+	bucket, _ := extractBucket(path)
+	if bucket == "" {
+		return nil
+	}
+	ctx := context.Background()
+	exists, err := s3FS.client.BucketExists(ctx, bucket)
+	if err != nil {
+		return errors.Wrapf(err, "cannot check bucket '%s'", bucket)
+	}
+	if exists {
+		return nil
+	}
+	return errors.Wrapf(s3FS.client.MakeBucket(ctx, bucket, minio.MakeBucketOptions{Region: s3FS.region}), "cannot create bucket '%s'", bucket)
+}
+
 func (s3FS *s3FSRW) Open(path string) (fs.File, error) {
+	return s3FS.OpenContext(context.Background(), path)
+}
+
+func (s3FS *s3FSRW) OpenContext(ctx context.Context, path string) (fs.File, error) {
 	bucket, bucketPath := extractBucket(path)
 	if s3FS.logger != nil {
 		s3FS.logger.Debugf("%s - Open(%s)", s3FS.String(), path)
 	}
-	ctx := context.Background()
 	object, err := s3FS.client.GetObject(ctx, bucket, bucketPath, minio.GetObjectOptions{})
 	if err != nil {
-		return nil, errors.Wrapf(err, "cannot open '%s/%s/%s'", s3FS.client.EndpointURL(), bucket, path)
+		return nil, errors.Wrapf(s3FS.transient(err), "cannot open '%s/%s/%s'", s3FS.client.EndpointURL(), bucket, path)
 	}
 	objectInfo, err := object.Stat()
 	if err != nil {
@@ -86,11 +119,11 @@ func (s3FS *s3FSRW) Open(path string) (fs.File, error) {
 		if s3FS.IsNotExist(err) {
 			return nil, fs.ErrNotExist
 		}
-		return nil, errors.Wrapf(err, "cannot stat '%s'", path)
+		return nil, errors.Wrapf(s3FS.transient(err), "cannot stat '%s'", path)
 	}
 	if objectInfo.Err != nil {
 		object.Close()
-		return nil, errors.Wrapf(objectInfo.Err, "error in objectInfo of '%s'", path)
+		return nil, errors.Wrapf(s3FS.transient(objectInfo.Err), "error in objectInfo of '%s'", path)
 	}
 	return NewROFile(object, path, s3FS.logger), nil
 }
@@ -112,14 +145,18 @@ func (s3FS *s3FSRW) ReadFile(path string) ([]byte, error) {
 }
 
 func (s3FS *s3FSRW) ReadDir(path string) ([]fs.DirEntry, error) {
+	return s3FS.ReadDirContext(context.Background(), path)
+}
+
+func (s3FS *s3FSRW) ReadDirContext(ctx context.Context, path string) ([]fs.DirEntry, error) {
 	bucket, bucketPath := extractBucket(path)
 	if s3FS.logger != nil {
 		s3FS.logger.Debugf("%s - ReadDir(%s)", s3FS.String(), path)
 	}
 	if bucket == "" {
-		bucketInfo, err := s3FS.client.ListBuckets(context.Background())
+		bucketInfo, err := s3FS.client.ListBuckets(ctx)
 		if err != nil {
-			return nil, errors.Wrapf(err, "cannot list buckets")
+			return nil, errors.Wrapf(s3FS.transient(err), "cannot list buckets")
 		}
 		result := []fs.DirEntry{}
 		for _, bi := range bucketInfo {
@@ -127,12 +164,14 @@ func (s3FS *s3FSRW) ReadDir(path string) ([]fs.DirEntry, error) {
 		}
 		return result, nil
 	}
-	ctx := context.Background()
 	result := []fs.DirEntry{}
-
+	// list the content of the folder, not the folder itself
+	if bucketPath != "" {
+		bucketPath += "/"
+	}
 	for objectInfo := range s3FS.client.ListObjects(ctx, bucket, minio.ListObjectsOptions{Prefix: bucketPath}) {
 		if objectInfo.Err != nil {
-			return nil, errors.Wrapf(objectInfo.Err, "cannot read '%s'", path)
+			return nil, errors.Wrapf(s3FS.transient(objectInfo.Err), "cannot read '%s'", path)
 		}
 		oiHelper := objectInfo
 		result = append(result, writefs.NewDirEntry(NewFileInfo(&oiHelper)))
@@ -141,34 +180,148 @@ func (s3FS *s3FSRW) ReadDir(path string) ([]fs.DirEntry, error) {
 }
 
 func (s3FS *s3FSRW) Create(path string) (writefs.FileWrite, error) {
-	bucket, bucketPath := extractBucket(path)
+	return s3FS.CreateContext(context.Background(), path)
+}
+
+// CreateContext starts the upload of path. Cancelling ctx aborts the upload.
+func (s3FS *s3FSRW) CreateContext(ctx context.Context, path string) (writefs.FileWrite, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, errors.WithStack(err)
+	}
 	if s3FS.logger != nil {
 		s3FS.logger.Debugf("%s - Create(%s)", s3FS.String(), path)
 	}
-	ctx := context.Background()
+	return s3FS.put(ctx, path, minio.PutObjectOptions{}), nil
+}
+
+func (s3FS *s3FSRW) put(ctx context.Context, path string, opts minio.PutObjectOptions) *rwCloser {
+	bucket, bucketPath := extractBucket(path)
 	wc := NewWriteCloser(path, s3FS.logger)
 	go func() {
-		ui, err := s3FS.client.PutObject(ctx, bucket, bucketPath, wc.GetReader(), -1, minio.PutObjectOptions{})
+		ui, err := s3FS.client.PutObject(ctx, bucket, bucketPath, wc.GetReader(), -1, opts)
+		if isPreconditionFailed(err) {
+			err = errors.Wrapf(fs.ErrExist, "'%s' already exists", path)
+		}
 		uierr := NewUploadInfo(&ui, err)
 		wc.c <- uierr
 		if err != nil {
 			wc.Close()
 		}
 	}()
-	return wc, nil
+	return wc
+}
+
+func (s3FS *s3FSRW) OpenFile(path string, flag int, perm fs.FileMode) (writefs.FileWrite, error) {
+	return s3FS.OpenFileContext(context.Background(), path, flag, perm)
+}
+
+// OpenFileContext supports the creation of objects only (os.O_WRONLY|os.O_CREATE with os.O_TRUNC or os.O_EXCL).
+// os.O_EXCL uses a conditional upload, so an existing object is reported by Write or Close.
+// perm is stored as user metadata.
+func (s3FS *s3FSRW) OpenFileContext(ctx context.Context, path string, flag int, perm fs.FileMode) (writefs.FileWrite, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	switch flag {
+	case os.O_WRONLY | os.O_CREATE | os.O_TRUNC:
+	case os.O_WRONLY | os.O_CREATE | os.O_EXCL, os.O_WRONLY | os.O_CREATE | os.O_EXCL | os.O_TRUNC:
+		ctx = withIfNoneMatch(ctx)
+	default:
+		return nil, errors.Wrapf(writefs.ErrNotImplemented, "OpenFile '%s' with flags %#x", path, flag)
+	}
+	if s3FS.logger != nil {
+		s3FS.logger.Debugf("%s - OpenFile(%s, %#x)", s3FS.String(), path, flag)
+	}
+	opts := minio.PutObjectOptions{}
+	if perm != 0 {
+		opts.UserMetadata = map[string]string{metaMode: strconv.FormatUint(uint64(perm.Perm()), 8)}
+	}
+	return s3FS.put(ctx, path, opts), nil
+}
+
+// CreateAtomic starts the upload of path.
+// S3 objects become visible only after the (multipart) upload is completed on Close.
+func (s3FS *s3FSRW) CreateAtomic(path string) (writefs.FileWrite, error) {
+	return s3FS.CreateContext(context.Background(), path)
 }
 
 func (s3FS *s3FSRW) Remove(path string) error {
+	return s3FS.RemoveContext(context.Background(), path)
+}
+
+func (s3FS *s3FSRW) RemoveContext(ctx context.Context, path string) error {
 	bucket, bucketPath := extractBucket(path)
 	if s3FS.logger != nil {
 		s3FS.logger.Debugf("%s - Delete(%s)", s3FS.String(), path)
 	}
-	ctx := context.Background()
+	// RemoveObject succeeds for missing objects, but Remove has to return fs.ErrNotExist like all writefs filesystems.
+	// An object, which is removed concurrently after the stat, is still reported as removed
+	if _, err := s3FS.client.StatObject(ctx, bucket, bucketPath, minio.StatObjectOptions{}); err != nil {
+		if s3FS.IsNotExist(err) {
+			return fs.ErrNotExist
+		}
+		return errors.Wrapf(s3FS.transient(err), "cannot stat '%s'", path)
+	}
 	if err := s3FS.client.RemoveObject(ctx, bucket, bucketPath, minio.RemoveObjectOptions{}); err != nil {
 		if s3FS.IsNotExist(err) {
 			return fs.ErrNotExist
 		}
-		return errors.Wrapf(err, "cannot remove '%s'", path)
+		return errors.Wrapf(s3FS.transient(err), "cannot remove '%s'", path)
+	}
+	return nil
+}
+
+// RemoveAll removes all objects with the prefix path via batch deletion.
+// If path is a bucket, the bucket is removed as well
+func (s3FS *s3FSRW) RemoveAll(path string) error {
+	bucket, bucketPath := extractBucket(path)
+	if s3FS.logger != nil {
+		s3FS.logger.Debugf("%s - RemoveAll(%s)", s3FS.String(), path)
+	}
+	if bucket == "" {
+		return errors.Wrap(fs.ErrInvalid, "cannot remove all buckets")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	objectsCh := make(chan minio.ObjectInfo)
+	var listErr error
+	go func() {
+		defer close(objectsCh)
+		if bucketPath != "" {
+			// the object itself
+			objectsCh <- minio.ObjectInfo{Key: bucketPath}
+		}
+		prefix := ""
+		if bucketPath != "" {
+			prefix = bucketPath + "/"
+		}
+		for objectInfo := range s3FS.client.ListObjects(ctx, bucket, minio.ListObjectsOptions{Prefix: prefix, Recursive: true}) {
+			if objectInfo.Err != nil {
+				if !s3FS.IsNotExist(objectInfo.Err) {
+					listErr = errors.Wrapf(objectInfo.Err, "cannot list '%s'", path)
+				}
+				return
+			}
+			objectsCh <- objectInfo
+		}
+	}()
+	var errs = []error{}
+	for rErr := range s3FS.client.RemoveObjects(ctx, bucket, objectsCh, minio.RemoveObjectsOptions{}) {
+		if rErr.Err != nil && !s3FS.IsNotExist(rErr.Err) {
+			errs = append(errs, errors.Wrapf(rErr.Err, "cannot remove '%s/%s'", bucket, rErr.ObjectName))
+		}
+	}
+	if listErr != nil {
+		errs = append(errs, listErr)
+	}
+	if len(errs) > 0 {
+		return errors.Combine(errs...)
+	}
+	if bucketPath == "" {
+		if err := s3FS.client.RemoveBucket(ctx, bucket); err != nil && !s3FS.IsNotExist(err) {
+			return errors.Wrapf(err, "cannot remove bucket '%s'", bucket)
+		}
 	}
 	return nil
 }
@@ -182,33 +335,99 @@ func (s3FS *s3FSRW) String() string {
 }
 
 func (s3FS *s3FSRW) Rename(src, dest string) error {
+	return s3FS.RenameContext(context.Background(), src, dest)
+}
+
+// RenameContext copies src to dest on the server and removes src afterward
+func (s3FS *s3FSRW) RenameContext(ctx context.Context, src, dest string) error {
 	if s3FS.logger != nil {
 		s3FS.logger.Debugf("%s - Rename(%s, %s)", s3FS.String(), src, dest)
 	}
-	_, err := s3FS.Stat(dest)
-	if err != nil {
-		if !s3FS.IsNotExist(err) {
-			return errors.Wrapf(err, "cannot stat '%s'", dest)
-		}
-	} else {
-		if err := s3FS.Remove(dest); err != nil {
-			return errors.Wrapf(err, "cannot delete '%s'", dest)
+	if err := s3FS.copyFile(ctx, src, dest); err != nil {
+		return errors.Wrapf(err, "cannot copy '%s' --> '%s'", src, dest)
+	}
+	if err := s3FS.RemoveContext(ctx, src); err != nil {
+		return errors.Wrapf(err, "cannot delete '%s'", src)
+	}
+	return nil
+}
+
+// CopyFile copies src to dst on the server via ComposeObject, which uses a multipart copy for objects larger than 5GiB
+func (s3FS *s3FSRW) CopyFile(src, dst string) error {
+	return s3FS.copyFile(context.Background(), src, dst)
+}
+
+func (s3FS *s3FSRW) copyFile(ctx context.Context, src, dst string) error {
+	if s3FS.logger != nil {
+		s3FS.logger.Debugf("%s - CopyFile(%s, %s)", s3FS.String(), src, dst)
+	}
+	srcBucket, srcPath := extractBucket(src)
+	dstBucket, dstPath := extractBucket(dst)
+	if srcPath == "" || dstPath == "" {
+		return errors.Wrapf(fs.ErrInvalid, "cannot copy buckets '%s' --> '%s'", src, dst)
+	}
+	if _, err := s3FS.client.ComposeObject(ctx,
+		minio.CopyDestOptions{Bucket: dstBucket, Object: dstPath},
+		minio.CopySrcOptions{Bucket: srcBucket, Object: srcPath},
+	); err != nil {
+		if s3FS.IsNotExist(err) {
+			return errors.Wrapf(fs.ErrNotExist, "cannot find '%s'", src)
 		}
+		return errors.Wrapf(err, "cannot copy '%s' --> '%s'", src, dst)
 	}
-	// now, dest should not exist...
+	return nil
+}
 
-	srcFP, err := s3FS.Open(src)
-	if err != nil {
-		return errors.Wrapf(err, "cannot open '%s'", src)
+// Chtimes stores mtime in the user metadata of the object. atime is not stored.
+func (s3FS *s3FSRW) Chtimes(name string, atime, mtime time.Time) error {
+	return s3FS.setUserMetadata(context.Background(), name, map[string]string{metaModTime: mtime.Format(time.RFC3339Nano)})
+}
+
+// Chmod stores the permission bits of mode in the user metadata of the object
+func (s3FS *s3FSRW) Chmod(name string, mode fs.FileMode) error {
+	return s3FS.setUserMetadata(context.Background(), name, map[string]string{metaMode: strconv.FormatUint(uint64(mode.Perm()), 8)})
+}
+
+// systemMetadata are the headers of an object, which are replaced together with the user metadata
+var systemMetadata = []string{"Cache-Control", "Content-Disposition", "Content-Encoding", "Content-Language", "Expires"}
+
+// setUserMetadata adds meta to the user metadata of path via a server side copy of the object onto itself.
+// Content-Type and the other system metadata are kept. Objects larger than 5GiB are copied with multipart copy.
+func (s3FS *s3FSRW) setUserMetadata(ctx context.Context, path string, meta map[string]string) error {
+	if s3FS.logger != nil {
+		s3FS.logger.Debugf("%s - setUserMetadata(%s, %v)", s3FS.String(), path, meta)
+	}
+	bucket, bucketPath := extractBucket(path)
+	if bucketPath == "" {
+		return errors.Wrapf(fs.ErrInvalid, "cannot set metadata of bucket '%s'", path)
 	}
-	defer srcFP.Close()
-	destFP, err := s3FS.Create(dest)
+	objectInfo, err := s3FS.client.StatObject(ctx, bucket, bucketPath, minio.StatObjectOptions{})
 	if err != nil {
-		return errors.Wrapf(err, "cannot create '%s'", dest)
+		if s3FS.IsNotExist(err) {
+			return errors.Wrapf(fs.ErrNotExist, "cannot find '%s'", path)
+		}
+		return errors.Wrapf(err, "cannot stat '%s'", path)
 	}
-	defer destFP.Close()
-	if _, err := io.Copy(destFP, srcFP); err != nil {
-		return errors.Wrapf(err, "cannot copy '%s' --> '%s'", src, dest)
+	userMetadata := map[string]string{}
+	for key, value := range objectInfo.UserMetadata {
+		userMetadata[key] = value
+	}
+	for key, value := range meta {
+		userMetadata[key] = value
+	}
+	if objectInfo.ContentType != "" {
+		userMetadata["Content-Type"] = objectInfo.ContentType
+	}
+	for _, key := range systemMetadata {
+		if value := objectInfo.Metadata.Get(key); value != "" {
+			userMetadata[key] = value
+		}
+	}
+	if _, err := s3FS.client.ComposeObject(ctx,
+		minio.CopyDestOptions{Bucket: bucket, Object: bucketPath, UserMetadata: userMetadata, ReplaceMetadata: true},
+		minio.CopySrcOptions{Bucket: bucket, Object: bucketPath},
+	); err != nil {
+		return errors.Wrapf(err, "cannot set metadata of '%s'", path)
 	}
 	return nil
 }
@@ -228,6 +447,22 @@ func (s3FS *s3FSRW) IsNotExist(err error) bool {
 	return slices.Contains(notFoundStatus, errResp.StatusCode)
 }
 
+// IsTransient reports server errors, throttling and network failures, after which a retry may succeed
+func (s3FS *s3FSRW) IsTransient(err error) bool {
+	if errResp, ok := err.(minio.ErrorResponse); ok {
+		return errResp.StatusCode >= http.StatusInternalServerError || errResp.StatusCode == http.StatusTooManyRequests
+	}
+	return writefs.IsNetworkFailure(err)
+}
+
+// transient marks err with writefs.Transient if it is transient
+func (s3FS *s3FSRW) transient(err error) error {
+	if s3FS.IsTransient(err) {
+		return writefs.Transient(err)
+	}
+	return err
+}
+
 func (s3FS *s3FSRW) WalkDir(path string, fn fs.WalkDirFunc) error {
 	var err error
 	bucket, bucketPath := extractBucket(path)
@@ -259,29 +494,36 @@ func (s3FS *s3FSRW) WalkDir(path string, fn fs.WalkDirFunc) error {
 }
 
 func (s3FS *s3FSRW) Stat(path string) (fs.FileInfo, error) {
+	return s3FS.StatContext(context.Background(), path)
+}
+
+func (s3FS *s3FSRW) StatContext(ctx context.Context, path string) (fs.FileInfo, error) {
 	bucket, bucketPath := extractBucket(path)
 	if bucket == "" {
 		return writefs.NewFileInfoDir(path), nil
 	}
-	ctx := context.Background()
 	objectInfo, err := s3FS.client.StatObject(ctx, bucket, bucketPath, minio.StatObjectOptions{})
 	if err != nil {
 		if s3FS.IsNotExist(err) {
-			if s3FS.hasContent(path) {
+			if s3FS.hasContentContext(ctx, path) {
 				return writefs.NewFileInfoDir(path), nil
 			} else {
 				return nil, fs.ErrNotExist
 			}
 		}
-		return nil, errors.Wrapf(err, "cannot stat '%s'", path)
+		return nil, errors.Wrapf(s3FS.transient(err), "cannot stat '%s'", path)
 	}
 	return &fileInfo{&objectInfo}, nil
 }
 
 func (s3FS *s3FSRW) hasContent(prefix string) bool {
+	return s3FS.hasContentContext(context.Background(), prefix)
+}
+
+func (s3FS *s3FSRW) hasContentContext(ctx context.Context, prefix string) bool {
 	bucket, bucketPath := extractBucket(prefix)
 	s3FS.logger.Debugf("%s - hasContent(%s)", s3FS.String(), prefix)
-	ctx, cancel := context.WithCancel(context.Background())
+	ctx, cancel := context.WithCancel(ctx)
 	chanObjectInfo := s3FS.client.ListObjects(ctx, bucket, minio.ListObjectsOptions{Prefix: bucketPath})
 	objectInfo, ok := <-chanObjectInfo
 	if ok {
@@ -299,13 +541,28 @@ func (s3FS *s3FSRW) HasContent() bool {
 }
 
 var (
-	_ writefs.ReadWriteFS = &s3FSRW{}
-	_ writefs.MkDirFS     = &s3FSRW{}
-	_ writefs.RenameFS    = &s3FSRW{}
-	_ writefs.RemoveFS    = &s3FSRW{}
-	_ fs.ReadDirFS        = &s3FSRW{}
-	_ fs.ReadFileFS       = &s3FSRW{}
-	_ fs.StatFS           = &s3FSRW{}
-	_ fs.SubFS            = &s3FSRW{}
-	_ fmt.Stringer        = &s3FSRW{}
+	_ writefs.ReadWriteFS    = &s3FSRW{}
+	_ writefs.CreateAtomicFS = &s3FSRW{}
+	_ writefs.OpenFileFS     = &s3FSRW{}
+	_ writefs.MkDirFS        = &s3FSRW{}
+	_ writefs.RenameFS       = &s3FSRW{}
+	_ writefs.RemoveFS       = &s3FSRW{}
+	_ writefs.RemoveAllFS    = &s3FSRW{}
+	_ writefs.MkDirAllFS     = &s3FSRW{}
+	_ writefs.CopyFileFS     = &s3FSRW{}
+	_ writefs.ChtimesFS      = &s3FSRW{}
+	_ writefs.ChmodFS        = &s3FSRW{}
+	_ fs.ReadDirFS           = &s3FSRW{}
+	_ fs.ReadFileFS          = &s3FSRW{}
+	_ fs.StatFS              = &s3FSRW{}
+	_ fs.SubFS               = &s3FSRW{}
+	_ fmt.Stringer           = &s3FSRW{}
+
+	_ writefs.CreateContextFS   = &s3FSRW{}
+	_ writefs.OpenFileContextFS = &s3FSRW{}
+	_ writefs.OpenContextFS     = &s3FSRW{}
+	_ writefs.StatContextFS     = &s3FSRW{}
+	_ writefs.RemoveContextFS   = &s3FSRW{}
+	_ writefs.RenameContextFS   = &s3FSRW{}
+	_ writefs.ReadDirContextFS  = &s3FSRW{}
 )
@@ -2,6 +2,7 @@ package s3fsrw
 
 import (
 	"emperror.dev/errors"
+	"github.com/je4/filesystem/v3/pkg/writefs"
 	"github.com/je4/utils/v2/pkg/zLogger"
 	"github.com/minio/minio-go/v7"
 	"io"
@@ -62,7 +63,12 @@ func (wc *rwCloser) Write(p []byte) (n int, err error) {
 func (wc *rwCloser) Close() error {
 	if !wc.isClosed.Load() {
 		wc.isClosed.Swap(true)
-		wc.errs = append(wc.errs, wc.PipeWriter.Close())
+		if len(wc.errs) > 0 {
+			// a failed write must not complete the upload
+			wc.errs = append(wc.errs, wc.PipeWriter.CloseWithError(errors.Combine(wc.errs...)))
+		} else {
+			wc.errs = append(wc.errs, wc.PipeWriter.Close())
+		}
 		if wc.uploadInfo == nil {
 			wc.uploadInfo = <-wc.c
 		}
@@ -72,6 +78,26 @@ func (wc *rwCloser) Close() error {
 	return errors.Combine(wc.errs...)
 }
 
+// Abort fails the upload, so that no object is created. Abort has no effect after Close.
+func (wc *rwCloser) Abort() error {
+	if wc.isClosed.Swap(true) {
+		return nil
+	}
+	wc.PipeWriter.CloseWithError(writefs.ErrAborted)
+	if wc.uploadInfo == nil {
+		wc.uploadInfo = <-wc.c
+	}
+	wc.logger.Debugf("abort s3 write file: %s", wc.debugInfo)
+	if wc.uploadInfo.err == nil {
+		return errors.Errorf("upload of '%s' completed before abort", wc.debugInfo)
+	}
+	return nil
+}
+
 func (wc *rwCloser) GetReader() io.Reader {
 	return wc.pr
 }
+
+var (
+	_ writefs.AbortFileWrite = &rwCloser{}
+)
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"github.com/je4/filesystem/v3/pkg/writefs"
+	"github.com/je4/filesystem/v3/pkg/writefs/writefstest"
 	"github.com/minio/madmin-go/v3"
 	mclient "github.com/minio/minio-go/v7"
 	"github.com/minio/minio-go/v7/pkg/credentials"
@@ -12,7 +13,9 @@ import (
 	"github.com/rs/zerolog"
 	"io/fs"
 	"net"
+	"net/http"
 	"os"
+	"strings"
 	"testing"
 	"time"
 )
@@ -132,6 +135,7 @@ func TestS3FS(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
+	defer testS3FSFactory.Release("arn:local:s3:::")
 	t.Run("create & read", func(t *testing.T) {
 		for i := 0; i < 10; i++ {
 			testx := fmt.Sprintf("test%d", i)
@@ -187,6 +191,127 @@ func TestS3FS(t *testing.T) {
 			}
 		}
 	})
+	t.Run("copy & move", func(t *testing.T) {
+		if _, err := writefs.CopyFS(s3fs, "test/test0.txt", s3fs, "test/copy0.txt", nil); err != nil {
+			t.Fatal(err)
+		}
+		if err := writefs.Move(s3fs, "test/copy0.txt", s3fs, "test2/moved0.txt", nil); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := fs.Stat(s3fs, "test/copy0.txt"); !errors.Is(err, fs.ErrNotExist) {
+			t.Fatalf("'test/copy0.txt' not moved: %v", err)
+		}
+		data, err := fs.ReadFile(s3fs, "test2/moved0.txt")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != "test0" {
+			t.Fatal("wrong data")
+		}
+	})
+	t.Run("removeall", func(t *testing.T) {
+		for i := 0; i < 10; i++ {
+			testx := fmt.Sprintf("test%d", i)
+			if _, err := writefs.WriteFile(s3fs, "test/sub/"+testx+".txt", []byte(testx)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := writefs.RemoveAll(s3fs, "test/sub"); err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 10; i++ {
+			testx := fmt.Sprintf("test%d", i)
+			if _, err := fs.Stat(s3fs, "test/sub/"+testx+".txt"); !errors.Is(err, fs.ErrNotExist) {
+				t.Fatalf("'test/sub/%s.txt' not removed: %v", testx, err)
+			}
+		}
+		if _, err := fs.ReadFile(s3fs, "test/test0.txt"); err != nil {
+			t.Fatalf("'test/test0.txt' removed: %v", err)
+		}
+	})
+	t.Run("chtimes & chmod", func(t *testing.T) {
+		mtime := time.Date(2001, 2, 3, 4, 5, 6, 0, time.UTC)
+		if err := writefs.Chtimes(s3fs, "test/test1.txt", mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+		if err := writefs.Chmod(s3fs, "test/test1.txt", 0640); err != nil {
+			t.Fatal(err)
+		}
+		info, err := fs.Stat(s3fs, "test/test1.txt")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !info.ModTime().Equal(mtime) {
+			t.Fatalf("wrong modtime %v", info.ModTime())
+		}
+		if info.Mode().Perm() != 0640 {
+			t.Fatalf("wrong mode %v", info.Mode())
+		}
+		data, err := fs.ReadFile(s3fs, "test/test1.txt")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != "test1" {
+			t.Fatal("wrong data")
+		}
+		s3FS, ok := s3fs.(*s3FSRW)
+		if !ok {
+			t.Skipf("no s3FSRW: %T", s3fs)
+		}
+		if _, err := s3FS.client.PutObject(context.Background(), "test", "typed.txt", strings.NewReader("typed"), 5,
+			mclient.PutObjectOptions{ContentType: "text/plain", CacheControl: "no-cache"}); err != nil {
+			t.Fatal(err)
+		}
+		if err := writefs.Chmod(s3fs, "test/typed.txt", 0640); err != nil {
+			t.Fatal(err)
+		}
+		objectInfo, err := s3FS.client.StatObject(context.Background(), "test", "typed.txt", mclient.StatObjectOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if objectInfo.ContentType != "text/plain" || objectInfo.Metadata.Get("Cache-Control") != "no-cache" {
+			t.Fatalf("system metadata lost: %s, %s", objectInfo.ContentType, objectInfo.Metadata.Get("Cache-Control"))
+		}
+	})
+	t.Run("exclusive create", func(t *testing.T) {
+		// uploads of unknown size are multipart uploads, the conflict is reported by CompleteMultipartUpload on Close
+		fp, err := writefs.OpenFile(s3fs, "test/test2.txt", os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := fp.Write([]byte("overwritten")); err != nil {
+			t.Fatal(err)
+		}
+		if err := fp.Close(); !errors.Is(err, fs.ErrExist) {
+			t.Fatalf("expected fs.ErrExist, got %v", err)
+		}
+		data, err := fs.ReadFile(s3fs, "test/test2.txt")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != "test2" {
+			t.Fatal("existing object overwritten")
+		}
+		fp, err = writefs.OpenFile(s3fs, "test/exclusive.txt", os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := fp.Write([]byte("exclusive")); err != nil {
+			t.Fatal(err)
+		}
+		if err := fp.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := writefs.OpenFile(s3fs, "test/exclusive.txt", os.O_WRONLY|os.O_APPEND, 0); !errors.Is(err, writefs.ErrNotImplemented) {
+			t.Fatalf("expected writefs.ErrNotImplemented, got %v", err)
+		}
+	})
+	t.Run("conformance", func(t *testing.T) {
+		if err := writefs.MkDir(s3fs, "conformance"); err != nil {
+			t.Fatal(err)
+		}
+		writefstest.TestReadWriteFS(t, writefs.NewSubFS(s3fs, "conformance"), nil)
+	})
 	t.Run("walkdir", func(t *testing.T) {
 		fs.WalkDir(s3fs, "", func(path string, entry fs.DirEntry, err error) error {
 			if entry == nil {
@@ -205,3 +330,26 @@ func TestS3FS(t *testing.T) {
 		})
 	})
 }
+
+func TestIsObjectWrite(t *testing.T) {
+	for _, tc := range []struct {
+		method string
+		query  string
+		write  bool
+	}{
+		{http.MethodPut, "", true},                         // PutObject
+		{http.MethodPost, "uploads", false},                // CreateMultipartUpload
+		{http.MethodPut, "partNumber=1&uploadId=x", false}, // UploadPart
+		{http.MethodPost, "uploadId=x", true},              // CompleteMultipartUpload
+		{http.MethodPost, "delete", false},                 // DeleteObjects
+		{http.MethodGet, "", false},
+	} {
+		req, err := http.NewRequest(tc.method, "http://localhost/bucket/object?"+tc.query, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if isObjectWrite(req) != tc.write {
+			t.Errorf("%s ?%s: expected %v", tc.method, tc.query, tc.write)
+		}
+	}
+}
@@ -1,19 +1,119 @@
 package sftpfsrw
 
 import (
+	"context"
 	"emperror.dev/errors"
+	"github.com/je4/filesystem/v3/pkg/writefs"
 	"github.com/pkg/sftp"
+	"io/fs"
+	"sync/atomic"
 )
 
+func newSFTPFile(ctx context.Context, fp *sftp.File, sess *sftpSession) *sftpFile {
+	f := &sftpFile{
+		File: fp,
+		sess: sess,
+		ctx:  ctx,
+	}
+	// closing the file unblocks pending reads and writes if ctx is cancelled
+	f.stop = context.AfterFunc(ctx, func() {
+		sess.logger.Debug().Msgf("context done, closing '%s'", fp.Name())
+		fp.Close()
+	})
+	return f
+}
+
 type sftpFile struct {
 	*sftp.File
-	sess *sftpSession
+	sess   *sftpSession
+	ctx    context.Context
+	stop   func() bool
+	closed atomic.Bool
 }
 
 func (f *sftpFile) Close() error {
+	if err := f.release(); err != nil {
+		return err
+	}
 	defer f.sess.sftpFS.closeSession(f.sess)
+	return f.close()
+}
+
+// release marks the file as closed, so that the session is returned only once
+func (f *sftpFile) release() error {
+	if !f.closed.CompareAndSwap(false, true) {
+		return errors.Wrapf(fs.ErrClosed, "'%s' already closed", f.Name())
+	}
+	return nil
+}
+
+// close closes the remote file without returning the session
+func (f *sftpFile) close() error {
+	if !f.stop() {
+		return errors.Wrapf(context.Cause(f.ctx), "'%s' closed by context", f.Name())
+	}
 	if err := f.File.Close(); err != nil {
 		return errors.Wrapf(err, "cannot close '%s'", f.Name())
 	}
 	return nil
 }
+
+// sftpWriteFile is a file created for writing which can be aborted
+type sftpWriteFile struct {
+	*sftpFile
+}
+
+// Abort closes and removes the file
+func (f *sftpWriteFile) Abort() error {
+	if err := f.release(); err != nil {
+		return err
+	}
+	defer f.sess.sftpFS.closeSession(f.sess)
+	f.close()
+	if err := f.sess.Remove(f.Name()); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return errors.Wrapf(err, "cannot remove '%s'", f.Name())
+	}
+	return nil
+}
+
+// sftpAtomicFile is written to a temporary file which is renamed to path on Close
+type sftpAtomicFile struct {
+	*sftpFile
+	path string
+}
+
+func (f *sftpAtomicFile) Close() error {
+	if err := f.release(); err != nil {
+		return err
+	}
+	defer f.sess.sftpFS.closeSession(f.sess)
+	tmpPath := f.Name()
+	if err := f.close(); err != nil {
+		f.sess.Remove(tmpPath)
+		return errors.WithStack(err)
+	}
+	if err := f.sess.posixRename(tmpPath, f.path); err != nil {
+		f.sess.Remove(tmpPath)
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+// Abort removes the temporary file
+func (f *sftpAtomicFile) Abort() error {
+	if err := f.release(); err != nil {
+		return err
+	}
+	defer f.sess.sftpFS.closeSession(f.sess)
+	f.close()
+	if err := f.sess.Remove(f.Name()); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return errors.Wrapf(err, "cannot remove '%s'", f.Name())
+	}
+	return nil
+}
+
+var (
+	_ writefs.AbortFileWrite = &sftpWriteFile{}
+	_ writefs.FileWriterAt   = &sftpWriteFile{}
+	_ writefs.AbortFileWrite = &sftpAtomicFile{}
+)
@@ -1,10 +1,12 @@
 package sftpfsrw
 
 import (
+	"context"
 	"emperror.dev/errors"
 	"fmt"
 	"github.com/je4/filesystem/v3/pkg/writefs"
 	"github.com/je4/utils/v2/pkg/zLogger"
+	"github.com/pkg/sftp"
 	"golang.org/x/crypto/ssh"
 	"io"
 	"io/fs"
@@ -50,17 +52,46 @@ type sftpFSRW struct {
 }
 
 func (sftpFS *sftpFSRW) Remove(path string) error {
-	sess, err := sftpFS.getSession(time.Second * 10)
+	return sftpFS.RemoveContext(context.Background(), path)
+}
+
+func (sftpFS *sftpFSRW) RemoveContext(ctx context.Context, path string) error {
+	sess, err := sftpFS.getSession(ctx)
+	if err != nil {
+		return errors.Wrapf(err, "cannot get sftp session")
+	}
+	defer sftpFS.closeSession(sess)
+	fullpath := filepath.ToSlash(filepath.Join(sftpFS.baseDir, path))
+	return transient(sess.Remove(fullpath))
+}
+
+// RemoveAll removes path and any children it contains. It returns nil if path does not exist.
+func (sftpFS *sftpFSRW) RemoveAll(path string) error {
+	return sftpFS.RemoveAllContext(context.Background(), path)
+}
+
+func (sftpFS *sftpFSRW) RemoveAllContext(ctx context.Context, path string) error {
+	sess, err := sftpFS.getSession(ctx)
 	if err != nil {
 		return errors.Wrapf(err, "cannot get sftp session")
 	}
 	defer sftpFS.closeSession(sess)
 	fullpath := filepath.ToSlash(filepath.Join(sftpFS.baseDir, path))
-	return sess.Remove(fullpath)
+	if err := sess.RemoveAll(fullpath); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return errors.Wrapf(err, "cannot remove '%s'", fullpath)
+	}
+	return nil
 }
 
 func (sftpFS *sftpFSRW) Rename(oldPath, newPath string) error {
-	sess, err := sftpFS.getSession(time.Second * 10)
+	return sftpFS.RenameContext(context.Background(), oldPath, newPath)
+}
+
+func (sftpFS *sftpFSRW) RenameContext(ctx context.Context, oldPath, newPath string) error {
+	sess, err := sftpFS.getSession(ctx)
 	if err != nil {
 		return errors.Wrapf(err, "cannot get sftp session")
 	}
@@ -71,7 +102,11 @@ func (sftpFS *sftpFSRW) Rename(oldPath, newPath string) error {
 }
 
 func (sftpFS *sftpFSRW) MkDir(path string) error {
-	sess, err := sftpFS.getSession(time.Second * 10)
+	return sftpFS.MkDirContext(context.Background(), path)
+}
+
+func (sftpFS *sftpFSRW) MkDirContext(ctx context.Context, path string) error {
+	sess, err := sftpFS.getSession(ctx)
 	if err != nil {
 		return errors.Wrapf(err, "cannot get sftp session")
 	}
@@ -80,21 +115,148 @@ func (sftpFS *sftpFSRW) MkDir(path string) error {
 	return sess.Mkdir(fullpath)
 }
 
+func (sftpFS *sftpFSRW) MkDirAll(path string) error {
+	return sftpFS.MkDirAllContext(context.Background(), path)
+}
+
+func (sftpFS *sftpFSRW) MkDirAllContext(ctx context.Context, path string) error {
+	sess, err := sftpFS.getSession(ctx)
+	if err != nil {
+		return errors.Wrapf(err, "cannot get sftp session")
+	}
+	defer sftpFS.closeSession(sess)
+	fullpath := filepath.ToSlash(filepath.Join(sftpFS.baseDir, path))
+	return errors.Wrapf(sess.MkdirAll(fullpath), "cannot create directory '%s'", fullpath)
+}
+
+func (sftpFS *sftpFSRW) Chtimes(name string, atime, mtime time.Time) error {
+	sess, err := sftpFS.getSession(context.Background())
+	if err != nil {
+		return errors.Wrapf(err, "cannot get sftp session")
+	}
+	defer sftpFS.closeSession(sess)
+	fullpath := filepath.ToSlash(filepath.Join(sftpFS.baseDir, name))
+	return errors.Wrapf(sess.Chtimes(fullpath, atime, mtime), "cannot change times of '%s'", fullpath)
+}
+
+func (sftpFS *sftpFSRW) Chmod(name string, mode fs.FileMode) error {
+	sess, err := sftpFS.getSession(context.Background())
+	if err != nil {
+		return errors.Wrapf(err, "cannot get sftp session")
+	}
+	defer sftpFS.closeSession(sess)
+	fullpath := filepath.ToSlash(filepath.Join(sftpFS.baseDir, name))
+	return errors.Wrapf(sess.Chmod(fullpath, mode), "cannot change mode of '%s'", fullpath)
+}
+
+// Symlink creates newname as a link to oldname. oldname is not modified,
+// so relative destinations are resolved from the folder of newname.
+func (sftpFS *sftpFSRW) Symlink(oldname, newname string) error {
+	sess, err := sftpFS.getSession(context.Background())
+	if err != nil {
+		return errors.Wrapf(err, "cannot get sftp session")
+	}
+	defer sftpFS.closeSession(sess)
+	fullpath := filepath.ToSlash(filepath.Join(sftpFS.baseDir, newname))
+	return errors.Wrapf(sess.Symlink(oldname, fullpath), "cannot create link '%s' to '%s'", fullpath, oldname)
+}
+
+func (sftpFS *sftpFSRW) ReadLink(name string) (string, error) {
+	sess, err := sftpFS.getSession(context.Background())
+	if err != nil {
+		return "", errors.Wrapf(err, "cannot get sftp session")
+	}
+	defer sftpFS.closeSession(sess)
+	fullpath := filepath.ToSlash(filepath.Join(sftpFS.baseDir, name))
+	dest, err := sess.ReadLink(fullpath)
+	if err != nil {
+		return "", errors.Wrapf(err, "cannot read link '%s'", fullpath)
+	}
+	return dest, nil
+}
+
+func (sftpFS *sftpFSRW) Lstat(name string) (fs.FileInfo, error) {
+	sess, err := sftpFS.getSession(context.Background())
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot get sftp session")
+	}
+	defer sftpFS.closeSession(sess)
+	fullpath := filepath.ToSlash(filepath.Join(sftpFS.baseDir, name))
+	fi, err := sess.Lstat(fullpath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot lstat '%s'", fullpath)
+	}
+	return fi, nil
+}
+
 func (sftpFS *sftpFSRW) Create(path string) (writefs.FileWrite, error) {
-	sess, err := sftpFS.getSession(time.Second * 10)
+	return sftpFS.CreateContext(context.Background(), path)
+}
+
+// CreateContext creates path. The file is closed if ctx is cancelled.
+func (sftpFS *sftpFSRW) CreateContext(ctx context.Context, path string) (writefs.FileWrite, error) {
+	sess, err := sftpFS.getSession(ctx)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot get sftp session")
+	}
+	fullpath := filepath.ToSlash(filepath.Join(sftpFS.baseDir, path))
+	fp, err := sess.CreateContext(ctx, fullpath)
+	if err != nil {
+		sftpFS.closeSession(sess)
+		return nil, errors.Wrapf(err, "cannot create '%s'", path)
+	}
+	return fp, nil
+}
+
+func (sftpFS *sftpFSRW) OpenFile(path string, flag int, perm fs.FileMode) (writefs.FileWrite, error) {
+	return sftpFS.OpenFileContext(context.Background(), path, flag, perm)
+}
+
+// OpenFileContext opens path with the flags of os.OpenFile. The file is closed if ctx is cancelled.
+func (sftpFS *sftpFSRW) OpenFileContext(ctx context.Context, path string, flag int, perm fs.FileMode) (writefs.FileWrite, error) {
+	sess, err := sftpFS.getSession(ctx)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot get sftp session")
+	}
+	fullpath := filepath.ToSlash(filepath.Join(sftpFS.baseDir, path))
+	fp, err := sess.OpenFileContext(ctx, fullpath, flag, perm)
+	if err != nil {
+		sftpFS.closeSession(sess)
+		return nil, errors.Wrapf(err, "cannot open '%s'", path)
+	}
+	return fp, nil
+}
+
+// CreateWriterAt creates path for random access writes
+func (sftpFS *sftpFSRW) CreateWriterAt(path string) (writefs.FileWriterAt, error) {
+	fp, err := sftpFS.CreateContext(context.Background(), path)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return fp.(*sftpWriteFile), nil
+}
+
+// CreateAtomic writes to a hidden temporary file which replaces path on Close
+func (sftpFS *sftpFSRW) CreateAtomic(path string) (writefs.FileWrite, error) {
+	sess, err := sftpFS.getSession(context.Background())
 	if err != nil {
 		return nil, errors.Wrapf(err, "cannot get sftp session")
 	}
 	fullpath := filepath.ToSlash(filepath.Join(sftpFS.baseDir, path))
-	fp, err := sess.Create(fullpath)
+	fp, err := sess.CreateAtomicContext(context.Background(), fullpath)
 	if err != nil {
-		return nil, errors.Wrapf(err, "cannot create '%s'")
+		sftpFS.closeSession(sess)
+		return nil, errors.Wrapf(err, "cannot create '%s'", path)
 	}
 	return fp, nil
 }
 
 func (sftpFS *sftpFSRW) ReadDir(name string) ([]fs.DirEntry, error) {
-	sess, err := sftpFS.getSession(time.Second * 10)
+	return sftpFS.ReadDirContext(context.Background(), name)
+}
+
+func (sftpFS *sftpFSRW) ReadDirContext(ctx context.Context, name string) ([]fs.DirEntry, error) {
+	sess, err := sftpFS.getSession(ctx)
 	if err != nil {
 		return nil, errors.Wrapf(err, "cannot get sftp session")
 	}
@@ -102,7 +264,7 @@ func (sftpFS *sftpFSRW) ReadDir(name string) ([]fs.DirEntry, error) {
 	fullpath := filepath.ToSlash(filepath.Join(sftpFS.baseDir, name))
 	dirs, err := sess.ReadDir(fullpath)
 	if err != nil {
-		return nil, errors.Wrapf(err, "cannot read folder '%s'", fullpath)
+		return nil, errors.Wrapf(transient(err), "cannot read folder '%s'", fullpath)
 	}
 	ret := []fs.DirEntry{}
 	for _, d := range dirs {
@@ -129,30 +291,59 @@ func (sftpFS *sftpFSRW) ReadFile(name string) ([]byte, error) {
 }
 
 func (sftpFS *sftpFSRW) Stat(name string) (fs.FileInfo, error) {
-	sess, err := sftpFS.getSession(time.Second * 10)
+	return sftpFS.StatContext(context.Background(), name)
+}
+
+func (sftpFS *sftpFSRW) StatContext(ctx context.Context, name string) (fs.FileInfo, error) {
+	sess, err := sftpFS.getSession(ctx)
 	if err != nil {
 		return nil, errors.Wrapf(err, "cannot get sftp session")
 	}
+	defer sftpFS.closeSession(sess)
 	fullpath := filepath.ToSlash(filepath.Join(sftpFS.baseDir, name))
 	fi, err := sess.Stat(fullpath)
 	if err != nil {
-		return nil, errors.Wrapf(err, "cannot stat '%s'", fullpath)
+		return nil, errors.Wrapf(transient(err), "cannot stat '%s'", fullpath)
 	}
 	return fi, nil
 }
 
-func (sftpFS *sftpFSRW) getSession(timeout time.Duration) (*sftpSession, error) {
+// DefaultSessionTimeout is the maximum time to wait for a free session
+// if the context has no deadline
+const DefaultSessionTimeout = time.Second * 10
+
+// getSession waits for a free session. If the DefaultSessionTimeout is reached, the error is transient,
+// a cancelled context or a deadline of the caller is permanent
+func (sftpFS *sftpFSRW) getSession(ctx context.Context) (*sftpSession, error) {
+	var defaultTimeout bool
+	if _, ok := ctx.Deadline(); !ok {
+		defaultTimeout = true
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, DefaultSessionTimeout)
+		defer cancel()
+	}
 	select {
 	case i, ok := <-sftpFS.freeSessions:
 		if !ok {
 			return nil, errors.Errorf("error reading from channel")
 		}
 		return sftpFS.sftpSessions[i], nil
-	case <-time.After(timeout):
-		return nil, errors.Errorf("timeout reached")
+	case <-ctx.Done():
+		if defaultTimeout && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, writefs.Transient(errors.Wrap(ctx.Err(), "timeout reached"))
+		}
+		return nil, errors.Wrap(ctx.Err(), "timeout reached")
 	}
 }
 
+// transient marks lost connections as transient
+func transient(err error) error {
+	if errors.Is(err, sftp.ErrSSHFxConnectionLost) || errors.Is(err, sftp.ErrSSHFxNoConnection) || writefs.IsNetworkFailure(err) {
+		return writefs.Transient(err)
+	}
+	return err
+}
+
 func (sftpFS *sftpFSRW) closeSession(sess *sftpSession) {
 	sftpFS.freeSessions <- sess.id
 }
@@ -166,15 +357,20 @@ func (sftpFS *sftpFSRW) String() string {
 }
 
 func (sftpFS *sftpFSRW) Open(name string) (fs.File, error) {
-	sess, err := sftpFS.getSession(time.Second * 10)
+	return sftpFS.OpenContext(context.Background(), name)
+}
+
+// OpenContext opens name. The file is closed if ctx is cancelled.
+func (sftpFS *sftpFSRW) OpenContext(ctx context.Context, name string) (fs.File, error) {
+	sess, err := sftpFS.getSession(ctx)
 	if err != nil {
 		return nil, errors.Wrapf(err, "cannot get sftp session")
 	}
 	fullpath := filepath.ToSlash(filepath.Join(sftpFS.baseDir, name))
-	fp, err := sess.Open(fullpath)
+	fp, err := sess.OpenContext(ctx, fullpath)
 	if err != nil {
 		sftpFS.closeSession(sess)
-		return nil, errors.Wrapf(err, "cannot open '%s'", name)
+		return nil, errors.Wrapf(transient(err), "cannot open '%s'", name)
 	}
 	return fp, nil
 }
@@ -198,9 +394,30 @@ var (
 	_ fs.StatFS     = (*sftpFSRW)(nil)
 	_ fs.SubFS      = (*sftpFSRW)(nil)
 	//	_ writefs.IsLockedFS = (*sftpFSRW)(nil)
-	_ fmt.Stringer        = (*sftpFSRW)(nil)
-	_ writefs.ReadWriteFS = (*sftpFSRW)(nil)
-	_ writefs.MkDirFS     = (*sftpFSRW)(nil)
-	_ writefs.RenameFS    = (*sftpFSRW)(nil)
-	_ writefs.RemoveFS    = (*sftpFSRW)(nil)
+	_ fmt.Stringer             = (*sftpFSRW)(nil)
+	_ writefs.ReadWriteFS      = (*sftpFSRW)(nil)
+	_ writefs.MkDirFS          = (*sftpFSRW)(nil)
+	_ writefs.RenameFS         = (*sftpFSRW)(nil)
+	_ writefs.RemoveFS         = (*sftpFSRW)(nil)
+	_ writefs.RemoveAllFS      = (*sftpFSRW)(nil)
+	_ writefs.MkDirAllFS       = (*sftpFSRW)(nil)
+	_ writefs.ChtimesFS        = (*sftpFSRW)(nil)
+	_ writefs.ChmodFS          = (*sftpFSRW)(nil)
+	_ writefs.CreateAtomicFS   = (*sftpFSRW)(nil)
+	_ writefs.OpenFileFS       = (*sftpFSRW)(nil)
+	_ writefs.CreateWriterAtFS = (*sftpFSRW)(nil)
+	_ writefs.SymlinkFS        = (*sftpFSRW)(nil)
+	_ writefs.ReadLinkFS       = (*sftpFSRW)(nil)
+	_ writefs.LstatFS          = (*sftpFSRW)(nil)
+
+	_ writefs.CreateContextFS    = (*sftpFSRW)(nil)
+	_ writefs.OpenFileContextFS  = (*sftpFSRW)(nil)
+	_ writefs.OpenContextFS      = (*sftpFSRW)(nil)
+	_ writefs.StatContextFS      = (*sftpFSRW)(nil)
+	_ writefs.RemoveContextFS    = (*sftpFSRW)(nil)
+	_ writefs.RenameContextFS    = (*sftpFSRW)(nil)
+	_ writefs.ReadDirContextFS   = (*sftpFSRW)(nil)
+	_ writefs.MkDirContextFS     = (*sftpFSRW)(nil)
+	_ writefs.MkDirAllContextFS  = (*sftpFSRW)(nil)
+	_ writefs.RemoveAllContextFS = (*sftpFSRW)(nil)
 )
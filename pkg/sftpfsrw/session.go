@@ -1,12 +1,14 @@
 package sftpfsrw
 
 import (
+	"context"
 	"emperror.dev/errors"
 	"github.com/je4/filesystem/v3/pkg/writefs"
 	"github.com/je4/utils/v2/pkg/zLogger"
 	"github.com/pkg/sftp"
 	"golang.org/x/crypto/ssh"
 	"io/fs"
+	"os"
 )
 
 func NewSession(conn *ssh.Client, sftpFS *sftpFSRW, i uint, logger zLogger.ZLogger) error {
@@ -35,25 +37,77 @@ type sftpSession struct {
 }
 
 func (sess *sftpSession) Open(fullpath string) (fs.File, error) {
+	return sess.OpenContext(context.Background(), fullpath)
+}
+
+// OpenContext opens fullpath. The file is closed if ctx is cancelled.
+func (sess *sftpSession) OpenContext(ctx context.Context, fullpath string) (fs.File, error) {
 	sess.logger.Debug().Msgf("open '%s'", fullpath)
 	fp, err := sess.Client.Open(fullpath)
 	if err != nil {
 		return nil, errors.Wrapf(err, "cannot open '%s'", fullpath)
 	}
-	return &sftpFile{
-		File: fp,
-		sess: sess,
-	}, nil
+	return newSFTPFile(ctx, fp, sess), nil
 }
 
 func (sess *sftpSession) Create(fullpath string) (writefs.FileWrite, error) {
+	return sess.CreateContext(context.Background(), fullpath)
+}
+
+// CreateContext creates fullpath. The file is closed if ctx is cancelled.
+func (sess *sftpSession) CreateContext(ctx context.Context, fullpath string) (writefs.FileWrite, error) {
 	sess.logger.Debug().Msgf("create '%s'", fullpath)
 	fp, err := sess.Client.Create(fullpath)
 	if err != nil {
 		return nil, errors.Wrapf(err, "cannot open '%s'", fullpath)
 	}
-	return &sftpFile{
-		File: fp,
-		sess: sess,
+	return &sftpWriteFile{sftpFile: newSFTPFile(ctx, fp, sess)}, nil
+}
+
+// OpenFileContext opens fullpath with the flags of os.OpenFile. The file is closed if ctx is cancelled.
+// sftp cannot set the mode on open, so perm is applied to files created with os.O_EXCL only.
+func (sess *sftpSession) OpenFileContext(ctx context.Context, fullpath string, flag int, perm fs.FileMode) (writefs.FileWrite, error) {
+	sess.logger.Debug().Msgf("open '%s' with flags %#x", fullpath, flag)
+	fp, err := sess.Client.OpenFile(fullpath, flag)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot open '%s'", fullpath)
+	}
+	if flag&(os.O_CREATE|os.O_EXCL) != os.O_CREATE|os.O_EXCL {
+		return newSFTPFile(ctx, fp, sess), nil
+	}
+	f := &sftpWriteFile{sftpFile: newSFTPFile(ctx, fp, sess)}
+	if perm != 0 {
+		if err := fp.Chmod(perm); err != nil {
+			f.close()
+			sess.Client.Remove(fullpath)
+			return nil, errors.Wrapf(err, "cannot change mode of '%s'", fullpath)
+		}
+	}
+	return f, nil
+}
+
+// CreateAtomicContext creates a temporary file which is renamed to fullpath on Close
+func (sess *sftpSession) CreateAtomicContext(ctx context.Context, fullpath string) (writefs.FileWrite, error) {
+	tmpPath := writefs.AtomicTempName(fullpath)
+	sess.logger.Debug().Msgf("create '%s' for '%s'", tmpPath, fullpath)
+	fp, err := sess.Client.Create(tmpPath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot open '%s'", tmpPath)
+	}
+	return &sftpAtomicFile{
+		sftpFile: newSFTPFile(ctx, fp, sess),
+		path:     fullpath,
 	}, nil
 }
+
+// posixRename replaces newPath atomically if the server supports the posix-rename extension.
+// Otherwise, newPath is removed before renaming.
+func (sess *sftpSession) posixRename(oldPath, newPath string) error {
+	if _, ok := sess.Client.HasExtension("posix-rename@openssh.com"); ok {
+		return errors.Wrapf(sess.Client.PosixRename(oldPath, newPath), "cannot rename '%s' to '%s'", oldPath, newPath)
+	}
+	if err := sess.Client.Remove(newPath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return errors.Wrapf(err, "cannot remove '%s'", newPath)
+	}
+	return errors.Wrapf(sess.Client.Rename(oldPath, newPath), "cannot rename '%s' to '%s'", oldPath, newPath)
+}
@@ -0,0 +1,97 @@
+package throttlefs
+
+import (
+	"context"
+	"emperror.dev/errors"
+	"github.com/je4/filesystem/v3/pkg/writefs"
+	"io"
+	"io/fs"
+)
+
+// newFile wraps fp and keeps io.ReaderAt, io.Seeker and fs.ReadDirFile if fp implements them
+func (t *throttleFS) newFile(ctx context.Context, fp fs.File) fs.File {
+	f := &file{File: fp, ctx: ctx, limiter: t.limiter}
+	_, isReaderAt := fp.(io.ReaderAt)
+	_, isSeeker := fp.(io.Seeker)
+	if isReaderAt && isSeeker {
+		return &readSeekFile{file: f}
+	}
+	if _, ok := fp.(fs.ReadDirFile); ok {
+		return &dirFile{file: f}
+	}
+	return f
+}
+
+// file waits after every Read until the read bytes are within the limit
+type file struct {
+	fs.File
+	ctx     context.Context
+	limiter *Limiter
+}
+
+func (f *file) Read(p []byte) (int, error) {
+	n, err := f.File.Read(p)
+	if wErr := f.limiter.waitRead(f.ctx, n); wErr != nil {
+		return n, wErr
+	}
+	return n, err
+}
+
+type dirFile struct {
+	*file
+}
+
+func (d *dirFile) ReadDir(n int) ([]fs.DirEntry, error) {
+	return d.File.(fs.ReadDirFile).ReadDir(n)
+}
+
+// readSeekFile is a file with random access, which limits ReadAt like Read
+type readSeekFile struct {
+	*file
+}
+
+func (f *readSeekFile) ReadAt(p []byte, off int64) (int, error) {
+	n, err := f.File.(io.ReaderAt).ReadAt(p, off)
+	if wErr := f.limiter.waitRead(f.ctx, n); wErr != nil {
+		return n, wErr
+	}
+	return n, err
+}
+
+func (f *readSeekFile) Seek(offset int64, whence int) (int64, error) {
+	return f.File.(io.Seeker).Seek(offset, whence)
+}
+
+func (f *readSeekFile) ReadDir(n int) ([]fs.DirEntry, error) {
+	dir, ok := f.File.(fs.ReadDirFile)
+	if !ok {
+		return nil, errors.Wrap(writefs.ErrNotImplemented, "ReadDir")
+	}
+	return dir.ReadDir(n)
+}
+
+// fileWrite waits before every Write until the bytes are within the limit
+type fileWrite struct {
+	writefs.FileWrite
+	ctx     context.Context
+	limiter *Limiter
+}
+
+func (fw *fileWrite) Write(p []byte) (int, error) {
+	if err := fw.limiter.waitWrite(fw.ctx, len(p)); err != nil {
+		return 0, err
+	}
+	return fw.FileWrite.Write(p)
+}
+
+func (fw *fileWrite) Abort() error {
+	return writefs.Abort(fw.FileWrite)
+}
+
+var (
+	_ fs.ReadDirFile         = &dirFile{}
+	_ fs.ReadDirFile         = &readSeekFile{}
+	_ io.ReaderAt            = &readSeekFile{}
+	_ io.Seeker              = &readSeekFile{}
+	_ writefs.AbortFileWrite = &fileWrite{}
+)
@@ -0,0 +1,293 @@
+// Package throttlefs limits the bandwidth and the operations per second of a filesystem
+package throttlefs
+
+import (
+	"context"
+	"emperror.dev/errors"
+	"fmt"
+	"github.com/je4/filesystem/v3/pkg/writefs"
+	"github.com/je4/utils/v2/pkg/zLogger"
+	"io/fs"
+	"os"
+	"time"
+)
+
+// NewFS wraps base and waits for limiter before every operation, read and write.
+// Share limiter between several filesystems to limit them together.
+func NewFS(base fs.FS, limiter *Limiter, logger zLogger.ZLogger) (*throttleFS, error) {
+	_logger := logger.With().Str("class", "throttleFS").Logger()
+	logger = &_logger
+	if limiter == nil {
+		return nil, errors.New("no limiter")
+	}
+	return &throttleFS{
+		base:    base,
+		limiter: limiter,
+		logger:  logger,
+	}, nil
+}
+
+type throttleFS struct {
+	base    fs.FS
+	limiter *Limiter
+	logger  zLogger.ZLogger
+}
+
+func (t *throttleFS) String() string {
+	return fmt.Sprintf("throttleFS(%v)", t.base)
+}
+
+func (t *throttleFS) Sub(dir string) (fs.FS, error) {
+	return writefs.NewSubFS(t, dir), nil
+}
+
+func (t *throttleFS) Open(name string) (fs.File, error) {
+	return t.OpenContext(context.Background(), name)
+}
+
+// OpenContext limits the reads of the file until ctx is done
+func (t *throttleFS) OpenContext(ctx context.Context, name string) (fs.File, error) {
+	if err := t.limiter.waitOp(ctx); err != nil {
+		return nil, err
+	}
+	fp, err := writefs.OpenContext(ctx, t.base, name)
+	if err != nil {
+		return nil, err
+	}
+	return t.newFile(ctx, fp), nil
+}
+
+func (t *throttleFS) ReadFile(name string) ([]byte, error) {
+	ctx := context.Background()
+	if err := t.limiter.waitOp(ctx); err != nil {
+		return nil, err
+	}
+	data, err := fs.ReadFile(t.base, name)
+	if wErr := t.limiter.waitRead(ctx, len(data)); wErr != nil {
+		return nil, wErr
+	}
+	return data, err
+}
+
+func (t *throttleFS) Stat(name string) (fs.FileInfo, error) {
+	return t.StatContext(context.Background(), name)
+}
+
+func (t *throttleFS) StatContext(ctx context.Context, name string) (fs.FileInfo, error) {
+	if err := t.limiter.waitOp(ctx); err != nil {
+		return nil, err
+	}
+	return writefs.StatContext(ctx, t.base, name)
+}
+
+func (t *throttleFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	return t.ReadDirContext(context.Background(), name)
+}
+
+func (t *throttleFS) ReadDirContext(ctx context.Context, name string) ([]fs.DirEntry, error) {
+	if err := t.limiter.waitOp(ctx); err != nil {
+		return nil, err
+	}
+	return writefs.ReadDirContext(ctx, t.base, name)
+}
+
+// newFileWrite limits the writes to fp until ctx is done
+func (t *throttleFS) newFileWrite(ctx context.Context, fp writefs.FileWrite, err error) (writefs.FileWrite, error) {
+	if err != nil {
+		return nil, err
+	}
+	return &fileWrite{FileWrite: fp, ctx: ctx, limiter: t.limiter}, nil
+}
+
+func (t *throttleFS) Create(name string) (writefs.FileWrite, error) {
+	return t.CreateContext(context.Background(), name)
+}
+
+func (t *throttleFS) CreateContext(ctx context.Context, name string) (writefs.FileWrite, error) {
+	if err := t.limiter.waitOp(ctx); err != nil {
+		return nil, err
+	}
+	fp, err := writefs.CreateContext(ctx, t.base, name)
+	return t.newFileWrite(ctx, fp, err)
+}
+
+func (t *throttleFS) CreateAtomic(name string) (writefs.FileWrite, error) {
+	return t.CreateAtomicContext(context.Background(), name)
+}
+
+func (t *throttleFS) CreateAtomicContext(ctx context.Context, name string) (writefs.FileWrite, error) {
+	if err := t.limiter.waitOp(ctx); err != nil {
+		return nil, err
+	}
+	fp, err := writefs.CreateAtomicContext(ctx, t.base, name)
+	return t.newFileWrite(ctx, fp, err)
+}
+
+func (t *throttleFS) OpenFile(name string, flag int, perm fs.FileMode) (writefs.FileWrite, error) {
+	return t.OpenFileContext(context.Background(), name, flag, perm)
+}
+
+func (t *throttleFS) OpenFileContext(ctx context.Context, name string, flag int, perm fs.FileMode) (writefs.FileWrite, error) {
+	if err := t.limiter.waitOp(ctx); err != nil {
+		return nil, err
+	}
+	fp, err := writefs.OpenFileContext(ctx, t.base, name, flag, perm)
+	if flag&(os.O_WRONLY|os.O_RDWR) == 0 {
+		return fp, err
+	}
+	return t.newFileWrite(ctx, fp, err)
+}
+
+func (t *throttleFS) Remove(name string) error {
+	return t.RemoveContext(context.Background(), name)
+}
+
+func (t *throttleFS) RemoveContext(ctx context.Context, name string) error {
+	if err := t.limiter.waitOp(ctx); err != nil {
+		return err
+	}
+	return writefs.RemoveContext(ctx, t.base, name)
+}
+
+func (t *throttleFS) Rename(oldPath, newPath string) error {
+	return t.RenameContext(context.Background(), oldPath, newPath)
+}
+
+func (t *throttleFS) RenameContext(ctx context.Context, oldPath, newPath string) error {
+	if err := t.limiter.waitOp(ctx); err != nil {
+		return err
+	}
+	return writefs.RenameContext(ctx, t.base, oldPath, newPath)
+}
+
+// the following operations count as one operation each, without limiting their bytes
+
+func (t *throttleFS) CreateWriterAt(name string) (writefs.FileWriterAt, error) {
+	if err := t.limiter.waitOp(context.Background()); err != nil {
+		return nil, err
+	}
+	return writefs.CreateWriterAt(t.base, name)
+}
+
+func (t *throttleFS) MkDir(name string) error {
+	return t.MkDirContext(context.Background(), name)
+}
+
+func (t *throttleFS) MkDirContext(ctx context.Context, name string) error {
+	if err := t.limiter.waitOp(ctx); err != nil {
+		return err
+	}
+	return writefs.MkDirContext(ctx, t.base, name)
+}
+
+func (t *throttleFS) MkDirAll(name string) error {
+	return t.MkDirAllContext(context.Background(), name)
+}
+
+func (t *throttleFS) MkDirAllContext(ctx context.Context, name string) error {
+	if err := t.limiter.waitOp(ctx); err != nil {
+		return err
+	}
+	return writefs.MkDirAllContext(ctx, t.base, name)
+}
+
+func (t *throttleFS) RemoveAll(name string) error {
+	return t.RemoveAllContext(context.Background(), name)
+}
+
+func (t *throttleFS) RemoveAllContext(ctx context.Context, name string) error {
+	if err := t.limiter.waitOp(ctx); err != nil {
+		return err
+	}
+	return writefs.RemoveAllContext(ctx, t.base, name)
+}
+
+func (t *throttleFS) CopyFile(src, dst string) error {
+	cfs, ok := t.base.(writefs.CopyFileFS)
+	if !ok {
+		return errors.Wrap(writefs.ErrNotImplemented, "CopyFile")
+	}
+	if err := t.limiter.waitOp(context.Background()); err != nil {
+		return err
+	}
+	return cfs.CopyFile(src, dst)
+}
+
+func (t *throttleFS) Chtimes(name string, atime, mtime time.Time) error {
+	if err := t.limiter.waitOp(context.Background()); err != nil {
+		return err
+	}
+	return writefs.Chtimes(t.base, name, atime, mtime)
+}
+
+func (t *throttleFS) Chmod(name string, mode fs.FileMode) error {
+	if err := t.limiter.waitOp(context.Background()); err != nil {
+		return err
+	}
+	return writefs.Chmod(t.base, name, mode)
+}
+
+func (t *throttleFS) Symlink(oldname, newname string) error {
+	if err := t.limiter.waitOp(context.Background()); err != nil {
+		return err
+	}
+	return writefs.Symlink(t.base, oldname, newname)
+}
+
+func (t *throttleFS) ReadLink(name string) (string, error) {
+	if err := t.limiter.waitOp(context.Background()); err != nil {
+		return "", err
+	}
+	return writefs.ReadLink(t.base, name)
+}
+
+func (t *throttleFS) Lstat(name string) (fs.FileInfo, error) {
+	if err := t.limiter.waitOp(context.Background()); err != nil {
+		return nil, err
+	}
+	return writefs.Lstat(t.base, name)
+}
+
+func (t *throttleFS) Fullpath(name string) (string, error) {
+	return writefs.Fullpath(t.base, name)
+}
+
+func (t *throttleFS) Close() error {
+	return writefs.Close(t.base)
+}
+
+var (
+	_ writefs.ReadWriteFS           = &throttleFS{}
+	_ writefs.CreateContextFS       = &throttleFS{}
+	_ writefs.CreateAtomicFS        = &throttleFS{}
+	_ writefs.CreateAtomicContextFS = &throttleFS{}
+	_ writefs.OpenFileFS            = &throttleFS{}
+	_ writefs.OpenFileContextFS     = &throttleFS{}
+	_ writefs.OpenContextFS         = &throttleFS{}
+	_ writefs.StatContextFS         = &throttleFS{}
+	_ writefs.ReadDirContextFS      = &throttleFS{}
+	_ writefs.CreateWriterAtFS      = &throttleFS{}
+	_ writefs.MkDirFS               = &throttleFS{}
+	_ writefs.MkDirAllFS            = &throttleFS{}
+	_ writefs.RemoveFS              = &throttleFS{}
+	_ writefs.RemoveContextFS       = &throttleFS{}
+	_ writefs.RemoveAllFS           = &throttleFS{}
+	_ writefs.MkDirContextFS        = &throttleFS{}
+	_ writefs.MkDirAllContextFS     = &throttleFS{}
+	_ writefs.RemoveAllContextFS    = &throttleFS{}
+	_ writefs.RenameFS              = &throttleFS{}
+	_ writefs.RenameContextFS       = &throttleFS{}
+	_ writefs.CopyFileFS            = &throttleFS{}
+	_ writefs.ChtimesFS             = &throttleFS{}
+	_ writefs.ChmodFS               = &throttleFS{}
+	_ writefs.SymlinkFS             = &throttleFS{}
+	_ writefs.ReadLinkFS            = &throttleFS{}
+	_ writefs.LstatFS               = &throttleFS{}
+	_ writefs.FullpathFS            = &throttleFS{}
+	_ writefs.CloseFS               = &throttleFS{}
+	_ fs.ReadDirFS                  = &throttleFS{}
+	_ fs.ReadFileFS                 = &throttleFS{}
+	_ fs.StatFS                     = &throttleFS{}
+	_ fs.SubFS                      = &throttleFS{}
+	_ fmt.Stringer                  = &throttleFS{}
+)
@@ -0,0 +1,78 @@
+package throttlefs
+
+import (
+	"context"
+	"emperror.dev/errors"
+	"github.com/je4/filesystem/v3/pkg/memfsrw"
+	"github.com/je4/filesystem/v3/pkg/writefs"
+	"github.com/rs/zerolog"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestThrottleFS(t *testing.T) {
+	logger := zerolog.New(os.Stderr).With().Timestamp().Logger()
+	memFS, err := memfsrw.NewFS("throttle", &logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := strings.Repeat("x", 6000)
+	for _, name := range []string{"a.txt", "b.txt"} {
+		if _, err := writefs.WriteFile(memFS, name, []byte(data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the reads of both files share 10000 bytes per second with a burst of one second
+	throttleFS, err := NewFS(memFS, NewLimiter(10000, 0, 0), &logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	wg := sync.WaitGroup{}
+	for _, name := range []string{"a.txt", "b.txt"} {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			fp, err := throttleFS.Open(name)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			defer fp.Close()
+			if n, err := io.Copy(io.Discard, fp); err != nil || n != int64(len(data)) {
+				t.Errorf("cannot read '%s': %d bytes, %v", name, n, err)
+			}
+		}(name)
+	}
+	wg.Wait()
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+		t.Errorf("12000 bytes read in %v", elapsed)
+	}
+
+	// 10 operations per second with a burst of 10
+	opsFS, err := NewFS(memFS, NewLimiter(0, 0, 10), &logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start = time.Now()
+	for i := 0; i < 12; i++ {
+		if _, err := fs.Stat(opsFS, "a.txt"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+		t.Errorf("12 operations in %v", elapsed)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := writefs.StatContext(ctx, opsFS, "a.txt"); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
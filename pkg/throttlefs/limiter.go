@@ -0,0 +1,59 @@
+package throttlefs
+
+import (
+	"context"
+	"emperror.dev/errors"
+	"golang.org/x/time/rate"
+	"math"
+)
+
+// Limiter limits the bytes per second of reads and writes and the operations per second.
+// All filesystems and files using the same limiter share its limits.
+type Limiter struct {
+	read  *rate.Limiter
+	write *rate.Limiter
+	ops   *rate.Limiter
+}
+
+// NewLimiter creates a limiter, which allows bursts of one second. A limit of 0 means unlimited
+func NewLimiter(readBytesPerSecond, writeBytesPerSecond int64, opsPerSecond float64) *Limiter {
+	return &Limiter{
+		read:  newRate(float64(readBytesPerSecond)),
+		write: newRate(float64(writeBytesPerSecond)),
+		ops:   newRate(opsPerSecond),
+	}
+}
+
+func newRate(limit float64) *rate.Limiter {
+	if limit <= 0 {
+		return nil
+	}
+	return rate.NewLimiter(rate.Limit(limit), max(1, int(math.Ceil(limit))))
+}
+
+// wait blocks until n tokens are available. n may exceed the burst of limiter
+func wait(ctx context.Context, limiter *rate.Limiter, n int) error {
+	if limiter == nil {
+		return nil
+	}
+	for n > 0 {
+		chunk := min(n, limiter.Burst())
+		if err := limiter.WaitN(ctx, chunk); err != nil {
+			return errors.Wrap(err, "throttling")
+		}
+		n -= chunk
+	}
+	return nil
+}
+
+func (l *Limiter) waitOp(ctx context.Context) error {
+	return wait(ctx, l.ops, 1)
+}
+
+func (l *Limiter) waitRead(ctx context.Context, n int) error {
+	return wait(ctx, l.read, n)
+}
+
+func (l *Limiter) waitWrite(ctx context.Context, n int) error {
+	return wait(ctx, l.write, n)
+}
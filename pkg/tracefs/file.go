@@ -0,0 +1,48 @@
+package tracefs
+
+import (
+	"github.com/je4/filesystem/v3/pkg/writefs"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"sync"
+)
+
+// fileWrite ends the span of its creation on Close or Abort
+type fileWrite struct {
+	writefs.FileWrite
+	span    trace.Span
+	written int64
+	once    sync.Once
+}
+
+func (fw *fileWrite) Write(p []byte) (int, error) {
+	n, err := fw.FileWrite.Write(p)
+	fw.written += int64(n)
+	return n, err
+}
+
+func (fw *fileWrite) end(err error) {
+	fw.once.Do(func() {
+		fw.span.SetAttributes(attribute.Int64("fs.bytes", fw.written))
+		end(fw.span, err)
+	})
+}
+
+func (fw *fileWrite) Close() error {
+	err := fw.FileWrite.Close()
+	fw.end(err)
+	return err
+}
+
+func (fw *fileWrite) Abort() error {
+	err := writefs.Abort(fw.FileWrite)
+	if err == nil {
+		fw.span.AddEvent("aborted")
+		fw.end(nil)
+	}
+	return err
+}
+
+var (
+	_ writefs.AbortFileWrite = &fileWrite{}
+)
@@ -0,0 +1,303 @@
+// Package tracefs creates OpenTelemetry spans for the operations of a filesystem
+package tracefs
+
+import (
+	"context"
+	"emperror.dev/errors"
+	"fmt"
+	"github.com/je4/filesystem/v3/pkg/writefs"
+	"github.com/je4/utils/v2/pkg/zLogger"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"io"
+	"io/fs"
+	"os"
+	"time"
+)
+
+// NewFS wraps base and creates a span named "fs.<operation>" for every operation.
+// Without provider, the global tracer provider is used, which does nothing unless it has been configured.
+// The context aware functions create child spans of the span in their context.
+func NewFS(base fs.FS, name string, provider trace.TracerProvider, logger zLogger.ZLogger) (*traceFS, error) {
+	_logger := logger.With().Str("class", "traceFS").Logger()
+	logger = &_logger
+	if provider == nil {
+		provider = otel.GetTracerProvider()
+	}
+	return &traceFS{
+		base:   base,
+		name:   name,
+		tracer: provider.Tracer(InstrumentationName),
+		logger: logger,
+	}, nil
+}
+
+type traceFS struct {
+	base   fs.FS
+	name   string
+	tracer trace.Tracer
+	logger zLogger.ZLogger
+}
+
+func (t *traceFS) start(ctx context.Context, op string, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	attrs = append(attrs, attribute.String("fs.name", t.name), attribute.String("fs.path", name))
+	return t.tracer.Start(ctx, "fs."+op, trace.WithAttributes(attrs...))
+}
+
+// end records err in span and ends it
+func end(span trace.Span, err error) {
+	if err != nil && !errors.Is(err, io.EOF) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+func (t *traceFS) String() string {
+	return fmt.Sprintf("traceFS(%v)", t.base)
+}
+
+func (t *traceFS) Sub(dir string) (fs.FS, error) {
+	return writefs.NewSubFS(t, dir), nil
+}
+
+func (t *traceFS) Open(name string) (fs.File, error) {
+	return t.OpenContext(context.Background(), name)
+}
+
+func (t *traceFS) OpenContext(ctx context.Context, name string) (fs.File, error) {
+	ctx, span := t.start(ctx, "Open", name)
+	fp, err := writefs.OpenContext(ctx, t.base, name)
+	end(span, err)
+	return fp, err
+}
+
+func (t *traceFS) ReadFile(name string) ([]byte, error) {
+	_, span := t.start(context.Background(), "ReadFile", name)
+	data, err := fs.ReadFile(t.base, name)
+	span.SetAttributes(attribute.Int("fs.bytes", len(data)))
+	end(span, err)
+	return data, err
+}
+
+func (t *traceFS) Stat(name string) (fs.FileInfo, error) {
+	return t.StatContext(context.Background(), name)
+}
+
+func (t *traceFS) StatContext(ctx context.Context, name string) (fs.FileInfo, error) {
+	ctx, span := t.start(ctx, "Stat", name)
+	info, err := writefs.StatContext(ctx, t.base, name)
+	end(span, err)
+	return info, err
+}
+
+func (t *traceFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	return t.ReadDirContext(context.Background(), name)
+}
+
+func (t *traceFS) ReadDirContext(ctx context.Context, name string) ([]fs.DirEntry, error) {
+	ctx, span := t.start(ctx, "ReadDir", name)
+	entries, err := writefs.ReadDirContext(ctx, t.base, name)
+	span.SetAttributes(attribute.Int("fs.entries", len(entries)))
+	end(span, err)
+	return entries, err
+}
+
+// newFileWrite ends span on a failed creation or keeps it open until the file is closed
+func newFileWrite(span trace.Span, fp writefs.FileWrite, err error) (writefs.FileWrite, error) {
+	if err != nil {
+		end(span, err)
+		return nil, err
+	}
+	return &fileWrite{FileWrite: fp, span: span}, nil
+}
+
+func (t *traceFS) Create(name string) (writefs.FileWrite, error) {
+	return t.CreateContext(context.Background(), name)
+}
+
+// CreateContext creates a span, which ends when the file is closed
+func (t *traceFS) CreateContext(ctx context.Context, name string) (writefs.FileWrite, error) {
+	ctx, span := t.start(ctx, "Create", name)
+	fp, err := writefs.CreateContext(ctx, t.base, name)
+	return newFileWrite(span, fp, err)
+}
+
+func (t *traceFS) CreateAtomic(name string) (writefs.FileWrite, error) {
+	return t.CreateAtomicContext(context.Background(), name)
+}
+
+func (t *traceFS) CreateAtomicContext(ctx context.Context, name string) (writefs.FileWrite, error) {
+	ctx, span := t.start(ctx, "CreateAtomic", name)
+	fp, err := writefs.CreateAtomicContext(ctx, t.base, name)
+	return newFileWrite(span, fp, err)
+}
+
+func (t *traceFS) OpenFile(name string, flag int, perm fs.FileMode) (writefs.FileWrite, error) {
+	return t.OpenFileContext(context.Background(), name, flag, perm)
+}
+
+func (t *traceFS) OpenFileContext(ctx context.Context, name string, flag int, perm fs.FileMode) (writefs.FileWrite, error) {
+	ctx, span := t.start(ctx, "OpenFile", name, attribute.Int("fs.flag", flag))
+	fp, err := writefs.OpenFileContext(ctx, t.base, name, flag, perm)
+	if flag&(os.O_WRONLY|os.O_RDWR) == 0 {
+		end(span, err)
+		return fp, err
+	}
+	return newFileWrite(span, fp, err)
+}
+
+func (t *traceFS) CreateWriterAt(name string) (writefs.FileWriterAt, error) {
+	_, span := t.start(context.Background(), "CreateWriterAt", name)
+	fp, err := writefs.CreateWriterAt(t.base, name)
+	end(span, err)
+	return fp, err
+}
+
+func (t *traceFS) Remove(name string) error {
+	return t.RemoveContext(context.Background(), name)
+}
+
+func (t *traceFS) RemoveContext(ctx context.Context, name string) error {
+	ctx, span := t.start(ctx, "Remove", name)
+	err := writefs.RemoveContext(ctx, t.base, name)
+	end(span, err)
+	return err
+}
+
+func (t *traceFS) Rename(oldPath, newPath string) error {
+	return t.RenameContext(context.Background(), oldPath, newPath)
+}
+
+func (t *traceFS) RenameContext(ctx context.Context, oldPath, newPath string) error {
+	ctx, span := t.start(ctx, "Rename", oldPath, attribute.String("fs.new_path", newPath))
+	err := writefs.RenameContext(ctx, t.base, oldPath, newPath)
+	end(span, err)
+	return err
+}
+
+func (t *traceFS) MkDir(name string) error {
+	return t.MkDirContext(context.Background(), name)
+}
+
+func (t *traceFS) MkDirContext(ctx context.Context, name string) error {
+	ctx, span := t.start(ctx, "MkDir", name)
+	err := writefs.MkDirContext(ctx, t.base, name)
+	end(span, err)
+	return err
+}
+
+func (t *traceFS) MkDirAll(name string) error {
+	return t.MkDirAllContext(context.Background(), name)
+}
+
+func (t *traceFS) MkDirAllContext(ctx context.Context, name string) error {
+	ctx, span := t.start(ctx, "MkDirAll", name)
+	err := writefs.MkDirAllContext(ctx, t.base, name)
+	end(span, err)
+	return err
+}
+
+func (t *traceFS) RemoveAll(name string) error {
+	return t.RemoveAllContext(context.Background(), name)
+}
+
+func (t *traceFS) RemoveAllContext(ctx context.Context, name string) error {
+	ctx, span := t.start(ctx, "RemoveAll", name)
+	err := writefs.RemoveAllContext(ctx, t.base, name)
+	end(span, err)
+	return err
+}
+
+func (t *traceFS) CopyFile(src, dst string) error {
+	_, span := t.start(context.Background(), "CopyFile", src, attribute.String("fs.new_path", dst))
+	var err error
+	if cfs, ok := t.base.(writefs.CopyFileFS); ok {
+		err = cfs.CopyFile(src, dst)
+	} else {
+		err = errors.Wrap(writefs.ErrNotImplemented, "CopyFile")
+	}
+	end(span, err)
+	return err
+}
+
+func (t *traceFS) Chtimes(name string, atime, mtime time.Time) error {
+	_, span := t.start(context.Background(), "Chtimes", name)
+	err := writefs.Chtimes(t.base, name, atime, mtime)
+	end(span, err)
+	return err
+}
+
+func (t *traceFS) Chmod(name string, mode fs.FileMode) error {
+	_, span := t.start(context.Background(), "Chmod", name)
+	err := writefs.Chmod(t.base, name, mode)
+	end(span, err)
+	return err
+}
+
+func (t *traceFS) Symlink(oldname, newname string) error {
+	_, span := t.start(context.Background(), "Symlink", newname)
+	err := writefs.Symlink(t.base, oldname, newname)
+	end(span, err)
+	return err
+}
+
+func (t *traceFS) ReadLink(name string) (string, error) {
+	_, span := t.start(context.Background(), "ReadLink", name)
+	dest, err := writefs.ReadLink(t.base, name)
+	end(span, err)
+	return dest, err
+}
+
+func (t *traceFS) Lstat(name string) (fs.FileInfo, error) {
+	_, span := t.start(context.Background(), "Lstat", name)
+	info, err := writefs.Lstat(t.base, name)
+	end(span, err)
+	return info, err
+}
+
+func (t *traceFS) Fullpath(name string) (string, error) {
+	return writefs.Fullpath(t.base, name)
+}
+
+func (t *traceFS) Close() error {
+	return writefs.Close(t.base)
+}
+
+var (
+	_ writefs.ReadWriteFS           = &traceFS{}
+	_ writefs.CreateContextFS       = &traceFS{}
+	_ writefs.CreateAtomicFS        = &traceFS{}
+	_ writefs.CreateAtomicContextFS = &traceFS{}
+	_ writefs.OpenFileFS            = &traceFS{}
+	_ writefs.OpenFileContextFS     = &traceFS{}
+	_ writefs.OpenContextFS         = &traceFS{}
+	_ writefs.StatContextFS         = &traceFS{}
+	_ writefs.ReadDirContextFS      = &traceFS{}
+	_ writefs.CreateWriterAtFS      = &traceFS{}
+	_ writefs.MkDirFS               = &traceFS{}
+	_ writefs.MkDirAllFS            = &traceFS{}
+	_ writefs.RemoveFS              = &traceFS{}
+	_ writefs.RemoveContextFS       = &traceFS{}
+	_ writefs.RemoveAllFS           = &traceFS{}
+	_ writefs.MkDirContextFS        = &traceFS{}
+	_ writefs.MkDirAllContextFS     = &traceFS{}
+	_ writefs.RemoveAllContextFS    = &traceFS{}
+	_ writefs.RenameFS              = &traceFS{}
+	_ writefs.RenameContextFS       = &traceFS{}
+	_ writefs.CopyFileFS            = &traceFS{}
+	_ writefs.ChtimesFS             = &traceFS{}
+	_ writefs.ChmodFS               = &traceFS{}
+	_ writefs.SymlinkFS             = &traceFS{}
+	_ writefs.ReadLinkFS            = &traceFS{}
+	_ writefs.LstatFS               = &traceFS{}
+	_ writefs.FullpathFS            = &traceFS{}
+	_ writefs.CloseFS               = &traceFS{}
+	_ fs.ReadDirFS                  = &traceFS{}
+	_ fs.ReadFileFS                 = &traceFS{}
+	_ fs.StatFS                     = &traceFS{}
+	_ fs.SubFS                      = &traceFS{}
+	_ fmt.Stringer                  = &traceFS{}
+)
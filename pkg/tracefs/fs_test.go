@@ -0,0 +1,75 @@
+package tracefs
+
+import (
+	"context"
+	"emperror.dev/errors"
+	"github.com/je4/filesystem/v3/pkg/memfsrw"
+	"github.com/je4/filesystem/v3/pkg/writefs"
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"io/fs"
+	"os"
+	"testing"
+)
+
+func TestTraceFS(t *testing.T) {
+	logger := zerolog.New(os.Stderr).With().Timestamp().Logger()
+	memFS, err := memfsrw.NewFS("trace", &logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	provider, exporter := NewInMemoryTracerProvider()
+	traceFS, err := NewFS(memFS, "test", provider, &logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, parent := provider.Tracer("test").Start(context.Background(), "test")
+	fp, err := writefs.CreateContext(ctx, traceFS, "a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fp.Write([]byte("0123456789")); err != nil {
+		t.Fatal(err)
+	}
+	if len(exporter.GetSpans()) != 0 {
+		t.Errorf("span of Create ended before Close")
+	}
+	if err := fp.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := writefs.StatContext(ctx, traceFS, "missing.txt"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected fs.ErrNotExist, got %v", err)
+	}
+	parent.End()
+
+	spans := exporter.GetSpans()
+	if len(spans) != 3 {
+		t.Fatalf("expected 3 spans, got %d", len(spans))
+	}
+	create, stat := spans[0], spans[1]
+	for _, span := range []struct {
+		name string
+		got  string
+	}{{"fs.Create", create.Name}, {"fs.Stat", stat.Name}} {
+		if span.name != span.got {
+			t.Errorf("expected span '%s', got '%s'", span.name, span.got)
+		}
+	}
+	if create.Parent.SpanID() != parent.SpanContext().SpanID() || stat.Parent.SpanID() != parent.SpanContext().SpanID() {
+		t.Errorf("spans are not children of the span in the context")
+	}
+	bytes := false
+	for _, attr := range create.Attributes {
+		if attr == attribute.Int64("fs.bytes", 10) {
+			bytes = true
+		}
+	}
+	if !bytes {
+		t.Errorf("written bytes not recorded in %v", create.Attributes)
+	}
+	if stat.Status.Code != codes.Error {
+		t.Errorf("expected error status, got %v", stat.Status.Code)
+	}
+}
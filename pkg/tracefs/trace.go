@@ -0,0 +1,19 @@
+package tracefs
+
+import (
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// InstrumentationName is the name of the tracers of this module
+const InstrumentationName = "github.com/je4/filesystem/v3"
+
+// Propagator transports the trace context in the http headers between remotefs client and server
+var Propagator propagation.TextMapPropagator = propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+
+// NewInMemoryTracerProvider returns a provider, which keeps all spans in the returned exporter, e.g. for tests
+func NewInMemoryTracerProvider() (*sdktrace.TracerProvider, *tracetest.InMemoryExporter) {
+	exporter := tracetest.NewInMemoryExporter()
+	return sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)), exporter
+}
@@ -0,0 +1,63 @@
+package unionfs
+
+import (
+	"context"
+	"emperror.dev/errors"
+	"github.com/je4/filesystem/v3/pkg/writefs"
+	"io"
+	"io/fs"
+)
+
+// dir is a folder with the merged entries of both layers
+type dir struct {
+	info    fs.FileInfo
+	entries []fs.DirEntry
+	offset  int
+}
+
+func (d *dir) Stat() (fs.FileInfo, error) {
+	return d.info, nil
+}
+
+func (d *dir) Read([]byte) (int, error) {
+	return 0, errors.Wrapf(fs.ErrInvalid, "'%s' is a directory", d.info.Name())
+}
+
+func (d *dir) Close() error {
+	return nil
+}
+
+func (d *dir) ReadDir(n int) ([]fs.DirEntry, error) {
+	entries := d.entries[d.offset:]
+	if n > 0 {
+		if len(entries) == 0 {
+			return nil, io.EOF
+		}
+		entries = entries[:min(n, len(entries))]
+	}
+	d.offset += len(entries)
+	return entries, nil
+}
+
+// fileWrite removes the whiteouts of the new file after it has been written to the upper layer
+type fileWrite struct {
+	writefs.FileWrite
+	u    *unionFS
+	name string
+}
+
+func (fw *fileWrite) Close() error {
+	if err := fw.FileWrite.Close(); err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(fw.u.unwhiteout(context.Background(), fw.name, false))
+}
+
+func (fw *fileWrite) Abort() error {
+	return writefs.Abort(fw.FileWrite)
+}
+
+var (
+	_ fs.ReadDirFile         = &dir{}
+	_ writefs.AbortFileWrite = &fileWrite{}
+)
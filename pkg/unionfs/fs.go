@@ -0,0 +1,457 @@
+// Package unionfs stacks a read-only lower filesystem under a writable upper filesystem.
+// Reads fall through to the lower layer, writes go to the upper layer and deletions of lower
+// files are recorded as whiteout markers in the upper layer.
+package unionfs
+
+import (
+	"context"
+	"emperror.dev/errors"
+	"fmt"
+	"github.com/je4/filesystem/v3/pkg/writefs"
+	"github.com/je4/utils/v2/pkg/zLogger"
+	"golang.org/x/exp/slices"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+)
+
+// NewFS creates a union of lower and upper. lower is never modified.
+func NewFS(lower fs.FS, upper writefs.ReadWriteFS, logger zLogger.ZLogger) (*unionFS, error) {
+	_logger := logger.With().Str("class", "unionFS").Logger()
+	logger = &_logger
+	return &unionFS{
+		lower:  lower,
+		upper:  upper,
+		logger: logger,
+	}, nil
+}
+
+type unionFS struct {
+	lower  fs.FS
+	upper  writefs.ReadWriteFS
+	logger zLogger.ZLogger
+}
+
+func (u *unionFS) String() string {
+	return fmt.Sprintf("unionFS(%v, %v)", u.lower, u.upper)
+}
+
+func (u *unionFS) Sub(dir string) (fs.FS, error) {
+	return writefs.NewSubFS(u, dir), nil
+}
+
+// layer returns the filesystem which provides name
+func (u *unionFS) layer(ctx context.Context, name string) (fs.FS, error) {
+	inUpper, _, err := u.lookup(ctx, name)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if inUpper {
+		return u.upper, nil
+	}
+	return u.lower, nil
+}
+
+func (u *unionFS) Stat(name string) (fs.FileInfo, error) {
+	return u.StatContext(context.Background(), name)
+}
+
+func (u *unionFS) StatContext(ctx context.Context, name string) (fs.FileInfo, error) {
+	fsys, err := u.layer(ctx, name)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	info, err := writefs.StatContext(ctx, fsys, name)
+	return info, errors.Wrapf(err, "cannot stat '%s'", name)
+}
+
+// Open opens name of the upper or the lower layer. Folders contain the merged entries of both layers.
+func (u *unionFS) Open(name string) (fs.File, error) {
+	return u.OpenContext(context.Background(), name)
+}
+
+func (u *unionFS) OpenContext(ctx context.Context, name string) (fs.File, error) {
+	fsys, err := u.layer(ctx, name)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	info, err := writefs.StatContext(ctx, fsys, name)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot stat '%s'", name)
+	}
+	if !info.IsDir() {
+		fp, err := writefs.OpenContext(ctx, fsys, name)
+		return fp, errors.Wrapf(err, "cannot open '%s'", name)
+	}
+	entries, err := u.ReadDirContext(ctx, name)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return &dir{
+		info:    info,
+		entries: entries,
+	}, nil
+}
+
+// ReadDir merges the entries of name in both layers. Entries of the upper layer hide those of the lower layer.
+func (u *unionFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	return u.ReadDirContext(context.Background(), name)
+}
+
+func (u *unionFS) ReadDirContext(ctx context.Context, name string) ([]fs.DirEntry, error) {
+	inUpper, inLower, err := u.lookup(ctx, name)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	result := []fs.DirEntry{}
+	hidden := map[string]bool{}
+	if inUpper {
+		entries, err := writefs.ReadDirContext(ctx, u.upper, name)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot read directory '%s' of upper layer", name)
+		}
+		for _, entry := range entries {
+			if entry.Name() == opaqueMarker {
+				inLower = false
+				continue
+			}
+			if deleted, ok := strings.CutPrefix(entry.Name(), whiteoutPrefix); ok {
+				hidden[deleted] = true
+				continue
+			}
+			hidden[entry.Name()] = true
+			result = append(result, entry)
+		}
+	}
+	if inLower {
+		entries, err := writefs.ReadDirContext(ctx, u.lower, name)
+		if err != nil && !inUpper {
+			return nil, errors.Wrapf(err, "cannot read directory '%s' of lower layer", name)
+		}
+		for _, entry := range entries {
+			if !hidden[entry.Name()] {
+				result = append(result, entry)
+			}
+		}
+	}
+	slices.SortFunc(result, func(a, b fs.DirEntry) int {
+		return strings.Compare(a.Name(), b.Name())
+	})
+	return result, nil
+}
+
+// Create writes name to the upper layer. The file becomes visible on Close.
+func (u *unionFS) Create(name string) (writefs.FileWrite, error) {
+	return u.CreateContext(context.Background(), name)
+}
+
+func (u *unionFS) CreateContext(ctx context.Context, name string) (writefs.FileWrite, error) {
+	if err := u.checkWritable(name); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	fp, err := writefs.CreateContext(ctx, u.upper, name)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot create '%s'", name)
+	}
+	return u.newFileWrite(fp, name), nil
+}
+
+// CreateAtomic writes name atomically to the upper layer
+func (u *unionFS) CreateAtomic(name string) (writefs.FileWrite, error) {
+	return u.CreateAtomicContext(context.Background(), name)
+}
+
+func (u *unionFS) CreateAtomicContext(ctx context.Context, name string) (writefs.FileWrite, error) {
+	if err := u.checkWritable(name); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	fp, err := writefs.CreateAtomicContext(ctx, u.upper, name)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot create '%s'", name)
+	}
+	return u.newFileWrite(fp, name), nil
+}
+
+// OpenFile opens name of the upper layer with the flags of os.OpenFile.
+// os.O_EXCL fails for files of both layers. Files of the lower layer are copied to the upper layer,
+// unless they are truncated.
+func (u *unionFS) OpenFile(name string, flag int, perm fs.FileMode) (writefs.FileWrite, error) {
+	return u.OpenFileContext(context.Background(), name, flag, perm)
+}
+
+func (u *unionFS) OpenFileContext(ctx context.Context, name string, flag int, perm fs.FileMode) (writefs.FileWrite, error) {
+	if err := u.checkWritable(name); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	inUpper, inLower, err := u.lookup(ctx, name)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, errors.WithStack(err)
+	}
+	if (inUpper || inLower) && flag&os.O_EXCL != 0 {
+		return nil, errors.Wrapf(fs.ErrExist, "'%s' already exists", name)
+	}
+	if inLower && !inUpper && flag&os.O_TRUNC == 0 {
+		if err := u.copyUp(ctx, name); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+	fp, err := writefs.OpenFileContext(ctx, u.upper, name, flag, perm)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot open '%s'", name)
+	}
+	return u.newFileWrite(fp, name), nil
+}
+
+// copyUp copies the file name of the lower layer to the upper layer
+func (u *unionFS) copyUp(ctx context.Context, name string) error {
+	src, err := writefs.OpenContext(ctx, u.lower, name)
+	if err != nil {
+		return errors.Wrapf(err, "cannot open '%s' of lower layer", name)
+	}
+	defer src.Close()
+	dst, err := writefs.CreateAtomicContext(ctx, u.upper, name)
+	if err != nil {
+		return errors.Wrapf(err, "cannot create '%s' in upper layer", name)
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		if err := writefs.Abort(dst); err != nil {
+			dst.Close()
+		}
+		return errors.Wrapf(err, "cannot copy '%s' to upper layer", name)
+	}
+	return errors.Wrapf(dst.Close(), "cannot close '%s'", name)
+}
+
+func (u *unionFS) newFileWrite(fp writefs.FileWrite, name string) *fileWrite {
+	return &fileWrite{
+		FileWrite: fp,
+		u:         u,
+		name:      name,
+	}
+}
+
+// checkWritable rejects invalid paths and the names of whiteout markers
+func (u *unionFS) checkWritable(name string) error {
+	if !fs.ValidPath(name) || name == "." {
+		return errors.Wrapf(fs.ErrInvalid, "invalid path '%s'", name)
+	}
+	for _, elem := range strings.Split(name, "/") {
+		if strings.HasPrefix(elem, whiteoutPrefix) {
+			return errors.Wrapf(fs.ErrInvalid, "reserved name '%s'", name)
+		}
+	}
+	return nil
+}
+
+// MkDir creates the folder name in the upper layer. The parent folder must exist in one of the layers.
+func (u *unionFS) MkDir(name string) error {
+	return u.MkDirContext(context.Background(), name)
+}
+
+func (u *unionFS) MkDirContext(ctx context.Context, name string) error {
+	if err := u.checkWritable(name); err != nil {
+		return errors.WithStack(err)
+	}
+	if _, _, err := u.lookup(ctx, name); err == nil {
+		return errors.Wrapf(fs.ErrExist, "cannot create folder '%s'", name)
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return errors.WithStack(err)
+	}
+	if _, _, err := u.lookup(ctx, path.Dir(name)); err != nil {
+		return errors.Wrapf(err, "cannot create folder '%s'", name)
+	}
+	if err := writefs.MkDirAllContext(ctx, u.upper, name); err != nil {
+		return errors.Wrapf(err, "cannot create folder '%s'", name)
+	}
+	return errors.WithStack(u.unwhiteout(ctx, name, true))
+}
+
+// Remove removes a file or an empty folder. Files of the lower layer are hidden by a whiteout marker.
+func (u *unionFS) Remove(name string) error {
+	return u.RemoveContext(context.Background(), name)
+}
+
+func (u *unionFS) RemoveContext(ctx context.Context, name string) error {
+	if err := u.checkWritable(name); err != nil {
+		return errors.WithStack(err)
+	}
+	inUpper, inLower, err := u.lookup(ctx, name)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	info, err := u.StatContext(ctx, name)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if info.IsDir() {
+		entries, err := u.ReadDirContext(ctx, name)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		if len(entries) > 0 {
+			return errors.Errorf("cannot remove '%s': directory not empty", name)
+		}
+	}
+	if inUpper {
+		// folders may still contain whiteout markers
+		if err := writefs.RemoveAllContext(ctx, u.upper, name); err != nil {
+			return errors.Wrapf(err, "cannot remove '%s' from upper layer", name)
+		}
+	}
+	if inLower {
+		return errors.WithStack(u.delete(ctx, name))
+	}
+	return nil
+}
+
+// RemoveAll removes name with all its content. A missing name is not an error.
+func (u *unionFS) RemoveAll(name string) error {
+	return u.RemoveAllContext(context.Background(), name)
+}
+
+func (u *unionFS) RemoveAllContext(ctx context.Context, name string) error {
+	if err := u.checkWritable(name); err != nil {
+		return errors.WithStack(err)
+	}
+	inUpper, inLower, err := u.lookup(ctx, name)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return errors.WithStack(err)
+	}
+	if inUpper {
+		if err := writefs.RemoveAllContext(ctx, u.upper, name); err != nil {
+			return errors.Wrapf(err, "cannot remove '%s' from upper layer", name)
+		}
+	}
+	if inLower {
+		return errors.WithStack(u.delete(ctx, name))
+	}
+	return nil
+}
+
+// Rename moves the file oldPath to newPath. Files of the upper layer are renamed within the upper layer,
+// files of the lower layer are copied to the upper layer and hidden afterwards.
+// Folders cannot be renamed.
+func (u *unionFS) Rename(oldPath, newPath string) error {
+	return u.RenameContext(context.Background(), oldPath, newPath)
+}
+
+func (u *unionFS) RenameContext(ctx context.Context, oldPath, newPath string) error {
+	if path.Clean(oldPath) == path.Clean(newPath) {
+		return nil
+	}
+	if err := u.checkWritable(newPath); err != nil {
+		return errors.WithStack(err)
+	}
+	inUpper, inLower, err := u.lookup(ctx, oldPath)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	info, err := u.StatContext(ctx, oldPath)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if info.IsDir() {
+		return errors.Wrapf(writefs.ErrNotImplemented, "Rename of folder '%s'", oldPath)
+	}
+	if inUpper {
+		if err := u.upperParent(ctx, newPath); err != nil {
+			return errors.WithStack(err)
+		}
+		err := writefs.RenameContext(ctx, u.upper, oldPath, newPath)
+		if err == nil {
+			if err := u.unwhiteout(ctx, newPath, false); err != nil {
+				return errors.WithStack(err)
+			}
+			if inLower {
+				return errors.WithStack(u.delete(ctx, oldPath))
+			}
+			return nil
+		}
+		if !errors.Is(err, writefs.ErrNotImplemented) {
+			return errors.Wrapf(err, "cannot rename '%s' to '%s'", oldPath, newPath)
+		}
+	}
+	src, err := u.OpenContext(ctx, oldPath)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer src.Close()
+	dst, err := u.CreateAtomicContext(ctx, newPath)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		if err := writefs.Abort(dst); err != nil {
+			dst.Close()
+		}
+		return errors.Wrapf(err, "cannot copy '%s' to '%s'", oldPath, newPath)
+	}
+	if err := dst.Close(); err != nil {
+		return errors.Wrapf(err, "cannot close '%s'", newPath)
+	}
+	return errors.WithStack(u.RemoveContext(ctx, oldPath))
+}
+
+// CopyFile copies files of the upper layer within the upper layer.
+// Files of the lower layer return writefs.ErrNotImplemented, so that they are streamed by writefs.CopyFS.
+func (u *unionFS) CopyFile(src, dst string) error {
+	ctx := context.Background()
+	if err := u.checkWritable(dst); err != nil {
+		return errors.WithStack(err)
+	}
+	inUpper, _, err := u.lookup(ctx, src)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	cfs, ok := u.upper.(writefs.CopyFileFS)
+	if !inUpper || !ok {
+		return errors.Wrapf(writefs.ErrNotImplemented, "CopyFile of '%s'", src)
+	}
+	if err := u.upperParent(ctx, dst); err != nil {
+		return errors.WithStack(err)
+	}
+	if err := cfs.CopyFile(src, dst); err != nil {
+		return errors.Wrapf(err, "cannot copy '%s' to '%s'", src, dst)
+	}
+	return errors.WithStack(u.unwhiteout(ctx, dst, false))
+}
+
+// upperParent creates the parent folder of name in the upper layer
+func (u *unionFS) upperParent(ctx context.Context, name string) error {
+	parent := path.Dir(name)
+	if parent == "." {
+		return nil
+	}
+	return errors.Wrapf(writefs.MkDirAllContext(ctx, u.upper, parent), "cannot create folder '%s'", parent)
+}
+
+var (
+	_ writefs.ReadWriteFS    = &unionFS{}
+	_ writefs.CreateAtomicFS = &unionFS{}
+	_ writefs.OpenFileFS     = &unionFS{}
+	_ writefs.MkDirFS        = &unionFS{}
+	_ writefs.RenameFS       = &unionFS{}
+	_ writefs.RemoveFS       = &unionFS{}
+	_ writefs.RemoveAllFS    = &unionFS{}
+	_ writefs.CopyFileFS     = &unionFS{}
+	_ fs.ReadDirFS           = &unionFS{}
+	_ fs.StatFS              = &unionFS{}
+	_ fs.SubFS               = &unionFS{}
+	_ fmt.Stringer           = &unionFS{}
+
+	_ writefs.CreateContextFS       = &unionFS{}
+	_ writefs.CreateAtomicContextFS = &unionFS{}
+	_ writefs.OpenFileContextFS     = &unionFS{}
+	_ writefs.OpenContextFS         = &unionFS{}
+	_ writefs.StatContextFS         = &unionFS{}
+	_ writefs.ReadDirContextFS      = &unionFS{}
+	_ writefs.MkDirContextFS        = &unionFS{}
+	_ writefs.RemoveContextFS       = &unionFS{}
+	_ writefs.RemoveAllContextFS    = &unionFS{}
+	_ writefs.RenameContextFS       = &unionFS{}
+)
@@ -0,0 +1,151 @@
+package unionfs
+
+import (
+	"emperror.dev/errors"
+	"github.com/je4/filesystem/v3/pkg/memfsrw"
+	"github.com/je4/filesystem/v3/pkg/writefs"
+	"github.com/je4/filesystem/v3/pkg/writefs/writefstest"
+	"github.com/rs/zerolog"
+	"golang.org/x/exp/slices"
+	"io/fs"
+	"os"
+	"testing"
+	"testing/fstest"
+)
+
+func TestUnionFS(t *testing.T) {
+	logger := zerolog.New(os.Stderr).With().Timestamp().Logger()
+	lower := fstest.MapFS{
+		"data/a.txt":       {Data: []byte("lower a")},
+		"data/b.txt":       {Data: []byte("lower b")},
+		"data/sub/c.txt":   {Data: []byte("lower c")},
+		"archive/file.txt": {Data: []byte("archived")},
+	}
+	upper, err := memfsrw.NewFS("upper", &logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unionFS, err := NewFS(lower, upper, &logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkFile := func(t *testing.T, name, content string) {
+		t.Helper()
+		data, err := fs.ReadFile(unionFS, name)
+		if err != nil {
+			t.Fatalf("cannot read '%s': %v", name, err)
+		}
+		if string(data) != content {
+			t.Errorf("wrong content of '%s': expected '%s', got '%s'", name, content, data)
+		}
+	}
+	checkDir := func(t *testing.T, name string, expected ...string) {
+		t.Helper()
+		entries, err := fs.ReadDir(unionFS, name)
+		if err != nil {
+			t.Fatalf("cannot read directory '%s': %v", name, err)
+		}
+		names := []string{}
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+		if !slices.Equal(names, expected) {
+			t.Errorf("wrong entries in '%s': expected %v, got %v", name, expected, names)
+		}
+	}
+
+	writefstest.TestReadWriteFS(t, unionFS, nil)
+
+	t.Run("read through", func(t *testing.T) {
+		checkFile(t, "data/a.txt", "lower a")
+		checkDir(t, "data", "a.txt", "b.txt", "sub")
+	})
+
+	t.Run("write", func(t *testing.T) {
+		if _, err := writefs.WriteFile(unionFS, "data/a.txt", []byte("upper a")); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := writefs.WriteFile(unionFS, "data/new.txt", []byte("new")); err != nil {
+			t.Fatal(err)
+		}
+		checkFile(t, "data/a.txt", "upper a")
+		checkDir(t, "data", "a.txt", "b.txt", "new.txt", "sub")
+		if string(lower["data/a.txt"].Data) != "lower a" {
+			t.Errorf("lower layer has been modified")
+		}
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		for _, name := range []string{"data/a.txt", "data/b.txt"} {
+			if err := writefs.Remove(unionFS, name); err != nil {
+				t.Fatalf("cannot remove '%s': %v", name, err)
+			}
+			if _, err := fs.Stat(unionFS, name); !errors.Is(err, fs.ErrNotExist) {
+				t.Errorf("removed file '%s': expected fs.ErrNotExist, got %v", name, err)
+			}
+		}
+		checkDir(t, "data", "new.txt", "sub")
+		if err := writefs.Remove(unionFS, "data/a.txt"); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("remove of deleted file: expected fs.ErrNotExist, got %v", err)
+		}
+		// a new file replaces the whiteout
+		if _, err := writefs.WriteFile(unionFS, "data/b.txt", []byte("upper b")); err != nil {
+			t.Fatal(err)
+		}
+		checkFile(t, "data/b.txt", "upper b")
+	})
+
+	t.Run("delete folder", func(t *testing.T) {
+		if err := writefs.RemoveAll(unionFS, "archive"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := fs.Stat(unionFS, "archive/file.txt"); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("removed folder: expected fs.ErrNotExist, got %v", err)
+		}
+		// the recreated folder must not show the old content
+		if err := writefs.MkDir(unionFS, "archive"); err != nil {
+			t.Fatal(err)
+		}
+		checkDir(t, "archive")
+		if _, err := writefs.WriteFile(unionFS, "archive/other.txt", []byte("other")); err != nil {
+			t.Fatal(err)
+		}
+		checkDir(t, "archive", "other.txt")
+	})
+
+	t.Run("rename", func(t *testing.T) {
+		if err := writefs.Rename(unionFS, "data/sub/c.txt", "data/c.txt"); err != nil {
+			t.Fatal(err)
+		}
+		checkFile(t, "data/c.txt", "lower c")
+		checkDir(t, "data/sub")
+	})
+
+	t.Run("open file", func(t *testing.T) {
+		if _, err := writefs.OpenFile(unionFS, "data/c.txt", os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644); !errors.Is(err, fs.ErrExist) {
+			t.Errorf("exclusive create of existing file: expected fs.ErrExist, got %v", err)
+		}
+		fp, err := writefs.CreateAtomic(unionFS, "data/atomic.txt")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := fp.Write([]byte("atomic")); err != nil {
+			t.Fatal(err)
+		}
+		if err := fp.Close(); err != nil {
+			t.Fatal(err)
+		}
+		checkFile(t, "data/atomic.txt", "atomic")
+		checkDir(t, "data", "atomic.txt", "b.txt", "c.txt", "new.txt", "sub")
+	})
+
+	t.Run("markers", func(t *testing.T) {
+		if _, err := writefs.Create(unionFS, "data/.wh.new.txt"); !errors.Is(err, fs.ErrInvalid) {
+			t.Errorf("create whiteout marker: expected fs.ErrInvalid, got %v", err)
+		}
+		if _, err := fs.Stat(unionFS, "data/.wh.a.txt"); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("stat of whiteout marker: expected fs.ErrNotExist, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,124 @@
+package unionfs
+
+import (
+	"context"
+	"emperror.dev/errors"
+	"github.com/je4/filesystem/v3/pkg/writefs"
+	"io/fs"
+	"path"
+	"strings"
+)
+
+const (
+	// whiteoutPrefix marks a deleted file or folder of the lower layer: <folder>/.wh.<name>
+	whiteoutPrefix = ".wh."
+	// opaqueMarker in a folder of the upper layer hides the content of the folder in the lower layer
+	opaqueMarker = ".wh..wh..opq"
+)
+
+func whiteout(name string) string {
+	return path.Join(path.Dir(name), whiteoutPrefix+path.Base(name))
+}
+
+func opaque(dir string) string {
+	return path.Join(dir, opaqueMarker)
+}
+
+func (u *unionFS) exists(ctx context.Context, fsys fs.FS, name string) (bool, error) {
+	if _, err := writefs.StatContext(ctx, fsys, name); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return false, nil
+		}
+		return false, errors.Wrapf(err, "cannot stat '%s'", name)
+	}
+	return true, nil
+}
+
+// lookup checks in which layers name is visible.
+// If name is visible in none of the layers, fs.ErrNotExist is returned.
+func (u *unionFS) lookup(ctx context.Context, name string) (inUpper, inLower bool, err error) {
+	if !fs.ValidPath(name) {
+		return false, false, errors.Wrapf(fs.ErrInvalid, "invalid path '%s'", name)
+	}
+	if name == "." {
+		return true, true, nil
+	}
+	lowerVisible := true
+	elems := strings.Split(name, "/")
+	for i := range elems {
+		current := path.Join(elems[:i+1]...)
+		if strings.HasPrefix(elems[i], whiteoutPrefix) {
+			return false, false, errors.Wrapf(fs.ErrNotExist, "'%s' is a whiteout marker", name)
+		}
+		deleted, err := u.exists(ctx, u.upper, whiteout(current))
+		if err != nil {
+			return false, false, errors.WithStack(err)
+		}
+		if deleted {
+			return false, false, errors.Wrapf(fs.ErrNotExist, "'%s' has been deleted", current)
+		}
+		if i == len(elems)-1 || !lowerVisible {
+			continue
+		}
+		isOpaque, err := u.exists(ctx, u.upper, opaque(current))
+		if err != nil {
+			return false, false, errors.WithStack(err)
+		}
+		lowerVisible = !isOpaque
+	}
+	if inUpper, err = u.exists(ctx, u.upper, name); err != nil {
+		return false, false, errors.WithStack(err)
+	}
+	if lowerVisible {
+		if inLower, err = u.exists(ctx, u.lower, name); err != nil {
+			return false, false, errors.WithStack(err)
+		}
+	}
+	if !inUpper && !inLower {
+		return false, false, errors.Wrapf(fs.ErrNotExist, "'%s' not found", name)
+	}
+	return inUpper, inLower, nil
+}
+
+// unwhiteout makes name and its parents visible again.
+// Deleted folders become opaque, so that their old content in the lower layer stays hidden.
+func (u *unionFS) unwhiteout(ctx context.Context, name string, isDir bool) error {
+	elems := strings.Split(name, "/")
+	for i := range elems {
+		current := path.Join(elems[:i+1]...)
+		deleted, err := u.exists(ctx, u.upper, whiteout(current))
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		if !deleted {
+			continue
+		}
+		if err := writefs.RemoveContext(ctx, u.upper, whiteout(current)); err != nil {
+			return errors.Wrapf(err, "cannot remove whiteout of '%s'", current)
+		}
+		if i == len(elems)-1 && !isDir {
+			continue
+		}
+		if err := writefs.MkDirAllContext(ctx, u.upper, current); err != nil {
+			return errors.Wrapf(err, "cannot create folder '%s'", current)
+		}
+		if err := u.touch(ctx, opaque(current)); err != nil {
+			return errors.Wrapf(err, "cannot make '%s' opaque", current)
+		}
+	}
+	return nil
+}
+
+// delete hides name of the lower layer
+func (u *unionFS) delete(ctx context.Context, name string) error {
+	return errors.Wrapf(u.touch(ctx, whiteout(name)), "cannot create whiteout of '%s'", name)
+}
+
+// touch creates the empty marker file name in the upper layer
+func (u *unionFS) touch(ctx context.Context, name string) error {
+	fp, err := writefs.CreateContext(ctx, u.upper, name)
+	if err != nil {
+		return errors.Wrapf(err, "cannot create '%s'", name)
+	}
+	return errors.Wrapf(fp.Close(), "cannot close '%s'", name)
+}
@@ -23,6 +23,10 @@ type Remote struct {
 	Address   string
 	ClientTLS *trustconfig.TLSConfig
 	BaseDir   string
+	// AbortTimeout limits the wait for the server to cancel an aborted upload (default: 3s)
+	AbortTimeout config.Duration
+	// CloseTimeout limits the wait for the server to commit an upload (default: 3s)
+	CloseTimeout config.Duration
 }
 
 type S3 struct {
@@ -37,13 +41,61 @@ type S3 struct {
 	ZipAsFolderCache uint
 }
 
+// Quota limits the content of a filesystem. A limit of 0 means unlimited.
+type Quota struct {
+	MaxBytes int64
+	MaxFiles int64
+}
+
+// Filter shows only the entries matching Include (all if empty) and not matching Exclude
+type Filter struct {
+	Include  []string
+	Exclude  []string
+	ReadOnly bool
+}
+
+// Audit sends the changes of a filesystem to the log and an optional webhook.
+// Timeout limits every webhook request (default 10s).
+type Audit struct {
+	Log       bool
+	Webhook   string
+	Timeout   config.Duration
+	Retries   int
+	Backoff   config.Duration
+	QueueSize int
+}
+
+// Retry retries idempotent operations after transient errors with exponential backoff.
+// Unset values keep the defaults of retryfs.DefaultBackoff, retries = 0 disables retrying.
+type Retry struct {
+	Retries    *int
+	Backoff    config.Duration
+	MaxBackoff config.Duration
+	Jitter     float64
+}
+
+// Throttle limits the bytes per second of reads and writes and the operations per second. A limit of 0 means unlimited.
+// All mounts with the same Group share their limits, e.g. to limit the uplink of several mounts together.
+// The remotefs server uses Throttle per client instead of per mount, see remotefs.NewMainController.
+type Throttle struct {
+	ReadBytesPerSecond  int64
+	WriteBytesPerSecond int64
+	OpsPerSecond        float64
+	Group               string
+}
+
 type VFS struct {
-	Name   string  `toml:"name"`
-	Type   string  `toml:"type"`
-	S3     *S3     `toml:"s3,omitempty"`
-	OS     *OS     `toml:"os,omitempty"`
-	SFTP   *SFTP   `toml:"sftp,omitempty"`
-	Remote *Remote `toml:"remote,omitempty"`
+	Name     string    `toml:"name"`
+	Type     string    `toml:"type"`
+	S3       *S3       `toml:"s3,omitempty"`
+	OS       *OS       `toml:"os,omitempty"`
+	SFTP     *SFTP     `toml:"sftp,omitempty"`
+	Remote   *Remote   `toml:"remote,omitempty"`
+	Quota    *Quota    `toml:"quota,omitempty"`
+	Filter   *Filter   `toml:"filter,omitempty"`
+	Audit    *Audit    `toml:"audit,omitempty"`
+	Retry    *Retry    `toml:"retry,omitempty"`
+	Throttle *Throttle `toml:"throttle,omitempty"`
 }
 
 type Config map[string]*VFS
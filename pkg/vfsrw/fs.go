@@ -1,6 +1,7 @@
 package vfsrw
 
 import (
+	"context"
 	"emperror.dev/errors"
 	"fmt"
 	"github.com/je4/filesystem/v3/pkg/writefs"
@@ -8,6 +9,7 @@ import (
 	"io"
 	"io/fs"
 	"strings"
+	"time"
 )
 
 func NewFS(config Config, logger zLogger.ZLogger) (*vFSRW, error) {
@@ -22,6 +24,7 @@ func NewFS(config Config, logger zLogger.ZLogger) (*vFSRW, error) {
 	}
 
 	vfs := &vFSRW{fss: map[string]fs.FS{}}
+	limiters := NewLimiters()
 
 	_logger := logger.With().Str("module", "vfsrw").Logger()
 	logger = &_logger
@@ -72,6 +75,13 @@ func NewFS(config Config, logger zLogger.ZLogger) (*vFSRW, error) {
 				toClose = append(toClose, closer)
 			}
 			vfs.fss[cfg.Name] = xFS
+		case "mem":
+			xFS, err := newMem(cfg.Name, logger)
+			if err != nil {
+				closeAll()
+				return nil, errors.Wrapf(err, "cannot create memfsrw in '%s'", cfg.Name)
+			}
+			vfs.fss[cfg.Name] = xFS
 		case "remote":
 			if cfg.Remote == nil {
 				closeAll()
@@ -87,6 +97,62 @@ func NewFS(config Config, logger zLogger.ZLogger) (*vFSRW, error) {
 			}
 			vfs.fss[cfg.Name] = xFS
 		}
+		if xFS, ok := vfs.fss[cfg.Name]; ok {
+			mFS, err := newMetrics(cfg.Name, strings.ToLower(cfg.Type), xFS, logger)
+			if err != nil {
+				closeAll()
+				return nil, errors.Wrapf(err, "cannot create metricsfs in '%s'", cfg.Name)
+			}
+			vfs.fss[cfg.Name] = mFS
+		}
+		if xFS, ok := vfs.fss[cfg.Name]; ok {
+			tFS, err := newTrace(cfg.Name, xFS, logger)
+			if err != nil {
+				closeAll()
+				return nil, errors.Wrapf(err, "cannot create tracefs in '%s'", cfg.Name)
+			}
+			vfs.fss[cfg.Name] = tFS
+		}
+		if xFS, ok := vfs.fss[cfg.Name]; ok && cfg.Throttle != nil {
+			tFS, err := newThrottle(xFS, cfg.Throttle, limiters, logger)
+			if err != nil {
+				closeAll()
+				return nil, errors.Wrapf(err, "cannot create throttlefs in '%s'", cfg.Name)
+			}
+			vfs.fss[cfg.Name] = tFS
+		}
+		if xFS, ok := vfs.fss[cfg.Name]; ok && cfg.Retry != nil {
+			rFS, err := newRetry(xFS, cfg.Retry, logger)
+			if err != nil {
+				closeAll()
+				return nil, errors.Wrapf(err, "cannot create retryfs in '%s'", cfg.Name)
+			}
+			vfs.fss[cfg.Name] = rFS
+		}
+		if xFS, ok := vfs.fss[cfg.Name]; ok && cfg.Quota != nil {
+			qFS, err := newQuota(xFS, cfg.Quota, logger)
+			if err != nil {
+				closeAll()
+				return nil, errors.Wrapf(err, "cannot create quotafs in '%s'", cfg.Name)
+			}
+			vfs.fss[cfg.Name] = qFS
+		}
+		if xFS, ok := vfs.fss[cfg.Name]; ok && cfg.Filter != nil {
+			fFS, err := newFilter(xFS, cfg.Filter, logger)
+			if err != nil {
+				closeAll()
+				return nil, errors.Wrapf(err, "cannot create filterfs in '%s'", cfg.Name)
+			}
+			vfs.fss[cfg.Name] = fFS
+		}
+		if xFS, ok := vfs.fss[cfg.Name]; ok && cfg.Audit != nil {
+			eFS, err := newAudit(cfg.Name, xFS, cfg.Audit, logger)
+			if err != nil {
+				closeAll()
+				return nil, errors.Wrapf(err, "cannot create eventfs in '%s'", cfg.Name)
+			}
+			vfs.fss[cfg.Name] = eFS
+		}
 	}
 	return vfs, nil
 }
@@ -143,6 +209,28 @@ func (vfs *vFSRW) Rename(oldPath, newPath string) error {
 
 }
 
+// CopyFile copies src to dst without streaming if both are located in the same filesystem
+func (vfs *vFSRW) CopyFile(src, dst string) error {
+	name1, _, _ := matchPath(src)
+	name2, _, _ := matchPath(dst)
+	if name2 != name1 {
+		return errors.Wrapf(writefs.ErrNotImplemented, "cannot copy over multiple filesystems %s -> %s", name1, name2)
+	}
+	vFS, sp, err := vfs.getFS(src)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	_, dp, err := vfs.getFS(dst)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	cfs, ok := vFS.(writefs.CopyFileFS)
+	if !ok {
+		return errors.Wrap(writefs.ErrNotImplemented, "CopyFile")
+	}
+	return errors.WithStack(cfs.CopyFile(sp, dp))
+}
+
 func (vfs *vFSRW) MkDir(name string) error {
 	vFS, path, err := vfs.getFS(name)
 	if err != nil {
@@ -155,6 +243,46 @@ func (vfs *vFSRW) MkDir(name string) error {
 	return nil
 }
 
+func (vfs *vFSRW) MkDirAll(name string) error {
+	vFS, path, err := vfs.getFS(name)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	err = writefs.MkDirAll(vFS, path)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+func (vfs *vFSRW) RemoveAll(name string) error {
+	vFS, path, err := vfs.getFS(name)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	err = writefs.RemoveAll(vFS, path)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+func (vfs *vFSRW) Chtimes(name string, atime, mtime time.Time) error {
+	vFS, path, err := vfs.getFS(name)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(writefs.Chtimes(vFS, path, atime, mtime))
+}
+
+func (vfs *vFSRW) Chmod(name string, mode fs.FileMode) error {
+	vFS, path, err := vfs.getFS(name)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(writefs.Chmod(vFS, path, mode))
+}
+
 func (vfs *vFSRW) Create(name string) (writefs.FileWrite, error) {
 	vFS, path, err := vfs.getFS(name)
 	if err != nil {
@@ -167,6 +295,75 @@ func (vfs *vFSRW) Create(name string) (writefs.FileWrite, error) {
 	return data, nil
 }
 
+func (vfs *vFSRW) CreateAtomic(name string) (writefs.FileWrite, error) {
+	vFS, path, err := vfs.getFS(name)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	data, err := writefs.CreateAtomic(vFS, path)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return data, nil
+}
+
+// Symlink creates newname as a link to oldname within the same filesystem. oldname is not modified.
+func (vfs *vFSRW) Symlink(oldname, newname string) error {
+	vFS, path, err := vfs.getFS(newname)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(writefs.Symlink(vFS, oldname, path))
+}
+
+func (vfs *vFSRW) ReadLink(name string) (string, error) {
+	vFS, path, err := vfs.getFS(name)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	dest, err := writefs.ReadLink(vFS, path)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	return dest, nil
+}
+
+func (vfs *vFSRW) Lstat(name string) (fs.FileInfo, error) {
+	vFS, path, err := vfs.getFS(name)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	info, err := writefs.Lstat(vFS, path)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return info, nil
+}
+
+func (vfs *vFSRW) CreateWriterAt(name string) (writefs.FileWriterAt, error) {
+	vFS, path, err := vfs.getFS(name)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	data, err := writefs.CreateWriterAt(vFS, path)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return data, nil
+}
+
+func (vfs *vFSRW) OpenFile(name string, flag int, perm fs.FileMode) (writefs.FileWrite, error) {
+	vFS, path, err := vfs.getFS(name)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	data, err := writefs.OpenFile(vFS, path, flag, perm)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return data, nil
+}
+
 func (vfs *vFSRW) String() string {
 	names := []string{}
 	for name, _ := range vfs.fss {
@@ -227,6 +424,136 @@ func (vfs *vFSRW) Open(vfsPath string) (fs.File, error) {
 	return fp, nil
 }
 
+func (vfs *vFSRW) RemoveContext(ctx context.Context, name string) error {
+	vFS, path, err := vfs.getFS(name)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	err = writefs.RemoveContext(ctx, vFS, path)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+func (vfs *vFSRW) RenameContext(ctx context.Context, oldPath, newPath string) error {
+	name1, _, _ := matchPath(oldPath)
+	name2, _, _ := matchPath(newPath)
+	if name2 != name1 {
+		return errors.Errorf("cannot rename over multiple filesystems %s -> %s", name1, name2)
+	}
+
+	vFS, op, err := vfs.getFS(oldPath)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	_, np, err := vfs.getFS(newPath)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	err = writefs.RenameContext(ctx, vFS, op, np)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+func (vfs *vFSRW) CreateContext(ctx context.Context, name string) (writefs.FileWrite, error) {
+	vFS, path, err := vfs.getFS(name)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	data, err := writefs.CreateContext(ctx, vFS, path)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return data, nil
+}
+
+func (vfs *vFSRW) CreateAtomicContext(ctx context.Context, name string) (writefs.FileWrite, error) {
+	vFS, path, err := vfs.getFS(name)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	data, err := writefs.CreateAtomicContext(ctx, vFS, path)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return data, nil
+}
+
+func (vfs *vFSRW) OpenFileContext(ctx context.Context, name string, flag int, perm fs.FileMode) (writefs.FileWrite, error) {
+	vFS, path, err := vfs.getFS(name)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	data, err := writefs.OpenFileContext(ctx, vFS, path, flag, perm)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return data, nil
+}
+
+func (vfs *vFSRW) StatContext(ctx context.Context, name string) (fs.FileInfo, error) {
+	vFS, path, err := vfs.getFS(name)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	data, err := writefs.StatContext(ctx, vFS, path)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return data, nil
+}
+
+func (vfs *vFSRW) ReadDirContext(ctx context.Context, name string) ([]fs.DirEntry, error) {
+	vFS, path, err := vfs.getFS(name)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	de, err := writefs.ReadDirContext(ctx, vFS, path)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return de, nil
+}
+
+func (vfs *vFSRW) OpenContext(ctx context.Context, vfsPath string) (fs.File, error) {
+	vFS, path, err := vfs.getFS(vfsPath)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	fp, err := writefs.OpenContext(ctx, vFS, path)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return fp, nil
+}
+
+func (vfs *vFSRW) MkDirContext(ctx context.Context, name string) error {
+	vFS, path, err := vfs.getFS(name)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(writefs.MkDirContext(ctx, vFS, path))
+}
+
+func (vfs *vFSRW) MkDirAllContext(ctx context.Context, name string) error {
+	vFS, path, err := vfs.getFS(name)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(writefs.MkDirAllContext(ctx, vFS, path))
+}
+
+func (vfs *vFSRW) RemoveAllContext(ctx context.Context, name string) error {
+	vFS, path, err := vfs.getFS(name)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(writefs.RemoveAllContext(ctx, vFS, path))
+}
+
 func (vfs *vFSRW) getFS(vfsPath string) (fs.FS, string, error) {
 	name, path, err := matchPath(vfsPath)
 	if err != nil {
@@ -246,10 +573,33 @@ var (
 	_ fs.StatFS     = (*vFSRW)(nil)
 	_ fs.SubFS      = (*vFSRW)(nil)
 	//	_ writefs.IsLockedFS = (*vFSRW)(nil)
-	_ fmt.Stringer        = (*vFSRW)(nil)
-	_ writefs.ReadWriteFS = (*vFSRW)(nil)
-	_ writefs.MkDirFS     = (*vFSRW)(nil)
-	_ writefs.RenameFS    = (*vFSRW)(nil)
-	_ writefs.RemoveFS    = (*vFSRW)(nil)
-	_ writefs.CreateFS    = (*vFSRW)(nil)
+	_ fmt.Stringer             = (*vFSRW)(nil)
+	_ writefs.ReadWriteFS      = (*vFSRW)(nil)
+	_ writefs.MkDirFS          = (*vFSRW)(nil)
+	_ writefs.RenameFS         = (*vFSRW)(nil)
+	_ writefs.RemoveFS         = (*vFSRW)(nil)
+	_ writefs.RemoveAllFS      = (*vFSRW)(nil)
+	_ writefs.MkDirAllFS       = (*vFSRW)(nil)
+	_ writefs.CopyFileFS       = (*vFSRW)(nil)
+	_ writefs.ChtimesFS        = (*vFSRW)(nil)
+	_ writefs.ChmodFS          = (*vFSRW)(nil)
+	_ writefs.CreateFS         = (*vFSRW)(nil)
+	_ writefs.CreateAtomicFS   = (*vFSRW)(nil)
+	_ writefs.OpenFileFS       = (*vFSRW)(nil)
+	_ writefs.CreateWriterAtFS = (*vFSRW)(nil)
+	_ writefs.SymlinkFS        = (*vFSRW)(nil)
+	_ writefs.ReadLinkFS       = (*vFSRW)(nil)
+	_ writefs.LstatFS          = (*vFSRW)(nil)
+
+	_ writefs.CreateContextFS       = (*vFSRW)(nil)
+	_ writefs.CreateAtomicContextFS = (*vFSRW)(nil)
+	_ writefs.OpenFileContextFS     = (*vFSRW)(nil)
+	_ writefs.OpenContextFS         = (*vFSRW)(nil)
+	_ writefs.StatContextFS         = (*vFSRW)(nil)
+	_ writefs.RemoveContextFS       = (*vFSRW)(nil)
+	_ writefs.RenameContextFS       = (*vFSRW)(nil)
+	_ writefs.ReadDirContextFS      = (*vFSRW)(nil)
+	_ writefs.MkDirContextFS        = (*vFSRW)(nil)
+	_ writefs.MkDirAllContextFS     = (*vFSRW)(nil)
+	_ writefs.RemoveAllContextFS    = (*vFSRW)(nil)
 )
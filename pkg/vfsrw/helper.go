@@ -4,10 +4,19 @@ import (
 	"crypto/tls"
 	"crypto/x509"
 	"emperror.dev/errors"
+	"github.com/je4/filesystem/v3/pkg/eventfs"
+	"github.com/je4/filesystem/v3/pkg/filterfs"
+	"github.com/je4/filesystem/v3/pkg/memfsrw"
+	"github.com/je4/filesystem/v3/pkg/metricsfs"
 	"github.com/je4/filesystem/v3/pkg/osfsrw"
+	"github.com/je4/filesystem/v3/pkg/quotafs"
 	"github.com/je4/filesystem/v3/pkg/remotefs"
+	"github.com/je4/filesystem/v3/pkg/retryfs"
 	"github.com/je4/filesystem/v3/pkg/s3fsrw"
 	"github.com/je4/filesystem/v3/pkg/sftpfsrw"
+	"github.com/je4/filesystem/v3/pkg/throttlefs"
+	"github.com/je4/filesystem/v3/pkg/tracefs"
+	"github.com/je4/filesystem/v3/pkg/writefs"
 	"github.com/je4/filesystem/v3/pkg/zipasfolder"
 	"github.com/je4/trustutil/v2/pkg/loader"
 	"github.com/je4/utils/v2/pkg/zLogger"
@@ -15,8 +24,10 @@ import (
 	"golang.org/x/crypto/ssh/knownhosts"
 	"io"
 	"io/fs"
+	"net/http"
 	"os"
 	"regexp"
+	"time"
 )
 
 func newRemote(name string, conf *Remote, logger zLogger.ZLogger) (fs.FS, error) {
@@ -24,13 +35,123 @@ func newRemote(name string, conf *Remote, logger zLogger.ZLogger) (fs.FS, error)
 	if err != nil {
 		logger.Panic().Msgf("cannot create client loader: %v", err)
 	}
-	rFS, err := remotefs.NewFS(clientCert, conf.Address, conf.BaseDir, name, []io.Closer{clientLoader}, logger)
+	rFS, err := remotefs.NewFS(clientCert, conf.Address, conf.BaseDir, name, time.Duration(conf.AbortTimeout), time.Duration(conf.CloseTimeout), []io.Closer{clientLoader}, logger)
 	if err != nil {
 		return nil, errors.Wrapf(err, "cannot create new osfsrw")
 	}
 	return rFS, nil
 }
 
+func newMem(name string, logger zLogger.ZLogger) (fs.FS, error) {
+	mFS, err := memfsrw.NewFS(name, logger)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot create new memfsrw")
+	}
+	return mFS, nil
+}
+
+func newQuota(xFS fs.FS, cfg *Quota, logger zLogger.ZLogger) (fs.FS, error) {
+	rwFS, ok := xFS.(writefs.ReadWriteFS)
+	if !ok {
+		return nil, errors.Errorf("'%v' is not writable", xFS)
+	}
+	qFS, err := quotafs.NewFS(rwFS, cfg.MaxBytes, cfg.MaxFiles, logger)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot create new quotafs")
+	}
+	return qFS, nil
+}
+
+func newMetrics(name, backend string, xFS fs.FS, logger zLogger.ZLogger) (fs.FS, error) {
+	metrics, err := metricsfs.DefaultMetrics()
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot get metrics")
+	}
+	mFS, err := metricsfs.NewFS(xFS, name, backend, metrics, logger)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot create new metricsfs")
+	}
+	return mFS, nil
+}
+
+// newTrace uses the global tracer provider, which creates no spans unless it has been configured
+func newTrace(name string, xFS fs.FS, logger zLogger.ZLogger) (fs.FS, error) {
+	tFS, err := tracefs.NewFS(xFS, name, nil, logger)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot create new tracefs")
+	}
+	return tFS, nil
+}
+
+func newThrottle(xFS fs.FS, cfg *Throttle, limiters *Limiters, logger zLogger.ZLogger) (fs.FS, error) {
+	limiter, err := limiters.Get(cfg)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot get limiter")
+	}
+	tFS, err := throttlefs.NewFS(xFS, limiter, logger)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot create new throttlefs")
+	}
+	return tFS, nil
+}
+
+func newRetry(xFS fs.FS, cfg *Retry, logger zLogger.ZLogger) (fs.FS, error) {
+	backoff := retryfs.DefaultBackoff
+	if cfg.Retries != nil {
+		backoff.Retries = *cfg.Retries
+	}
+	if cfg.Backoff > 0 {
+		backoff.Initial = time.Duration(cfg.Backoff)
+	}
+	if cfg.MaxBackoff > 0 {
+		backoff.Max = time.Duration(cfg.MaxBackoff)
+	}
+	if cfg.Jitter > 0 {
+		backoff.Jitter = cfg.Jitter
+	}
+	rFS, err := retryfs.NewFS(xFS, backoff, logger)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot create new retryfs")
+	}
+	return rFS, nil
+}
+
+func newAudit(name string, xFS fs.FS, cfg *Audit, logger zLogger.ZLogger) (fs.FS, error) {
+	subscribers := []eventfs.Subscriber{}
+	if cfg.Log {
+		subscribers = append(subscribers, eventfs.NewLogSubscriber(logger))
+	}
+	if cfg.Webhook != "" {
+		queueSize := cfg.QueueSize
+		if queueSize <= 0 {
+			queueSize = 100
+		}
+		backoff := time.Duration(cfg.Backoff)
+		if backoff <= 0 {
+			backoff = time.Second
+		}
+		timeout := time.Duration(cfg.Timeout)
+		if timeout <= 0 {
+			timeout = eventfs.DefaultWebhookTimeout
+		}
+		client := &http.Client{Timeout: timeout}
+		subscribers = append(subscribers, eventfs.NewWebhookSubscriber(cfg.Webhook, client, queueSize, cfg.Retries, backoff, logger))
+	}
+	eFS, err := eventfs.NewFS(xFS, name, subscribers, logger)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot create new eventfs")
+	}
+	return eFS, nil
+}
+
+func newFilter(xFS fs.FS, cfg *Filter, logger zLogger.ZLogger) (fs.FS, error) {
+	fFS, err := filterfs.NewFS(xFS, cfg.Include, cfg.Exclude, cfg.ReadOnly, logger)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot create new filterfs")
+	}
+	return fFS, nil
+}
+
 func newOS(name string, cfg *OS, logger zLogger.ZLogger) (fs.FS, error) {
 	rFS, err := osfsrw.NewFS(cfg.BaseDir, logger)
 	if err != nil {
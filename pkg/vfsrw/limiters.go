@@ -0,0 +1,38 @@
+package vfsrw
+
+import (
+	"emperror.dev/errors"
+	"github.com/je4/filesystem/v3/pkg/throttlefs"
+)
+
+// Limiters creates the limiters of throttle configurations of mounts or remotefs clients.
+// Configurations with the same group share one limiter.
+type Limiters struct {
+	groups map[string]*limiterGroup
+}
+
+type limiterGroup struct {
+	cfg     Throttle
+	limiter *throttlefs.Limiter
+}
+
+func NewLimiters() *Limiters {
+	return &Limiters{groups: map[string]*limiterGroup{}}
+}
+
+// Get returns a new limiter for cfg, or the limiter of its group. All configurations of a group must have the same limits
+func (l *Limiters) Get(cfg *Throttle) (*throttlefs.Limiter, error) {
+	limiter := throttlefs.NewLimiter(cfg.ReadBytesPerSecond, cfg.WriteBytesPerSecond, cfg.OpsPerSecond)
+	if cfg.Group == "" {
+		return limiter, nil
+	}
+	group, ok := l.groups[cfg.Group]
+	if !ok {
+		l.groups[cfg.Group] = &limiterGroup{cfg: *cfg, limiter: limiter}
+		return limiter, nil
+	}
+	if group.cfg != *cfg {
+		return nil, errors.Errorf("throttle group '%s' has different limits %+v and %+v", cfg.Group, group.cfg, *cfg)
+	}
+	return group.limiter, nil
+}
@@ -0,0 +1,89 @@
+package writefs
+
+import (
+	"crypto/rand"
+	"emperror.dev/errors"
+	"encoding/hex"
+	"io/fs"
+	"path/filepath"
+)
+
+// AtomicTempName returns a hidden temporary name in the folder of path
+func AtomicTempName(path string) string {
+	randBytes := make([]byte, 8)
+	rand.Read(randBytes)
+	dir, base := filepath.Split(filepath.ToSlash(path))
+	return dir + "." + base + "." + hex.EncodeToString(randBytes) + ".tmp"
+}
+
+// CreateAtomic creates a file which is visible under path only after a successful Close.
+// If fsys does not implement CreateAtomicFS, the content is written to a hidden temporary file
+// which is renamed to path on Close.
+func CreateAtomic(fsys fs.FS, path string) (FileWrite, error) {
+	if _fsys, ok := fsys.(CreateAtomicFS); ok {
+		return _fsys.CreateAtomic(path)
+	}
+	if _, ok := fsys.(RenameFS); !ok {
+		return nil, errors.Wrap(ErrNotImplemented, "CreateAtomic")
+	}
+	tmpPath := AtomicTempName(path)
+	fp, err := Create(fsys, tmpPath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot create '%s'", tmpPath)
+	}
+	return &atomicFileWrite{
+		FileWrite: fp,
+		fsys:      fsys,
+		tmpPath:   tmpPath,
+		path:      path,
+	}, nil
+}
+
+type atomicFileWrite struct {
+	FileWrite
+	fsys    fs.FS
+	tmpPath string
+	path    string
+}
+
+func (w *atomicFileWrite) Close() error {
+	if err := w.FileWrite.Close(); err != nil {
+		if err := Remove(w.fsys, w.tmpPath); err != nil {
+			return errors.Wrapf(err, "cannot remove '%s'", w.tmpPath)
+		}
+		return errors.Wrapf(err, "cannot close '%s'", w.tmpPath)
+	}
+	if err := Rename(w.fsys, w.tmpPath, w.path); err != nil {
+		// some filesystems cannot rename to an existing file
+		if _, statErr := fs.Stat(w.fsys, w.path); statErr != nil {
+			Remove(w.fsys, w.tmpPath)
+			return errors.Wrapf(err, "cannot rename '%s' to '%s'", w.tmpPath, w.path)
+		}
+		if err := Remove(w.fsys, w.path); err != nil {
+			Remove(w.fsys, w.tmpPath)
+			return errors.Wrapf(err, "cannot remove '%s'", w.path)
+		}
+		if err := Rename(w.fsys, w.tmpPath, w.path); err != nil {
+			return errors.Wrapf(err, "cannot rename '%s' to '%s'", w.tmpPath, w.path)
+		}
+	}
+	return nil
+}
+
+// Abort discards the temporary file
+func (w *atomicFileWrite) Abort() error {
+	if err := Abort(w.FileWrite); err != nil {
+		if !errors.Is(err, ErrNotImplemented) {
+			return errors.Wrapf(err, "cannot abort '%s'", w.tmpPath)
+		}
+		w.FileWrite.Close()
+		if err := Remove(w.fsys, w.tmpPath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return errors.Wrapf(err, "cannot remove '%s'", w.tmpPath)
+		}
+	}
+	return nil
+}
+
+var (
+	_ AbortFileWrite = &atomicFileWrite{}
+)
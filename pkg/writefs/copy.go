@@ -0,0 +1,144 @@
+package writefs
+
+import (
+	"emperror.dev/errors"
+	"io"
+	"io/fs"
+	"reflect"
+)
+
+// CopyOptions controls the behaviour of CopyFS and Move
+type CopyOptions struct {
+	// NoOverwrite lets the copy fail with fs.ErrExist if dst already exists
+	NoOverwrite bool
+	// SkipModTime keeps the modification time of the copy. Otherwise, the modification time of dst
+	// is set to the one of src if dstFS supports it
+	SkipModTime bool
+	// PreserveMode sets the mode of dst to the one of src if dstFS supports it
+	PreserveMode bool
+	// NoServerSide disables the fast path via CopyFileFS
+	NoServerSide bool
+}
+
+// sameFS checks whether a and b are the same filesystem instance
+func sameFS(a, b fs.FS) bool {
+	ta := reflect.TypeOf(a)
+	if ta == nil || ta != reflect.TypeOf(b) || !ta.Comparable() {
+		return false
+	}
+	return a == b
+}
+
+// CopyFS copies the file src of srcFS to dst of dstFS.
+// If both filesystems are the same instance and implement CopyFileFS, the copy is done without
+// streaming the content. Otherwise, the content is streamed from src to dst.
+func CopyFS(srcFS fs.FS, src string, dstFS fs.FS, dst string, opts *CopyOptions) (int64, error) {
+	if opts == nil {
+		opts = &CopyOptions{}
+	}
+	srcInfo, err := fs.Stat(srcFS, src)
+	if err != nil {
+		return 0, errors.Wrapf(err, "cannot stat source '%s'", src)
+	}
+	if srcInfo.IsDir() {
+		return 0, errors.Wrapf(fs.ErrInvalid, "source '%s' is a directory", src)
+	}
+	if opts.NoOverwrite {
+		if _, err := fs.Stat(dstFS, dst); err == nil {
+			return 0, errors.Wrapf(fs.ErrExist, "destination '%s' already exists", dst)
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return 0, errors.Wrapf(err, "cannot stat destination '%s'", dst)
+		}
+	}
+
+	if !opts.NoServerSide && sameFS(srcFS, dstFS) {
+		if cfs, ok := dstFS.(CopyFileFS); ok {
+			err := cfs.CopyFile(src, dst)
+			if err == nil {
+				preserveAttributes(dstFS, dst, srcInfo, opts)
+				return srcInfo.Size(), nil
+			}
+			if !errors.Is(err, ErrNotImplemented) {
+				return 0, errors.Wrapf(err, "cannot copy '%s' to '%s'", src, dst)
+			}
+		}
+	}
+
+	srcFP, err := srcFS.Open(src)
+	if err != nil {
+		return 0, errors.Wrapf(err, "cannot open source '%s'", src)
+	}
+	defer srcFP.Close()
+	dstFP, err := Create(dstFS, dst)
+	if err != nil {
+		return 0, errors.Wrapf(err, "cannot create destination '%s'", dst)
+	}
+	num, err := io.Copy(dstFP, srcFP)
+	if err != nil {
+		var errs = []error{errors.Wrapf(err, "cannot copy '%s' to '%s'", src, dst)}
+		// a close would commit the partial content on some filesystems, so abort if possible
+		if err := Abort(dstFP); err != nil {
+			if !errors.Is(err, ErrNotImplemented) {
+				errs = append(errs, errors.Wrapf(err, "cannot abort destination '%s'", dst))
+			} else {
+				if err := dstFP.Close(); err != nil {
+					errs = append(errs, errors.Wrapf(err, "cannot close destination '%s'", dst))
+				}
+				if err := Remove(dstFS, dst); err != nil && !errors.Is(err, fs.ErrNotExist) {
+					errs = append(errs, errors.Wrapf(err, "cannot remove destination '%s'", dst))
+				}
+			}
+		}
+		return 0, errors.Combine(errs...)
+	}
+	if err := dstFP.Close(); err != nil {
+		return 0, errors.Wrapf(err, "cannot close destination '%s'", dst)
+	}
+	preserveAttributes(dstFS, dst, srcInfo, opts)
+	return num, nil
+}
+
+// preserveAttributes sets the modification time and, if requested, the mode of dst if possible.
+// Errors are ignored, because not all filesystems support them
+func preserveAttributes(fsys fs.FS, dst string, srcInfo fs.FileInfo, opts *CopyOptions) {
+	if !opts.SkipModTime && !srcInfo.ModTime().IsZero() {
+		_ = Chtimes(fsys, dst, srcInfo.ModTime(), srcInfo.ModTime())
+	}
+	if opts.PreserveMode && srcInfo.Mode().Perm() != 0 {
+		_ = Chmod(fsys, dst, srcInfo.Mode().Perm())
+	}
+}
+
+// Move moves the file src of srcFS to dst of dstFS.
+// Within the same filesystem instance, Rename is used if available.
+// Otherwise, the file is copied with CopyFS and src is removed afterward.
+func Move(srcFS fs.FS, src string, dstFS fs.FS, dst string, opts *CopyOptions) error {
+	if opts == nil {
+		opts = &CopyOptions{}
+	}
+	if sameFS(srcFS, dstFS) {
+		if _, ok := srcFS.(RenameFS); ok {
+			if opts.NoOverwrite {
+				if _, err := fs.Stat(dstFS, dst); err == nil {
+					return errors.Wrapf(fs.ErrExist, "destination '%s' already exists", dst)
+				} else if !errors.Is(err, fs.ErrNotExist) {
+					return errors.Wrapf(err, "cannot stat destination '%s'", dst)
+				}
+			}
+			err := Rename(srcFS, src, dst)
+			if err == nil {
+				return nil
+			}
+			if !errors.Is(err, ErrNotImplemented) {
+				return errors.Wrapf(err, "cannot rename '%s' to '%s'", src, dst)
+			}
+		}
+	}
+	if _, err := CopyFS(srcFS, src, dstFS, dst, opts); err != nil {
+		return errors.Wrapf(err, "cannot copy '%s' to '%s'", src, dst)
+	}
+	if err := Remove(srcFS, src); err != nil {
+		return errors.Wrapf(err, "cannot remove source '%s'", src)
+	}
+	return nil
+}
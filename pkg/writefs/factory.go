@@ -4,7 +4,11 @@ import (
 	"emperror.dev/errors"
 	"golang.org/x/exp/slices"
 	"io/fs"
+	"path"
+	"path/filepath"
 	"regexp"
+	"strings"
+	"sync"
 )
 
 type levelFS uint8
@@ -21,18 +25,67 @@ type createFS struct {
 	level  levelFS
 	re     *regexp.Regexp
 	create CreateFSFunc
+	cached bool
 }
 
+// factoryInstance is a filesystem created by the Factory
+type factoryInstance struct {
+	key    string
+	fsys   fs.FS
+	cached bool
+	refs   int
+	// parents are the paths the CreateFSFunc has requested from the Factory. They are released with fsys
+	parents []string
+}
+
+// Factory creates filesystems for paths with the registered CreateFSFunc.
+// Every filesystem returned by Get must be returned with Release or Close instead of closing it directly.
+// Filesystems of cached registrations are shared between callers per normalized path.
 type Factory struct {
-	fss []*createFS
+	fss  []*createFS
+	lock sync.Mutex
+	// instances in order of creation, used to close dependent filesystems first
+	instances []*factoryInstance
+	// root is set for the Factory passed to a CreateFSFunc. Get on it records the parents of the created filesystem
+	root    *Factory
+	parents []string
 }
 
 func NewFactory() (*Factory, error) {
-	f := &Factory{fss: []*createFS{}}
+	f := &Factory{
+		fss:       []*createFS{},
+		instances: []*factoryInstance{},
+	}
 	return f, nil
 }
 
+// normalizePath cleans the part of p after an optional scheme
+func normalizePath(p string) string {
+	p = filepath.ToSlash(p)
+	var prefix string
+	if pos := strings.Index(p, "://"); pos >= 0 {
+		prefix, p = p[:pos+3], p[pos+3:]
+	}
+	if p == "" {
+		return prefix
+	}
+	return prefix + path.Clean(p)
+}
+
+// Register adds create for paths matching identifyRegex. Every Get creates a new filesystem,
+// which is kept open until its Release or Factory.Close.
 func (f *Factory) Register(create CreateFSFunc, identifyRegex string, level levelFS) error {
+	return f.register(create, identifyRegex, level, false)
+}
+
+// RegisterCached adds create for paths matching identifyRegex. The created filesystems are shared
+// by all callers of Get for the same path and closed with the last Release.
+// Filesystems which cannot be shared, like zip writers, must not be registered with RegisterCached.
+func (f *Factory) RegisterCached(create CreateFSFunc, identifyRegex string, level levelFS) error {
+	return f.register(create, identifyRegex, level, true)
+}
+
+func (f *Factory) register(create CreateFSFunc, identifyRegex string, level levelFS, cached bool) error {
 	re, err := regexp.Compile(identifyRegex)
 	if err != nil {
 		return errors.Wrapf(err, "cannot compile regexp '%s'", identifyRegex)
@@ -42,6 +95,7 @@ func (f *Factory) Register(create CreateFSFunc, identifyRegex string, level leve
 		level:  level,
 		re:     re,
 		create: create,
+		cached: cached,
 	}
 	pos, _ := slices.BinarySearchFunc(f.fss, cs, func(a, b *createFS) int {
 		if a.level > b.level {
@@ -58,15 +112,125 @@ func (f *Factory) Register(create CreateFSFunc, identifyRegex string, level leve
 	return nil
 }
 
+// find returns the index of the oldest instance for key. If cached is true, only cached instances are returned.
+// The caller must hold the lock
+func (f *Factory) find(key string, cached bool) int {
+	return slices.IndexFunc(f.instances, func(inst *factoryInstance) bool {
+		return inst.key == key && (inst.cached || !cached)
+	})
+}
+
+// Get returns the filesystem for path. It is created with the first matching CreateFSFunc,
+// unless there is an instance of a cached registration.
+// Every call of Get must be paired with a call of Release for path, otherwise the filesystem stays open
+// until Factory.Close. The filesystem must not be closed directly.
+// Within a CreateFSFunc, the filesystems returned by Get are released together with the created filesystem.
 func (f *Factory) Get(path string) (fs.FS, error) {
+	if f.root != nil {
+		fsys, err := f.root.Get(path)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		f.parents = append(f.parents, path)
+		return fsys, nil
+	}
+	key := normalizePath(path)
+	f.lock.Lock()
+	if pos := f.find(key, true); pos >= 0 {
+		inst := f.instances[pos]
+		inst.refs++
+		f.lock.Unlock()
+		return inst.fsys, nil
+	}
+	f.lock.Unlock()
+
+	// create without lock, because CreateFSFunc may call Get for the parent path
 	for _, cs := range f.fss {
-		if cs.re.MatchString(path) {
-			fsys, err := cs.create(f, path)
-			if err != nil {
-				return nil, errors.Wrapf(err, "cannot create filesystem for '%s'", path)
+		if !cs.re.MatchString(path) {
+			continue
+		}
+		creator := &Factory{root: f}
+		fsys, err := cs.create(creator, path)
+		if err != nil {
+			return nil, errors.Combine(
+				errors.Wrapf(err, "cannot create filesystem for '%s'", path),
+				f.release(creator.parents),
+			)
+		}
+		f.lock.Lock()
+		if pos := f.find(key, true); cs.cached && pos >= 0 {
+			// created concurrently
+			inst := f.instances[pos]
+			inst.refs++
+			f.lock.Unlock()
+			if err := errors.Combine(Close(fsys), f.release(creator.parents)); err != nil {
+				return nil, errors.Wrapf(err, "cannot close duplicate filesystem for '%s'", path)
 			}
-			return fsys, nil
+			return inst.fsys, nil
 		}
+		f.instances = append(f.instances, &factoryInstance{
+			key:     key,
+			fsys:    fsys,
+			cached:  cs.cached,
+			refs:    1,
+			parents: creator.parents,
+		})
+		f.lock.Unlock()
+		return fsys, nil
 	}
 	return nil, errors.Errorf("path %s not supported", path)
 }
+
+// Release decrements the reference counter of the instance for path. The last Release closes the filesystem
+// and releases the parent filesystems it has used.
+// If there are several instances for path of a registration without caching, the oldest one is released.
+func (f *Factory) Release(path string) error {
+	if f.root != nil {
+		return f.root.Release(path)
+	}
+	key := normalizePath(path)
+	f.lock.Lock()
+	pos := f.find(key, false)
+	if pos < 0 {
+		f.lock.Unlock()
+		return errors.Errorf("no filesystem for '%s'", path)
+	}
+	inst := f.instances[pos]
+	inst.refs--
+	if inst.refs > 0 {
+		f.lock.Unlock()
+		return nil
+	}
+	f.instances = slices.Delete(f.instances, pos, pos+1)
+	f.lock.Unlock()
+	return errors.Combine(
+		errors.Wrapf(Close(inst.fsys), "cannot close filesystem for '%s'", path),
+		f.release(inst.parents),
+	)
+}
+
+// release calls Release for all paths
+func (f *Factory) release(paths []string) error {
+	var errs = []error{}
+	for _, p := range paths {
+		if err := f.Release(p); err != nil {
+			errs = append(errs, errors.WithStack(err))
+		}
+	}
+	return errors.Combine(errs...)
+}
+
+// Close closes all filesystems created by the Factory in reverse order of creation
+func (f *Factory) Close() error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	var errs = []error{}
+	for i := len(f.instances) - 1; i >= 0; i-- {
+		inst := f.instances[i]
+		if err := Close(inst.fsys); err != nil {
+			errs = append(errs, errors.Wrapf(err, "cannot close filesystem for '%s'", inst.key))
+		}
+	}
+	f.instances = []*factoryInstance{}
+	return errors.Combine(errs...)
+}
@@ -1,6 +1,12 @@
 package writefs
 
-import "io"
+import (
+	"emperror.dev/errors"
+	"io"
+)
+
+// ErrAborted is the cause of failed uploads after Abort
+var ErrAborted = errors.NewPlain("aborted")
 
 type FileWrite interface {
 	io.WriteCloser
@@ -15,3 +21,19 @@ type FileWriteSeeker interface {
 	FileWrite
 	io.Seeker
 }
+
+// AbortFileWrite discards the written content instead of publishing it.
+// After Abort, the FileWrite must not be used anymore.
+type AbortFileWrite interface {
+	FileWrite
+	Abort() error
+}
+
+// Abort discards the content of fp. If fp does not implement AbortFileWrite, ErrNotImplemented is returned
+// and fp is still open.
+func Abort(fp FileWrite) error {
+	if _fp, ok := fp.(AbortFileWrite); ok {
+		return _fp.Abort()
+	}
+	return errors.Wrap(ErrNotImplemented, "Abort")
+}
@@ -2,13 +2,15 @@ package writefs
 
 import (
 	"io/fs"
+	"path"
+	"path/filepath"
 	"time"
 )
 
-// NewFileInfoDir creates a new FileInfo for a directory.
-func NewFileInfoDir(base string) *fileInfoDir {
+// NewFileInfoDir creates a new FileInfo for a directory. Name returns the last element of name.
+func NewFileInfoDir(name string) *fileInfoDir {
 	return &fileInfoDir{
-		base: base,
+		base: path.Base(filepath.ToSlash(name)),
 	}
 }
 
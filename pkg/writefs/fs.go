@@ -1,21 +1,88 @@
 package writefs
 
+import (
+	"context"
+	"io/fs"
+	"time"
+)
+
 type CreateFS interface {
 	Create(path string) (FileWrite, error)
 }
 
+// CreateAtomicFS creates files which are visible under their final name only after a successful Close
+type CreateAtomicFS interface {
+	CreateAtomic(path string) (FileWrite, error)
+}
+
+// OpenFileFS opens a file with the flags of os.OpenFile (os.O_RDONLY, os.O_WRONLY, os.O_RDWR,
+// os.O_APPEND, os.O_CREATE, os.O_EXCL, os.O_TRUNC). perm is used if the file is created.
+// Unsupported combinations of flags return ErrNotImplemented.
+// If os.O_EXCL is set and the file exists, the error wraps fs.ErrExist.
+type OpenFileFS interface {
+	OpenFile(name string, flag int, perm fs.FileMode) (FileWrite, error)
+}
+
+// CreateWriterAtFS creates files which support random access writes
+type CreateWriterAtFS interface {
+	CreateWriterAt(path string) (FileWriterAt, error)
+}
+
 type MkDirFS interface {
 	MkDir(path string) error
 }
 
+// MkDirAllFS creates a directory along with all necessary parents
+type MkDirAllFS interface {
+	MkDirAll(path string) error
+}
+
 type RenameFS interface {
 	Rename(oldPath, newPath string) error
 }
 
+// RemoveFS removes path. The removal of a missing file returns fs.ErrNotExist
 type RemoveFS interface {
 	Remove(path string) error
 }
 
+// RemoveAllFS removes path and any children it contains
+type RemoveAllFS interface {
+	RemoveAll(path string) error
+}
+
+// CopyFileFS copies a file within the filesystem without streaming the content
+// through the client (e.g. s3 CopyObject or a reflink).
+// If the fast path is not available, ErrNotImplemented is returned.
+type CopyFileFS interface {
+	CopyFile(src, dst string) error
+}
+
+// ChtimesFS changes the access and modification times of a file
+type ChtimesFS interface {
+	Chtimes(name string, atime, mtime time.Time) error
+}
+
+// ChmodFS changes the mode of a file
+type ChmodFS interface {
+	Chmod(name string, mode fs.FileMode) error
+}
+
+// SymlinkFS creates newname as a symbolic link to oldname
+type SymlinkFS interface {
+	Symlink(oldname, newname string) error
+}
+
+// ReadLinkFS returns the destination of a symbolic link
+type ReadLinkFS interface {
+	ReadLink(name string) (string, error)
+}
+
+// LstatFS returns the file info of name without following symbolic links
+type LstatFS interface {
+	Lstat(name string) (fs.FileInfo, error)
+}
+
 type CloseFS interface {
 	Close() error
 }
@@ -27,3 +94,58 @@ type WriteFileFS interface {
 type FullpathFS interface {
 	Fullpath(name string) (string, error)
 }
+
+// CreateContextFS is a CreateFS which can be cancelled via context
+type CreateContextFS interface {
+	CreateContext(ctx context.Context, path string) (FileWrite, error)
+}
+
+// CreateAtomicContextFS is a CreateAtomicFS which can be cancelled via context
+type CreateAtomicContextFS interface {
+	CreateAtomicContext(ctx context.Context, path string) (FileWrite, error)
+}
+
+// OpenFileContextFS is a OpenFileFS which can be cancelled via context
+type OpenFileContextFS interface {
+	OpenFileContext(ctx context.Context, name string, flag int, perm fs.FileMode) (FileWrite, error)
+}
+
+// OpenContextFS is a fs.FS which can be cancelled via context
+type OpenContextFS interface {
+	OpenContext(ctx context.Context, name string) (fs.File, error)
+}
+
+// StatContextFS is a fs.StatFS which can be cancelled via context
+type StatContextFS interface {
+	StatContext(ctx context.Context, name string) (fs.FileInfo, error)
+}
+
+// RemoveContextFS is a RemoveFS which can be cancelled via context
+type RemoveContextFS interface {
+	RemoveContext(ctx context.Context, path string) error
+}
+
+// RenameContextFS is a RenameFS which can be cancelled via context
+type RenameContextFS interface {
+	RenameContext(ctx context.Context, oldPath, newPath string) error
+}
+
+// ReadDirContextFS is a fs.ReadDirFS which can be cancelled via context
+type ReadDirContextFS interface {
+	ReadDirContext(ctx context.Context, name string) ([]fs.DirEntry, error)
+}
+
+// MkDirContextFS is a MkDirFS which can be cancelled via context
+type MkDirContextFS interface {
+	MkDirContext(ctx context.Context, path string) error
+}
+
+// MkDirAllContextFS is a MkDirAllFS which can be cancelled via context
+type MkDirAllContextFS interface {
+	MkDirAllContext(ctx context.Context, path string) error
+}
+
+// RemoveAllContextFS is a RemoveAllFS which can be cancelled via context
+type RemoveAllContextFS interface {
+	RemoveAllContext(ctx context.Context, path string) error
+}
@@ -5,11 +5,16 @@ import (
 	"io"
 	"io/fs"
 	"os"
+	"path/filepath"
 	"strings"
+	"time"
 )
 
 var ErrNotImplemented = errors.NewPlain("not implemented")
 
+// ErrQuotaExceeded is returned by writes which would exceed the limits of a filesystem
+var ErrQuotaExceeded = errors.NewPlain("quota exceeded")
+
 func SubFSCreate(fsys fs.FS, path string) (fs.FS, error) {
 	if err := MkDir(fsys, path); err != nil {
 		if !errors.Is(err, fs.ErrExist) {
@@ -26,6 +31,33 @@ func MkDir(fsys fs.FS, path string) error {
 	return errors.Wrapf(fs.ErrInvalid, "fs does not support MkDir")
 }
 
+// MkDirAll creates a directory along with all necessary parents.
+// If fsys does not implement MkDirAllFS, the directories are created one by one with MkDir.
+func MkDirAll(fsys fs.FS, path string) error {
+	if _fsys, ok := fsys.(MkDirAllFS); ok {
+		return _fsys.MkDirAll(path)
+	}
+	path = filepath.ToSlash(filepath.Clean(path))
+	if path == "." || path == "/" {
+		return nil
+	}
+	if info, err := fs.Stat(fsys, path); err == nil {
+		if info.IsDir() {
+			return nil
+		}
+		return errors.Wrapf(fs.ErrExist, "'%s' is not a directory", path)
+	}
+	if parent := filepath.ToSlash(filepath.Dir(path)); parent != "." && parent != "/" {
+		if err := MkDirAll(fsys, parent); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	if err := MkDir(fsys, path); err != nil && !errors.Is(err, fs.ErrExist) {
+		return errors.Wrapf(err, "cannot create directory '%s'", path)
+	}
+	return nil
+}
+
 func Rename(fsys fs.FS, oldPath, newPath string) error {
 	if _fsys, ok := fsys.(RenameFS); ok {
 		return _fsys.Rename(oldPath, newPath)
@@ -40,6 +72,19 @@ func Create(fsys fs.FS, path string) (FileWrite, error) {
 	return nil, errors.Wrap(ErrNotImplemented, "Create")
 }
 
+// OpenFile opens name with the flags of os.OpenFile.
+// If fsys does not implement OpenFileFS, os.O_WRONLY|os.O_CREATE|os.O_TRUNC falls back to Create,
+// all other flags return ErrNotImplemented.
+func OpenFile(fsys fs.FS, name string, flag int, perm fs.FileMode) (FileWrite, error) {
+	if _fsys, ok := fsys.(OpenFileFS); ok {
+		return _fsys.OpenFile(name, flag, perm)
+	}
+	if flag == os.O_WRONLY|os.O_CREATE|os.O_TRUNC {
+		return Create(fsys, name)
+	}
+	return nil, errors.Wrapf(ErrNotImplemented, "OpenFile with flags %#x", flag)
+}
+
 func Remove(fsys fs.FS, path string) error {
 	if _fsys, ok := fsys.(RemoveFS); ok {
 		return _fsys.Remove(path)
@@ -47,6 +92,78 @@ func Remove(fsys fs.FS, path string) error {
 	return errors.Wrap(ErrNotImplemented, "Remove")
 }
 
+// RemoveAll removes path and any children it contains. It returns nil if path does not exist.
+// If fsys does not implement RemoveAllFS, the tree is walked and removed entry by entry.
+func RemoveAll(fsys fs.FS, path string) error {
+	if _fsys, ok := fsys.(RemoveAllFS); ok {
+		return _fsys.RemoveAll(path)
+	}
+	path = filepath.ToSlash(filepath.Clean(path))
+	info, err := fs.Stat(fsys, path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return errors.Wrapf(err, "cannot stat '%s'", path)
+	}
+	if info.IsDir() {
+		entries, err := fs.ReadDir(fsys, path)
+		if err != nil {
+			return errors.Wrapf(err, "cannot read directory '%s'", path)
+		}
+		for _, entry := range entries {
+			if err := RemoveAll(fsys, filepath.ToSlash(filepath.Join(path, entry.Name()))); err != nil {
+				return errors.WithStack(err)
+			}
+		}
+		// the root cannot be removed
+		if path == "." {
+			return nil
+		}
+	}
+	if err := Remove(fsys, path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return errors.Wrapf(err, "cannot remove '%s'", path)
+	}
+	return nil
+}
+
+func Chtimes(fsys fs.FS, name string, atime, mtime time.Time) error {
+	if _fsys, ok := fsys.(ChtimesFS); ok {
+		return _fsys.Chtimes(name, atime, mtime)
+	}
+	return errors.Wrap(ErrNotImplemented, "Chtimes")
+}
+
+func Chmod(fsys fs.FS, name string, mode fs.FileMode) error {
+	if _fsys, ok := fsys.(ChmodFS); ok {
+		return _fsys.Chmod(name, mode)
+	}
+	return errors.Wrap(ErrNotImplemented, "Chmod")
+}
+
+func Symlink(fsys fs.FS, oldname, newname string) error {
+	if _fsys, ok := fsys.(SymlinkFS); ok {
+		return _fsys.Symlink(oldname, newname)
+	}
+	return errors.Wrap(ErrNotImplemented, "Symlink")
+}
+
+func ReadLink(fsys fs.FS, name string) (string, error) {
+	if _fsys, ok := fsys.(ReadLinkFS); ok {
+		return _fsys.ReadLink(name)
+	}
+	return "", errors.Wrap(ErrNotImplemented, "ReadLink")
+}
+
+// Lstat returns the file info of name without following symbolic links.
+// If fsys does not implement LstatFS, it has no symbolic links and fs.Stat is used.
+func Lstat(fsys fs.FS, name string) (fs.FileInfo, error) {
+	if _fsys, ok := fsys.(LstatFS); ok {
+		return _fsys.Lstat(name)
+	}
+	return fs.Stat(fsys, name)
+}
+
 func Close(fsys fs.FS) error {
 	if _fsys, ok := fsys.(CloseFS); ok {
 		return _fsys.Close()
@@ -93,6 +210,9 @@ func HasContent(fsys fs.FS) bool {
 	return false
 }
 
+// Copy copies src to dst. Paths containing "://" are handled by fs, all others by the local filesystem.
+//
+// Deprecated: use CopyFS
 func Copy(fs fs.FS, src, dst string) (int64, error) {
 	var srcFP io.ReadCloser
 	var err error
@@ -0,0 +1,139 @@
+package writefs
+
+import (
+	"context"
+	"emperror.dev/errors"
+	"io/fs"
+)
+
+// CreateContext creates a file with the context aware variant of fsys if available.
+// Otherwise it falls back to Create after checking ctx.
+func CreateContext(ctx context.Context, fsys fs.FS, path string) (FileWrite, error) {
+	if _fsys, ok := fsys.(CreateContextFS); ok {
+		return _fsys.CreateContext(ctx, path)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return Create(fsys, path)
+}
+
+// CreateAtomicContext creates a file with the context aware variant of fsys if available.
+// Otherwise it falls back to CreateAtomic after checking ctx.
+func CreateAtomicContext(ctx context.Context, fsys fs.FS, path string) (FileWrite, error) {
+	if _fsys, ok := fsys.(CreateAtomicContextFS); ok {
+		return _fsys.CreateAtomicContext(ctx, path)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return CreateAtomic(fsys, path)
+}
+
+// OpenFileContext opens name with the context aware variant of fsys if available.
+// Otherwise it falls back to OpenFile after checking ctx.
+func OpenFileContext(ctx context.Context, fsys fs.FS, name string, flag int, perm fs.FileMode) (FileWrite, error) {
+	if _fsys, ok := fsys.(OpenFileContextFS); ok {
+		return _fsys.OpenFileContext(ctx, name, flag, perm)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return OpenFile(fsys, name, flag, perm)
+}
+
+// OpenContext opens a file with the context aware variant of fsys if available.
+// Otherwise it falls back to fsys.Open after checking ctx.
+func OpenContext(ctx context.Context, fsys fs.FS, name string) (fs.File, error) {
+	if _fsys, ok := fsys.(OpenContextFS); ok {
+		return _fsys.OpenContext(ctx, name)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return fsys.Open(name)
+}
+
+// StatContext returns the file info with the context aware variant of fsys if available.
+// Otherwise it falls back to fs.Stat after checking ctx.
+func StatContext(ctx context.Context, fsys fs.FS, name string) (fs.FileInfo, error) {
+	if _fsys, ok := fsys.(StatContextFS); ok {
+		return _fsys.StatContext(ctx, name)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return fs.Stat(fsys, name)
+}
+
+// RemoveContext removes a file with the context aware variant of fsys if available.
+// Otherwise it falls back to Remove after checking ctx.
+func RemoveContext(ctx context.Context, fsys fs.FS, path string) error {
+	if _fsys, ok := fsys.(RemoveContextFS); ok {
+		return _fsys.RemoveContext(ctx, path)
+	}
+	if err := ctx.Err(); err != nil {
+		return errors.WithStack(err)
+	}
+	return Remove(fsys, path)
+}
+
+// RenameContext renames a file with the context aware variant of fsys if available.
+// Otherwise it falls back to Rename after checking ctx.
+func RenameContext(ctx context.Context, fsys fs.FS, oldPath, newPath string) error {
+	if _fsys, ok := fsys.(RenameContextFS); ok {
+		return _fsys.RenameContext(ctx, oldPath, newPath)
+	}
+	if err := ctx.Err(); err != nil {
+		return errors.WithStack(err)
+	}
+	return Rename(fsys, oldPath, newPath)
+}
+
+// ReadDirContext reads a directory with the context aware variant of fsys if available.
+// Otherwise it falls back to fs.ReadDir after checking ctx.
+func ReadDirContext(ctx context.Context, fsys fs.FS, name string) ([]fs.DirEntry, error) {
+	if _fsys, ok := fsys.(ReadDirContextFS); ok {
+		return _fsys.ReadDirContext(ctx, name)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return fs.ReadDir(fsys, name)
+}
+
+// MkDirContext creates a directory with the context aware variant of fsys if available.
+// Otherwise it falls back to MkDir after checking ctx.
+func MkDirContext(ctx context.Context, fsys fs.FS, path string) error {
+	if _fsys, ok := fsys.(MkDirContextFS); ok {
+		return _fsys.MkDirContext(ctx, path)
+	}
+	if err := ctx.Err(); err != nil {
+		return errors.WithStack(err)
+	}
+	return MkDir(fsys, path)
+}
+
+// MkDirAllContext creates a directory and its parents with the context aware variant of fsys if available.
+// Otherwise it falls back to MkDirAll after checking ctx.
+func MkDirAllContext(ctx context.Context, fsys fs.FS, path string) error {
+	if _fsys, ok := fsys.(MkDirAllContextFS); ok {
+		return _fsys.MkDirAllContext(ctx, path)
+	}
+	if err := ctx.Err(); err != nil {
+		return errors.WithStack(err)
+	}
+	return MkDirAll(fsys, path)
+}
+
+// RemoveAllContext removes path and its children with the context aware variant of fsys if available.
+// Otherwise it falls back to RemoveAll after checking ctx.
+func RemoveAllContext(ctx context.Context, fsys fs.FS, path string) error {
+	if _fsys, ok := fsys.(RemoveAllContextFS); ok {
+		return _fsys.RemoveAllContext(ctx, path)
+	}
+	if err := ctx.Err(); err != nil {
+		return errors.WithStack(err)
+	}
+	return RemoveAll(fsys, path)
+}
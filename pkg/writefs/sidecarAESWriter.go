@@ -1,17 +1,217 @@
 package writefs
 
 import (
+	"bytes"
+	"emperror.dev/errors"
+	"encoding/json"
+	"github.com/google/tink/go/core/registry"
+	"github.com/google/tink/go/keyset"
+	"github.com/google/tink/go/streamingaead"
+	"github.com/google/tink/go/tink"
+	"github.com/je4/utils/v2/pkg/encrypt"
 	"io"
+	"io/fs"
 )
 
-func NewSidecarAESWriter(w io.Writer, aesWriter io.WriteCloser) (io.WriteCloser, error) {
-	return nil, nil
+// KeySidecarSuffix is appended to the name of an encrypted file for the key sidecar
+const KeySidecarSuffix = ".key.json"
+
+func getKMSAEAD(keyURI string) (tink.AEAD, error) {
+	client, err := registry.GetKMSClient(keyURI)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot get KMS client for '%s'", keyURI)
+	}
+	aead, err := client.GetAEAD(keyURI)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot get AEAD for entry '%s'", keyURI)
+	}
+	return aead, nil
+}
+
+// NewSidecarAESWriter encrypts the data with a new AES256-GCM-HKDF streaming key and writes it to w.
+// On Close, the keyset, encrypted with the KMS key keyURI, and aad are written to keySidecar
+// as json encoded encrypt.KeyStruct. w is not closed.
+func NewSidecarAESWriter(w io.Writer, keySidecar io.WriteCloser, keyURI string, aad []byte) (io.WriteCloser, error) {
+	masterKey, err := getKMSAEAD(keyURI)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	handle, err := keyset.NewHandle(streamingaead.AES256GCMHKDF1MBKeyTemplate())
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot create keyset handle")
+	}
+	a, err := streamingaead.New(handle)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot create streamingaead")
+	}
+	encWriter, err := a.NewEncryptingWriter(w, aad)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot create encrypting writer")
+	}
+	return &sidecarAESWriter{
+		WriteCloser: encWriter,
+		keySidecar:  keySidecar,
+		handle:      handle,
+		masterKey:   masterKey,
+		aad:         aad,
+	}, nil
 }
 
 type sidecarAESWriter struct {
 	io.WriteCloser
+	keySidecar io.WriteCloser
+	handle     *keyset.Handle
+	masterKey  tink.AEAD
+	aad        []byte
 }
 
 func (s *sidecarAESWriter) Close() error {
-	return s.WriteCloser.Close()
+	var errs = []error{}
+	if err := s.WriteCloser.Close(); err != nil {
+		errs = append(errs, errors.Wrap(err, "cannot close encrypting writer"))
+	}
+	if len(errs) == 0 {
+		keyBuf := bytes.NewBuffer(nil)
+		if err := s.handle.Write(keyset.NewBinaryWriter(keyBuf), s.masterKey); err != nil {
+			errs = append(errs, errors.Wrap(err, "cannot write keyset"))
+		} else {
+			ks := encrypt.KeyStruct{
+				EncryptedKey: keyBuf.Bytes(),
+				Aad:          s.aad,
+			}
+			jsonBytes, err := json.Marshal(ks)
+			if err != nil {
+				errs = append(errs, errors.Wrap(err, "cannot marshal key"))
+			} else if _, err := s.keySidecar.Write(jsonBytes); err != nil {
+				errs = append(errs, errors.Wrap(err, "cannot write key sidecar"))
+			}
+		}
+	}
+	if err := s.keySidecar.Close(); err != nil {
+		errs = append(errs, errors.Wrap(err, "cannot close key sidecar"))
+	}
+	return errors.Combine(errs...)
+}
+
+// NewSidecarAESReader decrypts r with the keyset and aad from keySidecar, which has been written by NewSidecarAESWriter.
+// The keyset is decrypted with the KMS key keyURI.
+func NewSidecarAESReader(r io.Reader, keySidecar io.Reader, keyURI string) (io.Reader, error) {
+	ks := encrypt.KeyStruct{}
+	if err := json.NewDecoder(keySidecar).Decode(&ks); err != nil {
+		return nil, errors.Wrap(err, "cannot decode key sidecar")
+	}
+	masterKey, err := getKMSAEAD(keyURI)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	handle, err := keyset.Read(keyset.NewBinaryReader(bytes.NewReader(ks.EncryptedKey)), masterKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot read keyset")
+	}
+	a, err := streamingaead.New(handle)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot create streamingaead")
+	}
+	decReader, err := a.NewDecryptingReader(r, ks.Aad)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot create decrypting reader")
+	}
+	return decReader, nil
+}
+
+// CreateSidecarAES creates the encrypted file path in fsys. The key is written to path+KeySidecarSuffix on Close.
+// If the key sidecar cannot be written, the encrypted file is discarded, because it could not be decrypted.
+func CreateSidecarAES(fsys fs.FS, path, keyURI string) (FileWrite, error) {
+	fp, err := Create(fsys, path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot create '%s'", path)
+	}
+	keyPath := path + KeySidecarSuffix
+	keyFP, err := Create(fsys, keyPath)
+	if err != nil {
+		discard(fsys, path, fp)
+		return nil, errors.Wrapf(err, "cannot create '%s'", keyPath)
+	}
+	encWriter, err := NewSidecarAESWriter(fp, keyFP, keyURI, []byte(path))
+	if err != nil {
+		discard(fsys, path, fp)
+		discard(fsys, keyPath, keyFP)
+		return nil, errors.Wrapf(err, "cannot create encrypting writer for '%s'", path)
+	}
+	return &sidecarAESFile{
+		WriteCloser: encWriter,
+		fsys:        fsys,
+		path:        path,
+		fp:          fp,
+		keyFP:       keyFP,
+	}, nil
+}
+
+// discard aborts fp. If fp cannot be aborted, it is closed and path is removed
+func discard(fsys fs.FS, path string, fp FileWrite) {
+	if err := Abort(fp); err == nil {
+		return
+	}
+	fp.Close()
+	Remove(fsys, path)
+}
+
+type sidecarAESFile struct {
+	io.WriteCloser
+	fsys  fs.FS
+	path  string
+	fp    FileWrite
+	keyFP FileWrite
+}
+
+// Close finishes the encryption and writes the key sidecar. The encrypted file is discarded if this fails
+func (f *sidecarAESFile) Close() error {
+	if err := f.WriteCloser.Close(); err != nil {
+		discard(f.fsys, f.path, f.fp)
+		// the key sidecar has been closed by the encrypting writer
+		Remove(f.fsys, f.path+KeySidecarSuffix)
+		return errors.Wrapf(err, "cannot write '%s'", f.path)
+	}
+	return errors.Wrapf(f.fp.Close(), "cannot close '%s'", f.path)
 }
+
+// Abort discards the encrypted file and the key sidecar
+func (f *sidecarAESFile) Abort() error {
+	discard(f.fsys, f.path, f.fp)
+	discard(f.fsys, f.path+KeySidecarSuffix, f.keyFP)
+	return nil
+}
+
+// OpenSidecarAES opens the encrypted file path in fsys, which has been written by CreateSidecarAES
+func OpenSidecarAES(fsys fs.FS, path, keyURI string) (io.ReadCloser, error) {
+	keyPath := path + KeySidecarSuffix
+	keyData, err := fs.ReadFile(fsys, keyPath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot read '%s'", keyPath)
+	}
+	fp, err := fsys.Open(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot open '%s'", path)
+	}
+	decReader, err := NewSidecarAESReader(fp, bytes.NewReader(keyData), keyURI)
+	if err != nil {
+		fp.Close()
+		return nil, errors.Wrapf(err, "cannot create decrypting reader for '%s'", path)
+	}
+	return &sidecarAESReadCloser{
+		Reader: decReader,
+		Closer: fp,
+	}, nil
+}
+
+type sidecarAESReadCloser struct {
+	io.Reader
+	io.Closer
+}
+
+var (
+	_ io.WriteCloser = (*sidecarAESWriter)(nil)
+	_ FileWrite      = (*sidecarAESFile)(nil)
+	_ AbortFileWrite = (*sidecarAESFile)(nil)
+	_ io.ReadCloser  = (*sidecarAESReadCloser)(nil)
+)
@@ -0,0 +1,138 @@
+package writefs_test
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"github.com/google/tink/go/core/registry"
+	"github.com/google/tink/go/testing/fakekms"
+	"github.com/je4/filesystem/v3/pkg/osfsrw"
+	"github.com/je4/filesystem/v3/pkg/writefs"
+	"github.com/je4/utils/v2/pkg/encrypt"
+	"github.com/rs/zerolog"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestSidecarAES(t *testing.T) {
+	keyURI, err := fakekms.NewKeyURI()
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := fakekms.NewClient(keyURI)
+	if err != nil {
+		t.Fatal(err)
+	}
+	registry.RegisterKMSClient(client)
+
+	logger := zerolog.New(os.Stderr).With().Timestamp().Logger()
+	fsys, err := osfsrw.NewFS(t.TempDir(), &logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// more than one segment
+	data := make([]byte, 3*1024*1024+17)
+	if _, err := rand.Read(data); err != nil {
+		t.Fatal(err)
+	}
+	wfp, err := writefs.CreateSidecarAES(fsys, "data.bin.aes", keyURI)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := wfp.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := wfp.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	encData, err := fs.ReadFile(fsys, "data.bin.aes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(encData, data[:1024]) {
+		t.Error("data is not encrypted")
+	}
+	keyData, err := fs.ReadFile(fsys, "data.bin.aes"+writefs.KeySidecarSuffix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ks := encrypt.KeyStruct{}
+	if err := json.Unmarshal(keyData, &ks); err != nil {
+		t.Fatal(err)
+	}
+	if string(ks.Aad) != "data.bin.aes" {
+		t.Errorf("aad: expected 'data.bin.aes', got '%s'", ks.Aad)
+	}
+
+	rfp, err := writefs.OpenSidecarAES(fsys, "data.bin.aes", keyURI)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rfp.Close()
+	decData, err := io.ReadAll(rfp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, decData) {
+		t.Error("decrypted data differs")
+	}
+}
+
+// keyFailFS fails to write key sidecars
+type keyFailFS struct {
+	writefs.ReadWriteFS
+}
+
+func (f *keyFailFS) Create(path string) (writefs.FileWrite, error) {
+	fp, err := writefs.Create(f.ReadWriteFS, path)
+	if err != nil || !strings.HasSuffix(path, writefs.KeySidecarSuffix) {
+		return fp, err
+	}
+	return &failWrite{FileWrite: fp}, nil
+}
+
+type failWrite struct {
+	writefs.FileWrite
+}
+
+func (f *failWrite) Write(p []byte) (int, error) {
+	return 0, errors.New("write failed")
+}
+
+func TestSidecarAESKeyFailure(t *testing.T) {
+	keyURI, err := fakekms.NewKeyURI()
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := fakekms.NewClient(keyURI)
+	if err != nil {
+		t.Fatal(err)
+	}
+	registry.RegisterKMSClient(client)
+
+	logger := zerolog.New(os.Stderr).With().Timestamp().Logger()
+	osFS, err := osfsrw.NewFS(t.TempDir(), &logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fsys := &keyFailFS{ReadWriteFS: osFS}
+	wfp, err := writefs.CreateSidecarAES(fsys, "data.bin.aes", keyURI)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := wfp.Write([]byte("data")); err != nil {
+		t.Fatal(err)
+	}
+	if err := wfp.Close(); err == nil {
+		t.Fatal("no error for failed key sidecar")
+	}
+	if _, err := fs.Stat(osFS, "data.bin.aes"); err == nil {
+		t.Error("encrypted file without key published")
+	}
+}
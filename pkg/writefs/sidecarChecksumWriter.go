@@ -17,7 +17,7 @@ func NewSidecarChecksumWriter(w io.Writer, sidecars map[checksum.DigestAlgorithm
 	}
 	sw := &sidecarChecksumWriter{
 		ChecksumWriter: csw,
-		sidecars:       make(map[checksum.DigestAlgorithm]io.WriteCloser),
+		sidecars:       sidecars,
 	}
 	return sw, nil
 }
@@ -28,6 +28,9 @@ type sidecarChecksumWriter struct {
 }
 
 func (s *sidecarChecksumWriter) Close() error {
+	if err := s.ChecksumWriter.Close(); err != nil {
+		return errors.Wrap(err, "cannot close checksum writer")
+	}
 	digests, err := s.ChecksumWriter.GetChecksums()
 	if err != nil {
 		return errors.Wrap(err, "cannot get checksums")
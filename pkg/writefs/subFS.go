@@ -1,10 +1,12 @@
 package writefs
 
 import (
-	"errors"
+	"context"
+	"emperror.dev/errors"
 	"fmt"
 	"io/fs"
 	"path/filepath"
+	"time"
 )
 
 type subFS struct {
@@ -28,6 +30,33 @@ func (sfs *subFS) Rename(oldPath, newPath string) error {
 	)
 }
 
+func (sfs *subFS) RemoveAll(path string) error {
+	return RemoveAll(sfs.fsys, filepath.ToSlash(filepath.Join(sfs.dir, path)))
+}
+
+func (sfs *subFS) Chtimes(name string, atime, mtime time.Time) error {
+	return Chtimes(sfs.fsys, filepath.ToSlash(filepath.Join(sfs.dir, name)), atime, mtime)
+}
+
+func (sfs *subFS) Chmod(name string, mode fs.FileMode) error {
+	return Chmod(sfs.fsys, filepath.ToSlash(filepath.Join(sfs.dir, name)), mode)
+}
+
+func (sfs *subFS) MkDirAll(path string) error {
+	return MkDirAll(sfs.fsys, filepath.ToSlash(filepath.Join(sfs.dir, path)))
+}
+
+func (sfs *subFS) CopyFile(src, dst string) error {
+	cfs, ok := sfs.fsys.(CopyFileFS)
+	if !ok {
+		return errors.Wrap(ErrNotImplemented, "CopyFile")
+	}
+	return cfs.CopyFile(
+		filepath.ToSlash(filepath.Join(sfs.dir, src)),
+		filepath.ToSlash(filepath.Join(sfs.dir, dst)),
+	)
+}
+
 func (sfs *subFS) Remove(path string) error {
 	return Remove(sfs.fsys, filepath.ToSlash(filepath.Join(sfs.dir, path)))
 }
@@ -63,6 +92,31 @@ func (sfs *subFS) Create(path string) (FileWrite, error) {
 	return Create(sfs.fsys, filepath.ToSlash(filepath.Join(sfs.dir, path)))
 }
 
+func (sfs *subFS) CreateAtomic(path string) (FileWrite, error) {
+	return CreateAtomic(sfs.fsys, filepath.ToSlash(filepath.Join(sfs.dir, path)))
+}
+
+func (sfs *subFS) OpenFile(name string, flag int, perm fs.FileMode) (FileWrite, error) {
+	return OpenFile(sfs.fsys, filepath.ToSlash(filepath.Join(sfs.dir, name)), flag, perm)
+}
+
+func (sfs *subFS) CreateWriterAt(path string) (FileWriterAt, error) {
+	return CreateWriterAt(sfs.fsys, filepath.ToSlash(filepath.Join(sfs.dir, path)))
+}
+
+// Symlink creates a link to oldname, which is not changed
+func (sfs *subFS) Symlink(oldname, newname string) error {
+	return Symlink(sfs.fsys, oldname, filepath.ToSlash(filepath.Join(sfs.dir, newname)))
+}
+
+func (sfs *subFS) ReadLink(name string) (string, error) {
+	return ReadLink(sfs.fsys, filepath.ToSlash(filepath.Join(sfs.dir, name)))
+}
+
+func (sfs *subFS) Lstat(name string) (fs.FileInfo, error) {
+	return Lstat(sfs.fsys, filepath.ToSlash(filepath.Join(sfs.dir, name)))
+}
+
 func (sfs *subFS) MkDir(path string) error {
 	mkdirFS, ok := sfs.fsys.(MkDirFS)
 	if !ok {
@@ -71,16 +125,88 @@ func (sfs *subFS) MkDir(path string) error {
 	return mkdirFS.MkDir(filepath.ToSlash(filepath.Join(sfs.dir, path)))
 }
 
+func (sfs *subFS) CreateContext(ctx context.Context, path string) (FileWrite, error) {
+	return CreateContext(ctx, sfs.fsys, filepath.ToSlash(filepath.Join(sfs.dir, path)))
+}
+
+func (sfs *subFS) CreateAtomicContext(ctx context.Context, path string) (FileWrite, error) {
+	return CreateAtomicContext(ctx, sfs.fsys, filepath.ToSlash(filepath.Join(sfs.dir, path)))
+}
+
+func (sfs *subFS) OpenFileContext(ctx context.Context, name string, flag int, perm fs.FileMode) (FileWrite, error) {
+	return OpenFileContext(ctx, sfs.fsys, filepath.ToSlash(filepath.Join(sfs.dir, name)), flag, perm)
+}
+
+func (sfs *subFS) OpenContext(ctx context.Context, name string) (fs.File, error) {
+	return OpenContext(ctx, sfs.fsys, filepath.ToSlash(filepath.Join(sfs.dir, name)))
+}
+
+func (sfs *subFS) StatContext(ctx context.Context, name string) (fs.FileInfo, error) {
+	return StatContext(ctx, sfs.fsys, filepath.ToSlash(filepath.Join(sfs.dir, name)))
+}
+
+func (sfs *subFS) RemoveContext(ctx context.Context, path string) error {
+	return RemoveContext(ctx, sfs.fsys, filepath.ToSlash(filepath.Join(sfs.dir, path)))
+}
+
+func (sfs *subFS) RenameContext(ctx context.Context, oldPath, newPath string) error {
+	return RenameContext(
+		ctx,
+		sfs.fsys,
+		filepath.ToSlash(filepath.Join(sfs.dir, oldPath)),
+		filepath.ToSlash(filepath.Join(sfs.dir, newPath)),
+	)
+}
+
+func (sfs *subFS) ReadDirContext(ctx context.Context, name string) ([]fs.DirEntry, error) {
+	return ReadDirContext(ctx, sfs.fsys, filepath.ToSlash(filepath.Join(sfs.dir, name)))
+}
+
+func (sfs *subFS) MkDirContext(ctx context.Context, path string) error {
+	return MkDirContext(ctx, sfs.fsys, filepath.ToSlash(filepath.Join(sfs.dir, path)))
+}
+
+func (sfs *subFS) MkDirAllContext(ctx context.Context, path string) error {
+	return MkDirAllContext(ctx, sfs.fsys, filepath.ToSlash(filepath.Join(sfs.dir, path)))
+}
+
+func (sfs *subFS) RemoveAllContext(ctx context.Context, path string) error {
+	return RemoveAllContext(ctx, sfs.fsys, filepath.ToSlash(filepath.Join(sfs.dir, path)))
+}
+
 var (
-	_ fs.FS         = &subFS{}
-	_ CreateFS      = &subFS{}
-	_ MkDirFS       = &subFS{}
-	_ RenameFS      = &subFS{}
-	_ RemoveFS      = &subFS{}
-	_ FullpathFS    = &subFS{}
-	_ fs.ReadDirFS  = &subFS{}
-	_ fs.ReadFileFS = &subFS{}
-	_ fs.StatFS     = &subFS{}
-	_ fs.SubFS      = &subFS{}
-	_ fmt.Stringer  = &subFS{}
+	_ fs.FS            = &subFS{}
+	_ CreateFS         = &subFS{}
+	_ CreateAtomicFS   = &subFS{}
+	_ OpenFileFS       = &subFS{}
+	_ CreateWriterAtFS = &subFS{}
+	_ SymlinkFS        = &subFS{}
+	_ ReadLinkFS       = &subFS{}
+	_ LstatFS          = &subFS{}
+	_ MkDirFS          = &subFS{}
+	_ RenameFS         = &subFS{}
+	_ RemoveFS         = &subFS{}
+	_ RemoveAllFS      = &subFS{}
+	_ MkDirAllFS       = &subFS{}
+	_ CopyFileFS       = &subFS{}
+	_ ChtimesFS        = &subFS{}
+	_ ChmodFS          = &subFS{}
+	_ FullpathFS       = &subFS{}
+	_ fs.ReadDirFS     = &subFS{}
+	_ fs.ReadFileFS    = &subFS{}
+	_ fs.StatFS        = &subFS{}
+	_ fs.SubFS         = &subFS{}
+	_ fmt.Stringer     = &subFS{}
+
+	_ CreateContextFS       = &subFS{}
+	_ CreateAtomicContextFS = &subFS{}
+	_ OpenFileContextFS     = &subFS{}
+	_ OpenContextFS         = &subFS{}
+	_ StatContextFS         = &subFS{}
+	_ RemoveContextFS       = &subFS{}
+	_ RenameContextFS       = &subFS{}
+	_ ReadDirContextFS      = &subFS{}
+	_ MkDirContextFS        = &subFS{}
+	_ MkDirAllContextFS     = &subFS{}
+	_ RemoveAllContextFS    = &subFS{}
 )
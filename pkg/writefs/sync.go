@@ -0,0 +1,339 @@
+package writefs
+
+import (
+	"emperror.dev/errors"
+	"github.com/je4/utils/v2/pkg/checksum"
+	"golang.org/x/exp/slices"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// SyncCompare defines how Sync decides whether a file has to be copied
+type SyncCompare uint8
+
+const (
+	// SyncCompareSizeModTime copies a file if the sizes differ or the source is newer than the destination
+	SyncCompareSizeModTime SyncCompare = iota
+	// SyncCompareChecksum copies a file if the sizes or the checksums differ
+	SyncCompareChecksum
+)
+
+// SyncAction is the action Sync has taken for a path
+type SyncAction string
+
+const (
+	SyncActionCopy   SyncAction = "copy"
+	SyncActionUpdate SyncAction = "update"
+	SyncActionDelete SyncAction = "delete"
+	SyncActionSkip   SyncAction = "skip"
+)
+
+// SyncOptions controls the behaviour of Sync
+type SyncOptions struct {
+	// Compare defines how files are compared
+	Compare SyncCompare
+	// ChecksumAlgorithm is used with SyncCompareChecksum (default: sha512)
+	ChecksumAlgorithm checksum.DigestAlgorithm
+	// DryRun only reports the actions without changing the destination
+	DryRun bool
+	// Delete removes files and folders in the destination which do not exist in the source
+	Delete bool
+	// Include restricts the files to the ones matching at least one glob
+	Include []string
+	// Exclude skips files and folders matching any glob
+	Exclude []string
+	// Workers is the number of parallel copy operations (default: 1)
+	Workers int
+}
+
+// SyncResult is the outcome of Sync for a single path relative to the sync folders
+type SyncResult struct {
+	Path   string
+	Action SyncAction
+	Size   int64
+	Err    error
+}
+
+// SyncReport is the result of Sync
+type SyncReport struct {
+	DryRun  bool
+	Results []*SyncResult
+	// Bytes is the number of bytes copied
+	Bytes int64
+}
+
+// Count returns the number of successful results with the given action
+func (r *SyncReport) Count(action SyncAction) int {
+	var count int
+	for _, res := range r.Results {
+		if res.Action == action && res.Err == nil {
+			count++
+		}
+	}
+	return count
+}
+
+// Errors returns the errors of all failed results
+func (r *SyncReport) Errors() []error {
+	var errs = []error{}
+	for _, res := range r.Results {
+		if res.Err != nil {
+			errs = append(errs, errors.Wrapf(res.Err, "cannot %s '%s'", res.Action, res.Path))
+		}
+	}
+	return errs
+}
+
+// matchGlobs checks name against the patterns. Patterns without a slash are matched against the base name
+func matchGlobs(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		target := name
+		if !strings.Contains(pattern, "/") {
+			target = path.Base(name)
+		}
+		if ok, _ := path.Match(pattern, target); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func cleanSyncDir(dir string) string {
+	dir = strings.Trim(filepath.ToSlash(filepath.Clean(dir)), "/")
+	if dir == "" {
+		return "."
+	}
+	return dir
+}
+
+func syncRelPath(root, name string) string {
+	if root == "." {
+		return name
+	}
+	return strings.TrimPrefix(strings.TrimPrefix(name, root), "/")
+}
+
+func syncJoin(root, rel string) string {
+	if root == "." {
+		return rel
+	}
+	return root + "/" + rel
+}
+
+// Sync synchronizes the tree srcDir of srcFS to dstDir of dstFS.
+// Files are copied with CopyFS. Per file errors are collected in the report and combined in the returned error.
+func Sync(srcFS fs.FS, srcDir string, dstFS fs.FS, dstDir string, opts *SyncOptions) (*SyncReport, error) {
+	if opts == nil {
+		opts = &SyncOptions{}
+	}
+	for _, pattern := range append(slices.Clone(opts.Include), opts.Exclude...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, errors.Wrapf(err, "invalid glob '%s'", pattern)
+		}
+	}
+	alg := opts.ChecksumAlgorithm
+	if alg == "" {
+		alg = checksum.DigestSHA512
+	}
+	workers := opts.Workers
+	if workers < 1 {
+		workers = 1
+	}
+	srcDir = cleanSyncDir(srcDir)
+	dstDir = cleanSyncDir(dstDir)
+
+	// collect source
+	srcFiles := map[string]fs.FileInfo{}
+	srcDirs := map[string]bool{}
+	if err := fs.WalkDir(srcFS, srcDir, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		rel := syncRelPath(srcDir, name)
+		if rel == "" {
+			return nil
+		}
+		if matchGlobs(opts.Exclude, rel) {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			srcDirs[rel] = true
+			return nil
+		}
+		if len(opts.Include) > 0 && !matchGlobs(opts.Include, rel) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return errors.Wrapf(err, "cannot get info of '%s'", name)
+		}
+		srcFiles[rel] = info
+		return nil
+	}); err != nil {
+		return nil, errors.Wrapf(err, "cannot walk source '%s'", srcDir)
+	}
+
+	// collect destination
+	report := &SyncReport{DryRun: opts.DryRun}
+	dstFiles := map[string]fs.FileInfo{}
+	if err := fs.WalkDir(dstFS, dstDir, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if name == dstDir && errors.Is(err, fs.ErrNotExist) {
+				return fs.SkipAll
+			}
+			return errors.WithStack(err)
+		}
+		rel := syncRelPath(dstDir, name)
+		if rel == "" {
+			return nil
+		}
+		if matchGlobs(opts.Exclude, rel) {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			// with include globs, the folder may contain files outside the include set.
+			// in this case, the walk continues and only the included files are deleted
+			if opts.Delete && !srcDirs[rel] && len(opts.Include) == 0 {
+				report.Results = append(report.Results, &SyncResult{Path: rel, Action: SyncActionDelete})
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if len(opts.Include) > 0 && !matchGlobs(opts.Include, rel) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return errors.Wrapf(err, "cannot get info of '%s'", name)
+		}
+		if _, ok := srcFiles[rel]; !ok {
+			if opts.Delete {
+				report.Results = append(report.Results, &SyncResult{Path: rel, Action: SyncActionDelete, Size: info.Size()})
+			}
+			return nil
+		}
+		dstFiles[rel] = info
+		return nil
+	}); err != nil {
+		return nil, errors.Wrapf(err, "cannot walk destination '%s'", dstDir)
+	}
+
+	// deletes are executed before copies, so that a file can replace a folder of the same name and vice versa
+	deletes := slices.Clone(report.Results)
+	copies := []*SyncResult{}
+	for rel, srcInfo := range srcFiles {
+		res := &SyncResult{Path: rel, Size: srcInfo.Size()}
+		if _, ok := dstFiles[rel]; ok {
+			res.Action = SyncActionUpdate
+		} else {
+			res.Action = SyncActionCopy
+		}
+		copies = append(copies, res)
+	}
+	report.Results = append(report.Results, copies...)
+
+	// execute actions
+	var lock sync.Mutex
+	run := func(results []*SyncResult) {
+		jobs := make(chan *SyncResult)
+		wg := &sync.WaitGroup{}
+		for i := 0; i < workers; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for res := range jobs {
+					srcPath := syncJoin(srcDir, res.Path)
+					dstPath := syncJoin(dstDir, res.Path)
+					switch res.Action {
+					case SyncActionDelete:
+						if !opts.DryRun {
+							res.Err = RemoveAll(dstFS, dstPath)
+						}
+					case SyncActionCopy, SyncActionUpdate:
+						if res.Action == SyncActionUpdate {
+							equal, err := syncEqual(srcFS, srcPath, srcFiles[res.Path], dstFS, dstPath, dstFiles[res.Path], opts.Compare, alg)
+							if err != nil {
+								res.Err = err
+								continue
+							}
+							if equal {
+								res.Action = SyncActionSkip
+								continue
+							}
+						}
+						if opts.DryRun {
+							continue
+						}
+						// errors are ignored, Create reports missing folders
+						if parent := path.Dir(dstPath); parent != "." {
+							_ = MkDirAll(dstFS, parent)
+						}
+						num, err := CopyFS(srcFS, srcPath, dstFS, dstPath, nil)
+						if err != nil {
+							res.Err = err
+							continue
+						}
+						lock.Lock()
+						report.Bytes += num
+						lock.Unlock()
+					}
+				}
+			}()
+		}
+		for _, res := range results {
+			jobs <- res
+		}
+		close(jobs)
+		wg.Wait()
+	}
+	run(deletes)
+	run(copies)
+
+	slices.SortFunc(report.Results, func(a, b *SyncResult) int {
+		return strings.Compare(a.Path, b.Path)
+	})
+	return report, errors.Combine(report.Errors()...)
+}
+
+// syncEqual compares source and destination file
+func syncEqual(srcFS fs.FS, srcPath string, srcInfo fs.FileInfo, dstFS fs.FS, dstPath string, dstInfo fs.FileInfo, compare SyncCompare, alg checksum.DigestAlgorithm) (bool, error) {
+	if srcInfo.Size() != dstInfo.Size() {
+		return false, nil
+	}
+	switch compare {
+	case SyncCompareChecksum:
+		srcChecksum, err := fileChecksum(srcFS, srcPath, alg)
+		if err != nil {
+			return false, errors.WithStack(err)
+		}
+		dstChecksum, err := fileChecksum(dstFS, dstPath, alg)
+		if err != nil {
+			return false, errors.WithStack(err)
+		}
+		return srcChecksum == dstChecksum, nil
+	default:
+		return !srcInfo.ModTime().After(dstInfo.ModTime()), nil
+	}
+}
+
+func fileChecksum(fsys fs.FS, name string, alg checksum.DigestAlgorithm) (string, error) {
+	fp, err := fsys.Open(name)
+	if err != nil {
+		return "", errors.Wrapf(err, "cannot open '%s'", name)
+	}
+	defer fp.Close()
+	cs, err := checksum.Checksum(fp, alg)
+	if err != nil {
+		return "", errors.Wrapf(err, "cannot calculate checksum of '%s'", name)
+	}
+	return cs, nil
+}
@@ -0,0 +1,120 @@
+package writefs_test
+
+import (
+	"github.com/je4/filesystem/v3/pkg/osfsrw"
+	"github.com/je4/filesystem/v3/pkg/writefs"
+	"github.com/rs/zerolog"
+	"io/fs"
+	"os"
+	"testing"
+)
+
+func TestSync(t *testing.T) {
+	logger := zerolog.New(os.Stderr).With().Timestamp().Logger()
+	srcFS, err := osfsrw.NewFS(t.TempDir(), &logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dstFS, err := osfsrw.NewFS(t.TempDir(), &logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range map[string]string{
+		"a.txt":       "a",
+		"b/b.txt":     "b",
+		"b/c/c.txt":   "c",
+		"b/c/skip.md": "skip",
+	} {
+		if _, err := writefs.WriteFile(srcFS, name, []byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := writefs.WriteFile(dstFS, "extra/x.txt", []byte("x")); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := &writefs.SyncOptions{
+		Compare: writefs.SyncCompareChecksum,
+		Delete:  true,
+		Exclude: []string{"*.md"},
+		Workers: 2,
+		DryRun:  true,
+	}
+	report, err := writefs.Sync(srcFS, "", dstFS, "", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Count(writefs.SyncActionCopy) != 3 || report.Count(writefs.SyncActionDelete) != 1 {
+		t.Fatalf("unexpected dry run report: %+v", report.Results)
+	}
+	if _, err := fs.Stat(dstFS, "a.txt"); err == nil {
+		t.Fatal("dry run changed destination")
+	}
+
+	opts.DryRun = false
+	if _, err := writefs.Sync(srcFS, "", dstFS, "", opts); err != nil {
+		t.Fatal(err)
+	}
+	data, err := fs.ReadFile(dstFS, "b/c/c.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "c" {
+		t.Fatalf("wrong content '%s'", data)
+	}
+	if _, err := fs.Stat(dstFS, "b/c/skip.md"); err == nil {
+		t.Fatal("excluded file copied")
+	}
+	if _, err := fs.Stat(dstFS, "extra"); err == nil {
+		t.Fatal("extraneous folder not deleted")
+	}
+
+	report, err = writefs.Sync(srcFS, "", dstFS, "", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Count(writefs.SyncActionSkip) != 3 || report.Bytes != 0 {
+		t.Fatalf("unexpected second run report: %+v", report.Results)
+	}
+}
+
+func TestSyncDeleteInclude(t *testing.T) {
+	logger := zerolog.New(os.Stderr).With().Timestamp().Logger()
+	srcFS, err := osfsrw.NewFS(t.TempDir(), &logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dstFS, err := osfsrw.NewFS(t.TempDir(), &logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := writefs.WriteFile(srcFS, "a.txt/b.txt", []byte("b")); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range map[string]string{
+		"a.txt":         "a",
+		"extra/x.txt":   "x",
+		"extra/keep.md": "keep",
+	} {
+		if _, err := writefs.WriteFile(dstFS, name, []byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	opts := &writefs.SyncOptions{
+		Delete:  true,
+		Include: []string{"*.txt"},
+		Workers: 4,
+	}
+	if _, err := writefs.Sync(srcFS, "", dstFS, "", opts); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fs.Stat(dstFS, "a.txt/b.txt"); err != nil {
+		t.Fatal("folder replacing a file not copied")
+	}
+	if _, err := fs.Stat(dstFS, "extra/x.txt"); err == nil {
+		t.Fatal("included file not deleted")
+	}
+	if _, err := fs.Stat(dstFS, "extra/keep.md"); err != nil {
+		t.Fatal("file outside the include set deleted")
+	}
+}
@@ -0,0 +1,69 @@
+package writefs
+
+import (
+	"context"
+	"emperror.dev/errors"
+	"io"
+	"net"
+	"syscall"
+)
+
+// ErrTransient marks errors of temporary failures (timeouts, lost connections, server errors),
+// after which the same operation may succeed if it is retried
+var ErrTransient = errors.NewPlain("transient error")
+
+// TransientError is implemented by errors, which know whether they are transient
+type TransientError interface {
+	Transient() bool
+}
+
+type transientError struct {
+	err error
+}
+
+func (t *transientError) Error() string { return t.err.Error() }
+
+func (t *transientError) Unwrap() error { return t.err }
+
+func (t *transientError) Is(target error) bool { return target == ErrTransient }
+
+// Transient marks err as transient. nil stays nil
+func Transient(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &transientError{err: err}
+}
+
+// IsTransient reports whether err or one of the errors it wraps is marked as transient.
+// Backends mark their errors with Transient, all other errors are permanent
+func IsTransient(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, ErrTransient) {
+		return true
+	}
+	var te TransientError
+	if errors.As(err, &te) {
+		return te.Transient()
+	}
+	return false
+}
+
+// IsNetworkFailure reports whether err is a broken or refused connection or a network timeout.
+// Cancelled contexts and expired deadlines of the caller are not network failures
+func IsNetworkFailure(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.ECONNABORTED) ||
+		errors.Is(err, syscall.EPIPE) ||
+		errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
@@ -0,0 +1,216 @@
+// Package writefstest implements support for testing implementations of writefs.ReadWriteFS.
+package writefstest
+
+import (
+	"emperror.dev/errors"
+	"github.com/je4/filesystem/v3/pkg/writefs"
+	"golang.org/x/exp/slices"
+	"io/fs"
+	"path"
+	"testing"
+)
+
+// Dir is the folder of fsys which is used by TestReadWriteFS
+const Dir = "writefstest"
+
+// Options controls TestReadWriteFS
+type Options struct {
+	// Reopen returns a filesystem which contains all files written to fsys so far.
+	// It is needed for filesystems which publish new files on Close, like zipfsrw.
+	Reopen func(fsys writefs.ReadWriteFS) (writefs.ReadWriteFS, error)
+}
+
+// testFiles are created in this order, so that ReadDir has to sort them
+var testFiles = []struct {
+	name, content string
+}{
+	{"file.txt", "file"},
+	{"dir/c.txt", "c"},
+	{"dir/a.txt", "a"},
+	{"dir/sub/x.txt", "x"},
+	{"dir/b.txt", "b"},
+	{"missing/parent/file.txt", "parent"},
+}
+
+// TestReadWriteFS tests the semantics of Create, MkDirAll, Rename, Remove, Stat, ReadDir and Sub
+// of fsys within the folder Dir. Dir must not exist.
+// Operations, which are not implemented by fsys, are skipped.
+func TestReadWriteFS(t *testing.T, fsys writefs.ReadWriteFS, opts *Options) {
+	t.Helper()
+	if opts == nil {
+		opts = &Options{}
+	}
+	reopen := func(t *testing.T) {
+		t.Helper()
+		if opts.Reopen == nil {
+			return
+		}
+		var err error
+		if fsys, err = opts.Reopen(fsys); err != nil {
+			t.Fatalf("cannot reopen filesystem: %v", err)
+		}
+	}
+	p := func(name string) string {
+		return path.Join(Dir, name)
+	}
+
+	t.Run("create", func(t *testing.T) {
+		for _, f := range testFiles {
+			writeFile(t, fsys, p(f.name), f.content)
+		}
+		reopen(t)
+		for _, f := range testFiles {
+			checkFile(t, fsys, p(f.name), f.content)
+		}
+	})
+
+	t.Run("stat", func(t *testing.T) {
+		info, err := fs.Stat(fsys, p("dir/a.txt"))
+		if err != nil {
+			t.Fatalf("cannot stat '%s': %v", p("dir/a.txt"), err)
+		}
+		if info.IsDir() || info.Name() != "a.txt" || info.Size() != 1 {
+			t.Errorf("wrong file info of '%s': name=%s, size=%d, isDir=%v", p("dir/a.txt"), info.Name(), info.Size(), info.IsDir())
+		}
+		info, err = fs.Stat(fsys, p("dir/sub"))
+		if err != nil {
+			t.Fatalf("cannot stat '%s': %v", p("dir/sub"), err)
+		}
+		if !info.IsDir() || info.Name() != "sub" {
+			t.Errorf("wrong file info of '%s': name=%s, isDir=%v", p("dir/sub"), info.Name(), info.IsDir())
+		}
+		if _, err := fs.Stat(fsys, p("notfound.txt")); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("stat of missing file: expected fs.ErrNotExist, got %v", err)
+		}
+		if fp, err := fsys.Open(p("notfound.txt")); !errors.Is(err, fs.ErrNotExist) {
+			if err == nil {
+				fp.Close()
+			}
+			t.Errorf("open of missing file: expected fs.ErrNotExist, got %v", err)
+		}
+	})
+
+	t.Run("readdir", func(t *testing.T) {
+		if _, ok := fsys.(fs.ReadDirFS); !ok {
+			t.Skip("ReadDir not implemented")
+		}
+		entries, err := fs.ReadDir(fsys, p("dir"))
+		if err != nil {
+			t.Fatalf("cannot read directory '%s': %v", p("dir"), err)
+		}
+		names := []string{}
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+			if isDir := entry.Name() == "sub"; entry.IsDir() != isDir {
+				t.Errorf("'%s' has isDir=%v", entry.Name(), entry.IsDir())
+			}
+		}
+		if expected := []string{"a.txt", "b.txt", "c.txt", "sub"}; !slices.Equal(names, expected) {
+			t.Errorf("wrong entries in '%s': expected %v, got %v", p("dir"), expected, names)
+		}
+	})
+
+	t.Run("sub", func(t *testing.T) {
+		sub, err := fs.Sub(fsys, p("dir"))
+		if err != nil {
+			t.Fatalf("cannot create sub filesystem '%s': %v", p("dir"), err)
+		}
+		checkFile(t, sub, "a.txt", "a")
+		info, err := fs.Stat(sub, "sub")
+		if err != nil {
+			t.Fatalf("cannot stat 'sub' in sub filesystem: %v", err)
+		}
+		if !info.IsDir() {
+			t.Errorf("'sub' in sub filesystem is not a directory")
+		}
+		writeFile(t, sub, "d.txt", "d")
+		reopen(t)
+		checkFile(t, fsys, p("dir/d.txt"), "d")
+	})
+
+	t.Run("mkdirall", func(t *testing.T) {
+		_, ok1 := fsys.(writefs.MkDirFS)
+		_, ok2 := fsys.(writefs.MkDirAllFS)
+		if !ok1 && !ok2 {
+			t.Skip("MkDir not implemented")
+		}
+		if err := writefs.MkDirAll(fsys, p("new/folder")); err != nil {
+			t.Fatalf("cannot create directory '%s': %v", p("new/folder"), err)
+		}
+		writeFile(t, fsys, p("new/folder/file.txt"), "new")
+		reopen(t)
+		checkFile(t, fsys, p("new/folder/file.txt"), "new")
+	})
+
+	t.Run("rename", func(t *testing.T) {
+		if err := writefs.Rename(fsys, p("dir/a.txt"), p("dir/renamed.txt")); err != nil {
+			if errors.Is(err, writefs.ErrNotImplemented) {
+				t.Skip("Rename not implemented")
+			}
+			t.Fatalf("cannot rename '%s': %v", p("dir/a.txt"), err)
+		}
+		if _, err := fs.Stat(fsys, p("dir/a.txt")); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("renamed file: expected fs.ErrNotExist, got %v", err)
+		}
+		checkFile(t, fsys, p("dir/renamed.txt"), "a")
+	})
+
+	t.Run("remove", func(t *testing.T) {
+		if err := writefs.Remove(fsys, p("file.txt")); err != nil {
+			if errors.Is(err, writefs.ErrNotImplemented) {
+				t.Skip("Remove not implemented")
+			}
+			t.Fatalf("cannot remove '%s': %v", p("file.txt"), err)
+		}
+		if _, err := fs.Stat(fsys, p("file.txt")); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("removed file: expected fs.ErrNotExist, got %v", err)
+		}
+		if err := writefs.Remove(fsys, p("file.txt")); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("remove of missing file: expected fs.ErrNotExist, got %v", err)
+		}
+	})
+
+	t.Run("removeall", func(t *testing.T) {
+		_, ok1 := fsys.(writefs.RemoveAllFS)
+		_, ok2 := fsys.(fs.ReadDirFS)
+		if !ok1 && !ok2 {
+			t.Skip("RemoveAll not implemented")
+		}
+		if err := writefs.RemoveAll(fsys, Dir); err != nil {
+			if errors.Is(err, writefs.ErrNotImplemented) {
+				t.Skip("RemoveAll not implemented")
+			}
+			t.Fatalf("cannot remove '%s': %v", Dir, err)
+		}
+		if _, err := fs.Stat(fsys, p("dir/b.txt")); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("removed file: expected fs.ErrNotExist, got %v", err)
+		}
+	})
+}
+
+func writeFile(t *testing.T, fsys fs.FS, name, content string) {
+	t.Helper()
+	fp, err := writefs.Create(fsys, name)
+	if err != nil {
+		t.Fatalf("cannot create '%s': %v", name, err)
+	}
+	if _, err := fp.Write([]byte(content)); err != nil {
+		fp.Close()
+		t.Fatalf("cannot write '%s': %v", name, err)
+	}
+	if err := fp.Close(); err != nil {
+		t.Fatalf("cannot close '%s': %v", name, err)
+	}
+}
+
+func checkFile(t *testing.T, fsys fs.FS, name, content string) {
+	t.Helper()
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		t.Errorf("cannot read '%s': %v", name, err)
+		return
+	}
+	if string(data) != content {
+		t.Errorf("wrong content of '%s': expected '%s', got '%s'", name, content, data)
+	}
+}
@@ -0,0 +1,75 @@
+package writefs
+
+import (
+	"emperror.dev/errors"
+	"io"
+	"io/fs"
+	"os"
+)
+
+// CreateWriterAt creates a file which supports random access writes.
+// If fsys does not implement CreateWriterAtFS, the content is spooled to a local temporary file
+// which is uploaded with Create on Close.
+func CreateWriterAt(fsys fs.FS, path string) (FileWriterAt, error) {
+	if _fsys, ok := fsys.(CreateWriterAtFS); ok {
+		return _fsys.CreateWriterAt(path)
+	}
+	if _, ok := fsys.(CreateFS); !ok {
+		return nil, errors.Wrap(ErrNotImplemented, "CreateWriterAt")
+	}
+	fp, err := os.CreateTemp("", "writefs_spool_*.tmp")
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot create spool file for '%s'", path)
+	}
+	return &spoolFileWrite{
+		File: fp,
+		fsys: fsys,
+		path: path,
+	}, nil
+}
+
+// spoolFileWrite is a local temporary file which is uploaded to path on Close
+type spoolFileWrite struct {
+	*os.File
+	fsys fs.FS
+	path string
+}
+
+func (w *spoolFileWrite) Close() error {
+	defer os.Remove(w.File.Name())
+	if _, err := w.File.Seek(0, io.SeekStart); err != nil {
+		w.File.Close()
+		return errors.Wrapf(err, "cannot rewind spool file for '%s'", w.path)
+	}
+	err := w.upload()
+	return errors.Combine(err, errors.Wrapf(w.File.Close(), "cannot close spool file for '%s'", w.path))
+}
+
+func (w *spoolFileWrite) upload() error {
+	fp, err := Create(w.fsys, w.path)
+	if err != nil {
+		return errors.Wrapf(err, "cannot create '%s'", w.path)
+	}
+	if _, err := io.Copy(fp, w.File); err != nil {
+		if err := Abort(fp); err != nil {
+			fp.Close()
+		}
+		return errors.Wrapf(err, "cannot upload '%s'", w.path)
+	}
+	return errors.Wrapf(fp.Close(), "cannot close '%s'", w.path)
+}
+
+// Abort discards the spool file without uploading it
+func (w *spoolFileWrite) Abort() error {
+	w.File.Close()
+	if err := os.Remove(w.File.Name()); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return errors.Wrapf(err, "cannot remove spool file for '%s'", w.path)
+	}
+	return nil
+}
+
+var (
+	_ FileWriterAt    = &spoolFileWrite{}
+	_ FileWriteSeeker = &spoolFileWrite{}
+	_ AbortFileWrite  = &spoolFileWrite{}
+)
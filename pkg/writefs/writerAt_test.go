@@ -0,0 +1,67 @@
+package writefs_test
+
+import (
+	"errors"
+	"github.com/je4/filesystem/v3/pkg/osfsrw"
+	"github.com/je4/filesystem/v3/pkg/writefs"
+	"github.com/rs/zerolog"
+	"io/fs"
+	"os"
+	"testing"
+)
+
+// createOnlyFS hides all capabilities of the base filesystem except Create
+type createOnlyFS struct {
+	writefs.ReadWriteFS
+}
+
+func TestCreateWriterAt(t *testing.T) {
+	logger := zerolog.New(os.Stderr).With().Timestamp().Logger()
+	osFS, err := osfsrw.NewFS(t.TempDir(), &logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, fsys := range map[string]writefs.ReadWriteFS{
+		"native": osFS,
+		"spool":  createOnlyFS{osFS},
+	} {
+		t.Run(name, func(t *testing.T) {
+			fp, err := writefs.CreateWriterAt(fsys, name+"/patched.bin")
+			if err != nil {
+				t.Fatal(err)
+			}
+			// write the body and patch the header afterwards
+			if _, err := fp.Write([]byte("0000body")); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := fp.WriteAt([]byte("HEAD"), 0); err != nil {
+				t.Fatal(err)
+			}
+			if err := fp.Close(); err != nil {
+				t.Fatal(err)
+			}
+			data, err := fs.ReadFile(osFS, name+"/patched.bin")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != "HEADbody" {
+				t.Fatalf("wrong content '%s'", data)
+			}
+		})
+	}
+	t.Run("abort", func(t *testing.T) {
+		fp, err := writefs.CreateWriterAt(createOnlyFS{osFS}, "aborted.bin")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := fp.WriteAt([]byte("data"), 4); err != nil {
+			t.Fatal(err)
+		}
+		if err := writefs.Abort(fp); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := fs.Stat(osFS, "aborted.bin"); !errors.Is(err, fs.ErrNotExist) {
+			t.Fatalf("aborted file exists: %v", err)
+		}
+	})
+}
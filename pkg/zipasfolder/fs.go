@@ -1,6 +1,7 @@
 package zipasfolder
 
 import (
+	"context"
 	"fmt"
 	"github.com/bluele/gcache"
 	"github.com/je4/filesystem/v3/pkg/writefs"
@@ -11,6 +12,7 @@ import (
 	"path/filepath"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -19,7 +21,8 @@ import (
 func NewFS(baseFS fs.FS, cacheSize int, logger zLogger.ZLogger) (*zipAsFolderFS, error) {
 	_logger := logger.With().Str("class", "zipAsFolderFS").Logger()
 	logger = &_logger
-	f := &zipAsFolderFS{
+	var f *zipAsFolderFS
+	f = &zipAsFolderFS{
 		baseFS: baseFS,
 		zipCache: gcache.New(cacheSize).
 			LRU().
@@ -38,6 +41,7 @@ func NewFS(baseFS fs.FS, cacheSize int, logger zLogger.ZLogger) (*zipAsFolderFS,
 			}).
 			EvictedFunc(func(key, value any) {
 				logger.Debug().Msgf("evict zip file '%s'", key)
+				f.evictions.Add(1)
 				zipFS, ok := value.(fs.FS)
 				if !ok {
 					return
@@ -72,11 +76,18 @@ func NewFS(baseFS fs.FS, cacheSize int, logger zLogger.ZLogger) (*zipAsFolderFS,
 }
 
 type zipAsFolderFS struct {
-	baseFS   fs.FS
-	zipCache gcache.Cache
-	lock     sync.RWMutex
-	end      chan bool
-	logger   zLogger.ZLogger
+	baseFS    fs.FS
+	zipCache  gcache.Cache
+	evictions atomic.Uint64
+	lock      sync.RWMutex
+	end       chan bool
+	logger    zLogger.ZLogger
+}
+
+// CacheStats returns the hits, misses and evictions of the zip file cache.
+// Evictions include the removal of unused zip files.
+func (fsys *zipAsFolderFS) CacheStats() (hits, misses, evictions uint64) {
+	return fsys.zipCache.HitCount(), fsys.zipCache.MissCount(), fsys.evictions.Load()
 }
 
 func (fsys *zipAsFolderFS) Fullpath(name string) (string, error) {
@@ -99,12 +110,122 @@ func (fsys *zipAsFolderFS) Create(path string) (writefs.FileWrite, error) {
 
 // MkDir creates a new folder
 func (fsys *zipAsFolderFS) MkDir(path string) error {
+	return fsys.MkDirContext(context.Background(), path)
+}
+
+func (fsys *zipAsFolderFS) MkDirContext(ctx context.Context, path string) error {
+	path = clearPath(path)
+	zipFile, _, isZIP := expandZipFile(path)
+	if isZIP {
+		return errors.Errorf("cannot create folder '%s' in zip file '%s'", path, zipFile)
+	}
+	return writefs.MkDirContext(ctx, fsys.baseFS, path)
+}
+
+// MkDirAll creates a new folder along with all necessary parents
+func (fsys *zipAsFolderFS) MkDirAll(path string) error {
+	return fsys.MkDirAllContext(context.Background(), path)
+}
+
+func (fsys *zipAsFolderFS) MkDirAllContext(ctx context.Context, path string) error {
 	path = clearPath(path)
 	zipFile, _, isZIP := expandZipFile(path)
 	if isZIP {
 		return errors.Errorf("cannot create folder '%s' in zip file '%s'", path, zipFile)
 	}
-	return writefs.MkDir(fsys.baseFS, path)
+	return writefs.MkDirAllContext(ctx, fsys.baseFS, path)
+}
+
+// Remove removes a file outside of zip files. A zip file is removed as a single file
+func (fsys *zipAsFolderFS) Remove(path string) error {
+	return fsys.RemoveContext(context.Background(), path)
+}
+
+func (fsys *zipAsFolderFS) RemoveContext(ctx context.Context, path string) error {
+	path = clearPath(path)
+	zipFile, zipPath, isZIP := expandZipFile(path)
+	if isZIP && zipPath != "" {
+		return errors.Errorf("cannot remove '%s' in zip file '%s'", path, zipFile)
+	}
+	fsys.lock.Lock()
+	defer fsys.lock.Unlock()
+	fsys.evict(path)
+	return writefs.RemoveContext(ctx, fsys.baseFS, path)
+}
+
+// RemoveAll removes a file or folder outside of zip files. Zip files are removed as single files
+// and not walked as folders
+func (fsys *zipAsFolderFS) RemoveAll(path string) error {
+	return fsys.RemoveAllContext(context.Background(), path)
+}
+
+func (fsys *zipAsFolderFS) RemoveAllContext(ctx context.Context, path string) error {
+	path = clearPath(path)
+	zipFile, zipPath, isZIP := expandZipFile(path)
+	if isZIP && zipPath != "" {
+		return errors.Errorf("cannot remove '%s' in zip file '%s'", path, zipFile)
+	}
+	fsys.lock.Lock()
+	defer fsys.lock.Unlock()
+	fsys.evict(path)
+	if path == "" {
+		path = "."
+	}
+	return writefs.RemoveAllContext(ctx, fsys.baseFS, path)
+}
+
+// Rename renames a file or folder outside of zip files. A zip file is renamed as a single file
+func (fsys *zipAsFolderFS) Rename(oldPath, newPath string) error {
+	return fsys.RenameContext(context.Background(), oldPath, newPath)
+}
+
+func (fsys *zipAsFolderFS) RenameContext(ctx context.Context, oldPath, newPath string) error {
+	oldPath = clearPath(oldPath)
+	newPath = clearPath(newPath)
+	for _, path := range []string{oldPath, newPath} {
+		if zipFile, zipPath, isZIP := expandZipFile(path); isZIP && zipPath != "" {
+			return errors.Errorf("cannot rename '%s' in zip file '%s'", path, zipFile)
+		}
+	}
+	fsys.lock.Lock()
+	defer fsys.lock.Unlock()
+	fsys.evict(oldPath)
+	fsys.evict(newPath)
+	return writefs.RenameContext(ctx, fsys.baseFS, oldPath, newPath)
+}
+
+// evict removes the zip file path and all cached zip files below path from the cache.
+// The caller must hold the write lock
+func (fsys *zipAsFolderFS) evict(path string) {
+	for key := range fsys.zipCache.GetALL(false) {
+		zipFile, ok := key.(string)
+		if !ok {
+			continue
+		}
+		if path == "" || zipFile == path || strings.HasPrefix(zipFile, path+"/") {
+			fsys.zipCache.Remove(key)
+		}
+	}
+}
+
+// Chtimes changes the times of a file outside of zip files
+func (fsys *zipAsFolderFS) Chtimes(name string, atime, mtime time.Time) error {
+	name = clearPath(name)
+	zipFile, _, isZIP := expandZipFile(name)
+	if isZIP {
+		return errors.Errorf("cannot change times of '%s' in zip file '%s'", name, zipFile)
+	}
+	return writefs.Chtimes(fsys.baseFS, name, atime, mtime)
+}
+
+// Chmod changes the mode of a file outside of zip files
+func (fsys *zipAsFolderFS) Chmod(name string, mode fs.FileMode) error {
+	name = clearPath(name)
+	zipFile, _, isZIP := expandZipFile(name)
+	if isZIP {
+		return errors.Errorf("cannot change mode of '%s' in zip file '%s'", name, zipFile)
+	}
+	return writefs.Chmod(fsys.baseFS, name, mode)
 }
 
 // Stat returns the file info for a given path
@@ -150,6 +271,11 @@ func (fsys *zipAsFolderFS) ReadFile(name string) ([]byte, error) {
 
 // ReadDir reads a directory from the filesystem
 func (fsys *zipAsFolderFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	return fsys.ReadDirContext(context.Background(), name)
+}
+
+// ReadDirContext reads a directory. Folders outside of zip files are read with the context aware variant of the base fs
+func (fsys *zipAsFolderFS) ReadDirContext(ctx context.Context, name string) ([]fs.DirEntry, error) {
 	name = strings.TrimPrefix(name, "./")
 	name = strings.Trim(name, "/")
 	zipFile, zipPath, isZIP := expandZipFile(name)
@@ -157,7 +283,7 @@ func (fsys *zipAsFolderFS) ReadDir(name string) ([]fs.DirEntry, error) {
 		if name == "" {
 			name = "."
 		}
-		entries, err := fs.ReadDir(fsys.baseFS, name)
+		entries, err := writefs.ReadDirContext(ctx, fsys.baseFS, name)
 		//file, err := fsys.baseFS.ReadDir(name)
 		if err != nil {
 			return entries, errors.Wrapf(err, "cannot open file '%s'", name)
@@ -176,6 +302,9 @@ func (fsys *zipAsFolderFS) ReadDir(name string) ([]fs.DirEntry, error) {
 		}
 		return result, nil
 	}
+	if err := ctx.Err(); err != nil {
+		return nil, errors.WithStack(err)
+	}
 	fsys.lock.RLock()
 	defer fsys.lock.RUnlock()
 	zipFSCache, err := fsys.zipCache.Get(zipFile)
@@ -219,6 +348,90 @@ func (fsys *zipAsFolderFS) Open(name string) (fs.File, error) {
 	return rc, nil
 }
 
+// CreateAtomic creates a new file which is visible only after a successful Close
+func (fsys *zipAsFolderFS) CreateAtomic(path string) (writefs.FileWrite, error) {
+	path = clearPath(path)
+	zipFile, _, isZIP := expandZipFile(path)
+	if isZIP {
+		return nil, errors.Errorf("cannot create file '%s' in zip file '%s'", path, zipFile)
+	}
+	return writefs.CreateAtomic(fsys.baseFS, path)
+}
+
+// CreateWriterAt creates a file outside of zip files for random access writes
+func (fsys *zipAsFolderFS) CreateWriterAt(path string) (writefs.FileWriterAt, error) {
+	path = clearPath(path)
+	zipFile, _, isZIP := expandZipFile(path)
+	if isZIP {
+		return nil, errors.Errorf("cannot create file '%s' in zip file '%s'", path, zipFile)
+	}
+	return writefs.CreateWriterAt(fsys.baseFS, path)
+}
+
+// OpenFile opens a file outside of zip files with the flags of os.OpenFile
+func (fsys *zipAsFolderFS) OpenFile(path string, flag int, perm fs.FileMode) (writefs.FileWrite, error) {
+	path = clearPath(path)
+	zipFile, _, isZIP := expandZipFile(path)
+	if isZIP {
+		return nil, errors.Errorf("cannot open file '%s' in zip file '%s'", path, zipFile)
+	}
+	return writefs.OpenFile(fsys.baseFS, path, flag, perm)
+}
+
+// OpenFileContext opens a file outside of zip files with the context aware variant of the base fs
+func (fsys *zipAsFolderFS) OpenFileContext(ctx context.Context, path string, flag int, perm fs.FileMode) (writefs.FileWrite, error) {
+	path = clearPath(path)
+	zipFile, _, isZIP := expandZipFile(path)
+	if isZIP {
+		return nil, errors.Errorf("cannot open file '%s' in zip file '%s'", path, zipFile)
+	}
+	return writefs.OpenFileContext(ctx, fsys.baseFS, path, flag, perm)
+}
+
+// CreateContext creates a new file with the context aware variant of the base fs
+func (fsys *zipAsFolderFS) CreateContext(ctx context.Context, path string) (writefs.FileWrite, error) {
+	path = clearPath(path)
+	zipFile, _, isZIP := expandZipFile(path)
+	if isZIP {
+		return nil, errors.Errorf("cannot create file '%s' in zip file '%s'", path, zipFile)
+	}
+	return writefs.CreateContext(ctx, fsys.baseFS, path)
+}
+
+// OpenContext opens a file. Files outside of zip files are opened with the context aware variant of the base fs
+func (fsys *zipAsFolderFS) OpenContext(ctx context.Context, name string) (fs.File, error) {
+	name = strings.TrimPrefix(name, "./")
+	name = strings.Trim(name, "/")
+	if _, _, isZIP := expandZipFile(name); isZIP {
+		if err := ctx.Err(); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return fsys.Open(name)
+	}
+	file, err := writefs.OpenContext(ctx, fsys.baseFS, name)
+	if err != nil {
+		return file, errors.Wrapf(err, "cannot open file '%s'", name)
+	}
+	return file, nil
+}
+
+// StatContext returns the file info. Files outside of zip files are checked with the context aware variant of the base fs
+func (fsys *zipAsFolderFS) StatContext(ctx context.Context, name string) (fs.FileInfo, error) {
+	name = strings.TrimPrefix(name, "./")
+	name = strings.Trim(name, "/")
+	if _, _, isZIP := expandZipFile(name); isZIP {
+		if err := ctx.Err(); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return fsys.Stat(name)
+	}
+	info, err := writefs.StatContext(ctx, fsys.baseFS, name)
+	if err != nil {
+		return info, errors.Wrapf(err, "cannot stat file '%s'", name)
+	}
+	return info, nil
+}
+
 // Close closes the filesystem and underlying fs if possible
 func (fsys *zipAsFolderFS) Close() error {
 	fsys.lock.Lock()
@@ -266,11 +479,31 @@ func expandZipFile(name string) (zipFile string, zipPath string, isZip bool) {
 }
 
 var (
-	_ writefs.ReadWriteFS = (*zipAsFolderFS)(nil)
-	_ writefs.MkDirFS     = (*zipAsFolderFS)(nil)
-	_ writefs.CloseFS     = (*zipAsFolderFS)(nil)
-	_ writefs.FullpathFS  = (*zipAsFolderFS)(nil)
-	_ fs.ReadDirFS        = (*zipAsFolderFS)(nil)
-	_ fs.ReadFileFS       = (*zipAsFolderFS)(nil)
-	_ fmt.Stringer        = (*zipAsFolderFS)(nil)
+	_ writefs.ReadWriteFS      = (*zipAsFolderFS)(nil)
+	_ writefs.MkDirFS          = (*zipAsFolderFS)(nil)
+	_ writefs.MkDirAllFS       = (*zipAsFolderFS)(nil)
+	_ writefs.RemoveFS         = (*zipAsFolderFS)(nil)
+	_ writefs.RemoveAllFS      = (*zipAsFolderFS)(nil)
+	_ writefs.RenameFS         = (*zipAsFolderFS)(nil)
+	_ writefs.ChtimesFS        = (*zipAsFolderFS)(nil)
+	_ writefs.ChmodFS          = (*zipAsFolderFS)(nil)
+	_ writefs.CreateAtomicFS   = (*zipAsFolderFS)(nil)
+	_ writefs.OpenFileFS       = (*zipAsFolderFS)(nil)
+	_ writefs.CreateWriterAtFS = (*zipAsFolderFS)(nil)
+	_ writefs.CloseFS          = (*zipAsFolderFS)(nil)
+	_ writefs.FullpathFS       = (*zipAsFolderFS)(nil)
+	_ fs.ReadDirFS             = (*zipAsFolderFS)(nil)
+	_ fs.ReadFileFS            = (*zipAsFolderFS)(nil)
+	_ fmt.Stringer             = (*zipAsFolderFS)(nil)
+
+	_ writefs.CreateContextFS    = (*zipAsFolderFS)(nil)
+	_ writefs.OpenFileContextFS  = (*zipAsFolderFS)(nil)
+	_ writefs.OpenContextFS      = (*zipAsFolderFS)(nil)
+	_ writefs.StatContextFS      = (*zipAsFolderFS)(nil)
+	_ writefs.ReadDirContextFS   = (*zipAsFolderFS)(nil)
+	_ writefs.RemoveContextFS    = (*zipAsFolderFS)(nil)
+	_ writefs.RemoveAllContextFS = (*zipAsFolderFS)(nil)
+	_ writefs.RenameContextFS    = (*zipAsFolderFS)(nil)
+	_ writefs.MkDirContextFS     = (*zipAsFolderFS)(nil)
+	_ writefs.MkDirAllContextFS  = (*zipAsFolderFS)(nil)
 )
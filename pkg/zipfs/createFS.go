@@ -14,6 +14,7 @@ func NewCreateFSFunc(logger zLogger.ZLogger) writefs.CreateFSFunc {
 		if len(parts) < 2 {
 			return nil, errors.Errorf("invalid zip path: %s", zipFile)
 		}
+		// the factory releases baseFS together with the zip filesystem
 		baseFS, err := f.Get(strings.Join(parts[:len(parts)-1], "/"))
 		if err != nil {
 			return nil, errors.Wrapf(err, "cannot get base filesystem for '%s'", zipFile)
@@ -59,7 +59,17 @@ func (zfs *zipFS) Sub(dir string) (fs.FS, error) {
 
 */
 
+// Stat returns the file info of name. Symbolic links are followed.
 func (zfs *zipFS) Stat(name string) (fs.FileInfo, error) {
+	name, err := zfs.resolve(clearPath(name))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return zfs.Lstat(name)
+}
+
+// Lstat returns the file info of name without following symbolic links
+func (zfs *zipFS) Lstat(name string) (fs.FileInfo, error) {
 	name = clearPath(name)
 	for _, f := range zfs.File {
 		if strings.HasPrefix(f.Name, name) && len(f.Name) != len(name) {
@@ -73,7 +83,10 @@ func (zfs *zipFS) Stat(name string) (fs.FileInfo, error) {
 }
 
 func (zfs *zipFS) ReadFile(name string) ([]byte, error) {
-	name = clearPath(name)
+	name, err := zfs.resolve(clearPath(name))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
 	for _, f := range zfs.File {
 		if f.Name == name {
 			rc, err := f.Open()
@@ -112,7 +125,10 @@ func (zfs *zipFS) ReadDir(name string) ([]fs.DirEntry, error) {
 }
 
 func (zfs *zipFS) Open(name string) (fs.File, error) {
-	name = clearPath(name)
+	name, err := zfs.resolve(clearPath(name))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
 	for _, f := range zfs.File {
 		if f.Name == name {
 			w, err := f.Open()
@@ -151,6 +167,8 @@ var (
 	_ fs.StatFS          = (*zipFS)(nil)
 	_ fs.SubFS           = (*zipFS)(nil)
 	_ writefs.IsLockedFS = (*zipFS)(nil)
+	_ writefs.ReadLinkFS = (*zipFS)(nil)
+	_ writefs.LstatFS    = (*zipFS)(nil)
 	_ OpenRawZipFS       = (*zipFS)(nil)
 	_ fmt.Stringer       = (*zipFS)(nil)
 )
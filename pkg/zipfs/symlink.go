@@ -0,0 +1,93 @@
+package zipfs
+
+import (
+	"archive/zip"
+	"emperror.dev/errors"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+)
+
+// maxSymlinks limits the number of links followed while resolving a path
+const maxSymlinks = 255
+
+// ReadLink returns the destination of the link name. The destination is the content of the zip entry.
+func (zfs *zipFS) ReadLink(name string) (string, error) {
+	name = clearPath(name)
+	f := zfs.findFile(name)
+	if f == nil {
+		return "", errors.Wrapf(fs.ErrNotExist, "'%s' not found", name)
+	}
+	if f.Mode()&fs.ModeSymlink == 0 {
+		return "", errors.Wrapf(fs.ErrInvalid, "'%s' is not a link", name)
+	}
+	return readLink(f)
+}
+
+// resolve follows the links in every component of name. Links must not point outside of the zip file.
+func (zfs *zipFS) resolve(name string) (string, error) {
+	if name == "" {
+		return name, nil
+	}
+	resolved := ""
+	rest := strings.Split(name, "/")
+	hops := 0
+	for len(rest) > 0 {
+		elem := rest[0]
+		rest = rest[1:]
+		switch elem {
+		case "", ".":
+			continue
+		case "..":
+			if resolved == "" {
+				return "", errors.Wrapf(fs.ErrNotExist, "'%s' points outside of the zip file", name)
+			}
+			if resolved = path.Dir(resolved); resolved == "." {
+				resolved = ""
+			}
+			continue
+		}
+		current := path.Join(resolved, elem)
+		f := zfs.findFile(current)
+		if f == nil || f.Mode()&fs.ModeSymlink == 0 {
+			resolved = current
+			continue
+		}
+		if hops++; hops > maxSymlinks {
+			return "", errors.Errorf("too many links while resolving '%s'", name)
+		}
+		dest, err := readLink(f)
+		if err != nil {
+			return "", errors.WithStack(err)
+		}
+		if path.IsAbs(dest) {
+			return "", errors.Wrapf(fs.ErrNotExist, "link '%s' to '%s' points outside of the zip file", current, dest)
+		}
+		// the destination is relative to the directory of the link
+		rest = append(strings.Split(dest, "/"), rest...)
+	}
+	return resolved, nil
+}
+
+func (zfs *zipFS) findFile(name string) *zip.File {
+	for _, f := range zfs.File {
+		if f.Name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+func readLink(f *zip.File) (string, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return "", errors.Wrapf(err, "cannot open link '%s'", f.Name)
+	}
+	defer rc.Close()
+	dest, err := io.ReadAll(rc)
+	if err != nil {
+		return "", errors.Wrapf(err, "cannot read link '%s'", f.Name)
+	}
+	return string(dest), nil
+}
@@ -0,0 +1,93 @@
+package zipfs
+
+import (
+	"archive/zip"
+	"bytes"
+	"emperror.dev/errors"
+	"github.com/rs/zerolog"
+	"io/fs"
+	"os"
+	"testing"
+)
+
+func TestSymlink(t *testing.T) {
+	buf := &bytes.Buffer{}
+	zw := zip.NewWriter(buf)
+	for _, entry := range []struct {
+		name, content string
+		mode          fs.FileMode
+	}{
+		{"data/file.txt", "content", 0644},
+		{"data/link.txt", "file.txt", fs.ModeSymlink | 0777},
+		{"link/link.txt", "../data/link.txt", fs.ModeSymlink | 0777},
+		{"outside.txt", "../file.txt", fs.ModeSymlink | 0777},
+		{"dir", "data", fs.ModeSymlink | 0777},
+		{"loop/a", "b", fs.ModeSymlink | 0777},
+		{"loop/b", "a", fs.ModeSymlink | 0777},
+	} {
+		header := &zip.FileHeader{Name: entry.name, Method: zip.Deflate}
+		header.SetMode(entry.mode)
+		w, err := zw.CreateHeader(header)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(entry.content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	logger := zerolog.New(os.Stderr).With().Timestamp().Logger()
+	zipFS, err := NewFS(bytes.NewReader(buf.Bytes()), int64(buf.Len()), "symlink.zip", &logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := zipFS.Lstat("link/link.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode()&fs.ModeSymlink == 0 {
+		t.Fatalf("'link/link.txt' is not a link: %v", info.Mode())
+	}
+	dest, err := zipFS.ReadLink("link/link.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dest != "../data/link.txt" {
+		t.Fatalf("wrong destination '%s'", dest)
+	}
+	// links are followed by Stat and Open
+	info, err = zipFS.Stat("link/link.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.Mode().IsRegular() {
+		t.Fatalf("'link/link.txt' not resolved: %v", info.Mode())
+	}
+	data, err := fs.ReadFile(zipFS, "link/link.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "content" {
+		t.Fatalf("wrong content '%s'", data)
+	}
+	// links in parent directories are followed
+	data, err = fs.ReadFile(zipFS, "dir/link.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "content" {
+		t.Fatalf("wrong content '%s'", data)
+	}
+	if _, err := zipFS.Open("loop/a"); err == nil {
+		t.Fatal("no error for link loop")
+	}
+	if _, err := zipFS.Open("outside.txt"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("expected fs.ErrNotExist, got %v", err)
+	}
+	if _, err := zipFS.ReadLink("data/file.txt"); !errors.Is(err, fs.ErrInvalid) {
+		t.Fatalf("expected fs.ErrInvalid, got %v", err)
+	}
+}
@@ -0,0 +1,82 @@
+package zipfsrw
+
+import (
+	"archive/zip"
+	"emperror.dev/errors"
+	"encoding/binary"
+	"io/fs"
+	"time"
+)
+
+// extended timestamp extra field, which is preferred by archive/zip and Info-ZIP
+const extTimeExtraID = 0x5455
+
+// Chtimes sets the modification time of name. atime is not stored in zip files.
+// Files created in this session must be changed before the first write, because the local file header
+// contains the modification time. Files of the original zip are updated while they are copied on Close.
+func (zfsrw *zipFSRW) Chtimes(name string, atime, mtime time.Time) error {
+	name = clearPath(name)
+	if header, ok := zfsrw.headers[name]; ok {
+		if !zfsrw.pending[name] {
+			return errors.Wrapf(fs.ErrPermission, "cannot change modification time of '%s' after writing", name)
+		}
+		setModTime(header, mtime)
+		return nil
+	}
+	if err := zfsrw.existsInReader(name); err != nil {
+		return errors.WithStack(err)
+	}
+	zfsrw.modTimes[name] = mtime
+	return nil
+}
+
+// Chmod sets the mode of name in the external attributes of the zip entry
+func (zfsrw *zipFSRW) Chmod(name string, mode fs.FileMode) error {
+	name = clearPath(name)
+	if header, ok := zfsrw.headers[name]; ok {
+		header.SetMode(mode)
+		return nil
+	}
+	if err := zfsrw.existsInReader(name); err != nil {
+		return errors.WithStack(err)
+	}
+	zfsrw.modes[name] = mode
+	return nil
+}
+
+func (zfsrw *zipFSRW) existsInReader(name string) error {
+	if zfsrw.zipReader == nil {
+		return errors.Wrapf(fs.ErrNotExist, "'%s' not found", name)
+	}
+	if _, err := fs.Stat(zfsrw.zipReader, name); err != nil {
+		return errors.Wrapf(err, "cannot stat '%s'", name)
+	}
+	return nil
+}
+
+// setModTime sets the modification time of header and replaces the extended timestamp
+func setModTime(header *zip.FileHeader, mtime time.Time) {
+	header.Modified = mtime
+	header.ModifiedDate = uint16(mtime.Day() + int(mtime.Month())<<5 + (mtime.Year()-1980)<<9)
+	header.ModifiedTime = uint16(mtime.Second()/2 + mtime.Minute()<<5 + mtime.Hour()<<11)
+
+	// replace extended timestamp
+	var extra []byte
+	for data := header.Extra; len(data) >= 4; {
+		tag := binary.LittleEndian.Uint16(data[0:2])
+		size := int(binary.LittleEndian.Uint16(data[2:4]))
+		if len(data) < 4+size {
+			break
+		}
+		if tag != extTimeExtraID {
+			extra = append(extra, data[:4+size]...)
+		}
+		data = data[4+size:]
+	}
+	var buf [9]byte
+	binary.LittleEndian.PutUint16(buf[0:2], extTimeExtraID)
+	binary.LittleEndian.PutUint16(buf[2:4], 5)
+	buf[4] = 1 // flags: ModTime
+	binary.LittleEndian.PutUint32(buf[5:9], uint32(mtime.Unix()))
+	header.Extra = append(extra, buf[:]...)
+}
@@ -15,6 +15,7 @@ func NewCreateFSFunc(noCompression bool, logger zLogger.ZLogger) writefs.CreateF
 		if len(parts) < 2 {
 			return nil, errors.Errorf("invalid zip path: %s", zipFile)
 		}
+		// the factory releases baseFS together with the zip filesystem
 		baseFS, err := f.Get(strings.Join(parts[:len(parts)-1], "/"))
 		if err != nil {
 			return nil, errors.Wrapf(err, "cannot get base filesystem for '%s'", zipFile)
@@ -33,6 +34,7 @@ func NewCreateFSChecksumFunc(noCompression bool, algs []checksum.DigestAlgorithm
 		if len(parts) < 2 {
 			return nil, errors.Errorf("invalid zip path: %s", zipFile)
 		}
+		// the factory releases baseFS together with the zip filesystem
 		baseFS, err := f.Get(strings.Join(parts[:len(parts)-1], "/"))
 		if err != nil {
 			return nil, errors.Wrapf(err, "cannot get base filesystem for '%s'", zipFile)
@@ -51,6 +53,7 @@ func NewCreateFSEncryptedChecksumFunc(noCompression bool, algs []checksum.Digest
 		if len(parts) < 2 {
 			return nil, errors.Errorf("invalid zip path: %s", zipFile)
 		}
+		// the factory releases baseFS together with the zip filesystem
 		baseFS, err := f.Get(strings.Join(parts[:len(parts)-1], "/"))
 		if err != nil {
 			return nil, errors.Wrapf(err, "cannot get base filesystem for '%s'", zipFile)
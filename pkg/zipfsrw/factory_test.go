@@ -4,7 +4,9 @@ import (
 	"github.com/je4/filesystem/v3/pkg/osfsrw"
 	"github.com/je4/filesystem/v3/pkg/writefs"
 	"os"
+	"path"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -17,17 +19,22 @@ func TestZipFSRWFactory(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	if err := factory.Register(osfsrw.NewCreateFSFunc(), "^file://", writefs.MediumFS); err != nil {
+	if err := factory.RegisterCached(osfsrw.NewCreateFSFunc(testLogger), "^file://", writefs.MediumFS); err != nil {
 		t.Fatal(err)
 	}
-	if err := factory.Register(NewCreateFSFunc(false), "\\.zip$", writefs.HighFS); err != nil {
+	if err := factory.Register(NewCreateFSFunc(false, testLogger), "\\.zip$", writefs.HighFS); err != nil {
 		t.Fatal(err)
 	}
 
 	t.Cleanup(func() {
+		if err := factory.Close(); err != nil {
+			t.Error(err)
+		}
+		os.Remove(strings.TrimPrefix(testTmpFile, "file://"))
 	})
 
 	t.Run("create", testZipFSRWFactory_create)
+	t.Run("cache", testZipFSRWFactory_cache)
 }
 
 func testZipFSRWFactory_create(t *testing.T) {
@@ -38,4 +45,43 @@ func testZipFSRWFactory_create(t *testing.T) {
 	if fs == nil {
 		t.Fatal("fs is nil")
 	}
+	if err := factory.Release(testTmpFile); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testZipFSRWFactory_cache(t *testing.T) {
+	fs1, err := factory.Get(testTmpFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// same path, not normalized
+	dir, file := path.Split(testTmpFile)
+	fs2, err := factory.Get(dir + "./" + file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fs1 == fs2 {
+		t.Error("zip writer shared")
+	}
+	baseFS1, err := factory.Get(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	baseFS2, err := factory.Get(dir + ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if baseFS1 != baseFS2 {
+		t.Error("base filesystem not cached")
+	}
+	for _, p := range []string{testTmpFile, testTmpFile, dir, dir} {
+		if err := factory.Release(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// the zip writers have released their references to the base filesystem
+	if err := factory.Release(dir); err == nil {
+		t.Error("base filesystem not released")
+	}
 }
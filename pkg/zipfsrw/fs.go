@@ -11,6 +11,7 @@ import (
 	"golang.org/x/exp/slices"
 	"io"
 	"io/fs"
+	"time"
 )
 
 func NewFS(writer io.Writer, zipFS zipfs.OpenRawZipFS, noCompression bool, name string, logger zLogger.ZLogger) (*zipFSRW, error) {
@@ -19,6 +20,10 @@ func NewFS(writer io.Writer, zipFS zipfs.OpenRawZipFS, noCompression bool, name
 		zipReader:     zipFS,
 		zipWriter:     zipWriter,
 		newFiles:      []string{},
+		headers:       map[string]*zip.FileHeader{},
+		pending:       map[string]bool{},
+		modTimes:      map[string]time.Time{},
+		modes:         map[string]fs.FileMode{},
 		noCompression: noCompression,
 		name:          name,
 		logger:        logger,
@@ -26,9 +31,14 @@ func NewFS(writer io.Writer, zipFS zipfs.OpenRawZipFS, noCompression bool, name
 }
 
 type zipFSRW struct {
-	zipReader     zipfs.OpenRawZipFS
-	zipWriter     *zip.Writer
-	newFiles      []string
+	zipReader zipfs.OpenRawZipFS
+	zipWriter *zip.Writer
+	newFiles  []string
+	headers   map[string]*zip.FileHeader
+	// pending contains the new files, whose header has not been written yet
+	pending       map[string]bool
+	modTimes      map[string]time.Time
+	modes         map[string]fs.FileMode
 	noCompression bool
 	name          string
 	logger        zLogger.ZLogger
@@ -46,7 +56,7 @@ func (zfsrw *zipFSRW) String() string {
 }
 
 func (zfsrw *zipFSRW) HasChanged() bool {
-	return len(zfsrw.newFiles) > 0
+	return len(zfsrw.newFiles) > 0 || len(zfsrw.modTimes) > 0 || len(zfsrw.modes) > 0
 }
 
 func (zfsrw *zipFSRW) Close() error {
@@ -62,7 +72,14 @@ func (zfsrw *zipFSRW) Close() error {
 					errs = append(errs, err)
 					break
 				}
-				w, err := zfsrw.zipWriter.CreateRaw(&f.FileHeader)
+				header := f.FileHeader
+				if mtime, ok := zfsrw.modTimes[f.Name]; ok {
+					setModTime(&header, mtime)
+				}
+				if mode, ok := zfsrw.modes[f.Name]; ok {
+					header.SetMode(mode)
+				}
+				w, err := zfsrw.zipWriter.CreateRaw(&header)
 				if err != nil {
 					errs = append(errs, err)
 					break
@@ -106,12 +123,42 @@ func (zfsrw *zipFSRW) Create(path string) (writefs.FileWrite, error) {
 	} else {
 		header.Method = zip.Deflate
 	}
-	fp, err := zfsrw.zipWriter.CreateHeader(header)
+	zfsrw.newFiles = append(zfsrw.newFiles, path)
+	zfsrw.headers[path] = header
+	zfsrw.pending[path] = true
+	return &entryWriter{zfsrw: zfsrw, header: header}, nil
+}
+
+// entryWriter writes the header of the zip entry on the first Write or on Close,
+// so that Chtimes can set the modification time of the local file header before
+type entryWriter struct {
+	zfsrw  *zipFSRW
+	header *zip.FileHeader
+	w      io.Writer
+}
+
+func (ew *entryWriter) start() error {
+	if ew.w != nil {
+		return nil
+	}
+	w, err := ew.zfsrw.zipWriter.CreateHeader(ew.header)
 	if err != nil {
-		return nil, errors.Wrapf(err, "cannot create file '%s'", path)
+		return errors.Wrapf(err, "cannot create file '%s'", ew.header.Name)
 	}
-	zfsrw.newFiles = append(zfsrw.newFiles, path)
-	return writefs.NewNopWriteCloser(fp), nil
+	delete(ew.zfsrw.pending, ew.header.Name)
+	ew.w = w
+	return nil
+}
+
+func (ew *entryWriter) Write(p []byte) (int, error) {
+	if err := ew.start(); err != nil {
+		return 0, errors.WithStack(err)
+	}
+	return ew.w.Write(p)
+}
+
+func (ew *entryWriter) Close() error {
+	return errors.WithStack(ew.start())
 }
 
 func (zfsrw *zipFSRW) ReadDir(name string) ([]fs.DirEntry, error) {
@@ -136,5 +183,7 @@ var (
 	_ fs.StatFS           = &fsFile{}
 	_ writefs.ReadWriteFS = &fsFile{}
 	_ writefs.CloseFS     = &fsFile{}
+	_ writefs.ChtimesFS   = &fsFile{}
+	_ writefs.ChmodFS     = &fsFile{}
 	_ fmt.Stringer        = &fsFile{}
 )
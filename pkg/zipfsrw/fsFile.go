@@ -14,12 +14,10 @@ import (
 // NewZipFSRW creates a new ReadWriteFS
 // If the file does not exist, it will be created on the first write operation.
 // If the file exists, it will be opened and read.
-// Changes will be written to an additional file and then renamed to the original file.
+// Changes will be written with writefs.CreateAtomic and replace the original file on Close.
 // additional writers will added via io.MultiWriter
 // additional writers will not be closed
 func NewFSFile(baseFS fs.FS, path string, noCompression bool, logger zLogger.ZLogger, writers ...io.Writer) (*fsFile, error) {
-	writerPath := path
-
 	var zipFS zipfs.OpenRawZipFS
 
 	if xfs, err := zipfs.NewFSFile(baseFS, path, logger); err != nil {
@@ -28,13 +26,12 @@ func NewFSFile(baseFS fs.FS, path string, noCompression bool, logger zLogger.ZLo
 		}
 	} else {
 		zipFS = xfs
-		writerPath = path + ".tmp"
 	}
 
 	// create new file
-	zipFP, err := writefs.Create(baseFS, writerPath)
+	zipFP, err := writefs.CreateAtomic(baseFS, path)
 	if err != nil {
-		return nil, errors.Wrapf(err, "cannot create zip file '%s'", writerPath)
+		return nil, errors.Wrapf(err, "cannot create zip file '%s'", path)
 	}
 	// add a buffer to the file
 	zipFPBuffer := bufio.NewWriterSize(zipFP, 1024*1024)
@@ -54,7 +51,6 @@ func NewFSFile(baseFS fs.FS, path string, noCompression bool, logger zLogger.ZLo
 	return &fsFile{
 		zipFSRW:     zipFSRWBase,
 		path:        path,
-		writerPath:  writerPath,
 		baseFS:      baseFS,
 		zipFP:       zipFP,
 		zipFPBuffer: zipFPBuffer,
@@ -69,7 +65,6 @@ type fsFile struct {
 	zipFP       writefs.FileWrite
 	zipFPBuffer *bufio.Writer
 	path        string
-	writerPath  string
 	zipFS       zipfs.OpenRawZipFS
 }
 
@@ -86,23 +81,23 @@ func (zfsrw *fsFile) Close() error {
 	if err := zfsrw.zipFPBuffer.Flush(); err != nil {
 		errs = append(errs, errors.WithStack(err))
 	}
-	if err := zfsrw.zipFP.Close(); err != nil {
-		errs = append(errs, errors.WithStack(err))
-	}
-
+	// close the original zip before it gets replaced
 	if zfsrw.zipFS != nil {
 		if err := writefs.Close(zfsrw.zipFS); err != nil {
 			errs = append(errs, errors.WithStack(err))
 		}
 	}
-
-	if zfsrw.HasChanged() && zfsrw.path != zfsrw.writerPath {
-		if err := writefs.Remove(zfsrw.baseFS, zfsrw.path); err != nil {
-			errs = append(errs, errors.WithStack(err))
-		}
-		if err := writefs.Rename(zfsrw.baseFS, zfsrw.writerPath, zfsrw.path); err != nil {
-			errs = append(errs, errors.WithStack(err))
+	// keep the original zip if nothing has changed or writing failed
+	if len(errs) > 0 || (zfsrw.zipFS != nil && !zfsrw.HasChanged()) {
+		if err := writefs.Abort(zfsrw.zipFP); err != nil {
+			if !errors.Is(err, writefs.ErrNotImplemented) {
+				errs = append(errs, errors.WithStack(err))
+			} else if err := zfsrw.zipFP.Close(); err != nil {
+				errs = append(errs, errors.WithStack(err))
+			}
 		}
+	} else if err := zfsrw.zipFP.Close(); err != nil {
+		errs = append(errs, errors.WithStack(err))
 	}
 
 	if len(errs) > 0 {
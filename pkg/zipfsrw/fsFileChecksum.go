@@ -3,12 +3,12 @@ package zipfsrw
 import (
 	"emperror.dev/errors"
 	"fmt"
+	"github.com/je4/filesystem/v3/pkg/checksumfs"
 	"github.com/je4/filesystem/v3/pkg/writefs"
 	"github.com/je4/utils/v2/pkg/checksum"
 	"github.com/je4/utils/v2/pkg/zLogger"
 	"io"
 	"io/fs"
-	"strings"
 )
 
 // NewZipFSRW creates a new ReadWriteFS
@@ -67,8 +67,8 @@ func (zfsrw *fsFileChecksums) Close() error {
 		}
 		if len(errs) == 0 {
 			for alg, cs := range checksums {
-				sideCar := fmt.Sprintf("%s.%s", zfsrw.path, strings.ToLower(string(alg)))
-				if _, err := writefs.WriteFile(zfsrw.baseFS, sideCar, []byte(fmt.Sprintf("%s *%s", cs, zfsrw.path))); err != nil {
+				sideCar := checksumfs.SidecarName(zfsrw.path, alg)
+				if _, err := writefs.WriteFile(zfsrw.baseFS, sideCar, checksumfs.SidecarContent(zfsrw.path, cs)); err != nil {
 					errs = append(errs, errors.Wrapf(err, "cannot write sidecar file '%s'", sideCar))
 				}
 			}
@@ -8,13 +8,13 @@ import (
 	"fmt"
 	"github.com/google/tink/go/core/registry"
 	"github.com/google/tink/go/keyset"
+	"github.com/je4/filesystem/v3/pkg/checksumfs"
 	"github.com/je4/filesystem/v3/pkg/writefs"
 	"github.com/je4/utils/v2/pkg/checksum"
 	"github.com/je4/utils/v2/pkg/encrypt"
 	"github.com/je4/utils/v2/pkg/zLogger"
 	"io"
 	"io/fs"
-	"strings"
 )
 
 // NewFSFileEncryptedChecksums creates a new ReadWriteFS
@@ -125,12 +125,12 @@ func (zfsrw *fsFileEncryptedChecksums) Close() error {
 		}
 		if len(errs) == 0 {
 			for alg, cs := range checksums {
-				sideCar := fmt.Sprintf("%s.aes.%s", zfsrw.path, strings.ToLower(string(alg)))
+				sideCar := checksumfs.SidecarName(zfsrw.path+".aes", alg)
 				wfp, err := writefs.Create(zfsrw.baseFS, sideCar)
 				if err != nil {
 					errs = append(errs, errors.Wrapf(err, "cannot create sidecar file '%s'", sideCar))
 				}
-				if _, err := wfp.Write([]byte(fmt.Sprintf("%s *%s.aes", cs, zfsrw.path))); err != nil {
+				if _, err := wfp.Write(checksumfs.SidecarContent(zfsrw.path+".aes", cs)); err != nil {
 					errs = append(errs, errors.Wrapf(err, "cannot write to sidecar file '%s'", sideCar))
 				}
 				if err := wfp.Close(); err != nil {
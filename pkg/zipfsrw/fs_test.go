@@ -1,12 +1,16 @@
 package zipfsrw
 
 import (
+	"bytes"
 	"crypto/rand"
 	"crypto/sha512"
+	"emperror.dev/errors"
+	"encoding/binary"
 	"encoding/hex"
 	"fmt"
 	"github.com/je4/filesystem/v3/pkg/osfsrw"
 	"github.com/je4/filesystem/v3/pkg/writefs"
+	"github.com/je4/filesystem/v3/pkg/writefs/writefstest"
 	"github.com/je4/filesystem/v3/pkg/zipfs"
 	"github.com/je4/utils/v2/pkg/checksum"
 	"github.com/rs/zerolog"
@@ -27,12 +31,13 @@ func tempFileName(prefix, suffix string) string {
 
 var baseFS fs.FS       // base file system
 var zipFileName string // path of the zip file
+var testLogger *zerolog.Logger
 
 func TestMain(m *testing.M) {
 	var err error
 	_logger := zerolog.New(os.Stderr).With().Timestamp().Logger()
-	logger := &_logger
-	baseFS, err = osfsrw.NewFS(os.TempDir(), logger)
+	testLogger = &_logger
+	baseFS, err = osfsrw.NewFS(os.TempDir(), testLogger)
 	if err != nil {
 		fmt.Println(err)
 		os.Exit(1)
@@ -55,6 +60,7 @@ func TestZipFSRW(t *testing.T) {
 	t.Run("read", testZipFSRW_Read)
 	t.Run("update", testZipFSRW_Update)
 	t.Run("checksum", testZipFSRW_Checksum)
+	t.Run("attributes", testZipFSRW_Attributes)
 }
 
 func testZipFSRW_Checksum(t *testing.T) {
@@ -92,7 +98,7 @@ func testZipFSRW_Create(t *testing.T) {
 	// create a new zip file
 
 	// create a new zip file system
-	zipFS, err := NewFSFileChecksums(baseFS, zipFileName, false, []checksum.DigestAlgorithm{checksum.DigestSHA512})
+	zipFS, err := NewFSFileChecksums(baseFS, zipFileName, false, []checksum.DigestAlgorithm{checksum.DigestSHA512}, testLogger)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -160,7 +166,7 @@ func testZipFSRW_Update(t *testing.T) {
 	// create a new zip file
 
 	// create a new zip file system
-	zipFS, err := NewFSFileChecksums(baseFS, zipFileName, false, []checksum.DigestAlgorithm{checksum.DigestSHA512})
+	zipFS, err := NewFSFileChecksums(baseFS, zipFileName, false, []checksum.DigestAlgorithm{checksum.DigestSHA512}, testLogger)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -191,7 +197,7 @@ func testZipFSRW_Update(t *testing.T) {
 
 func testZipFSRW_ReadUpdate(t *testing.T) {
 	// open the zip file system again
-	zipFS, err := NewFSFile(baseFS, zipFileName, false)
+	zipFS, err := NewFSFile(baseFS, zipFileName, false, testLogger)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -238,3 +244,106 @@ func testZipFSRW_ReadUpdate(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func testZipFSRW_Attributes(t *testing.T) {
+	mtime := time.Date(2001, 2, 3, 4, 5, 6, 0, time.UTC)
+	zipFS, err := NewFSFileChecksums(baseFS, zipFileName, false, []checksum.DigestAlgorithm{checksum.DigestSHA512}, testLogger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// new file, the modification time is set before writing
+	fp, err := writefs.Create(zipFS, "attr/content.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// file of the original zip
+	for _, fname := range []string{"attr/content.txt", "a/content.txt"} {
+		if err := writefs.Chtimes(zipFS, fname, mtime, mtime); err != nil {
+			t.Fatalf("cannot set modtime of '%s': %v", fname, err)
+		}
+		if err := writefs.Chmod(zipFS, fname, 0640); err != nil {
+			t.Fatalf("cannot set mode of '%s': %v", fname, err)
+		}
+	}
+	if _, err := fp.Write([]byte("attr")); err != nil {
+		t.Fatal(err)
+	}
+	if err := fp.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := writefs.Chtimes(zipFS, "attr/content.txt", mtime, mtime); !errors.Is(err, fs.ErrPermission) {
+		t.Fatalf("expected fs.ErrPermission after writing, got %v", err)
+	}
+	if err := writefs.Chtimes(zipFS, "notfound.txt", mtime, mtime); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("expected fs.ErrNotExist, got %v", err)
+	}
+	if err := writefs.Close(zipFS); err != nil {
+		t.Fatal(err)
+	}
+
+	zipReader, err := zipfs.NewFSFile(baseFS, zipFileName, testLogger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer zipReader.Close()
+	for _, fname := range []string{"attr/content.txt", "a/content.txt"} {
+		info, err := fs.Stat(zipReader, fname)
+		if err != nil {
+			t.Fatalf("cannot stat '%s': %v", fname, err)
+		}
+		if !info.ModTime().Equal(mtime) {
+			t.Errorf("wrong modtime of '%s': %v", fname, info.ModTime())
+		}
+		if info.Mode().Perm() != 0640 {
+			t.Errorf("wrong mode of '%s': %v", fname, info.Mode())
+		}
+	}
+
+	// the local file header contains the same time as the central directory
+	data, err := fs.ReadFile(baseFS, zipFileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	localHeader := []byte("PK\x03\x04")
+	pos := bytes.Index(data, []byte("attr/content.txt"))
+	for pos >= 30 && !bytes.Equal(data[pos-30:pos-26], localHeader) {
+		next := bytes.Index(data[pos+1:], []byte("attr/content.txt"))
+		if next < 0 {
+			pos = -1
+			break
+		}
+		pos += next + 1
+	}
+	if pos < 30 {
+		t.Fatal("local file header of 'attr/content.txt' not found")
+	}
+	dosTime := binary.LittleEndian.Uint16(data[pos-30+10:])
+	dosDate := binary.LittleEndian.Uint16(data[pos-30+12:])
+	if dosDate != uint16(mtime.Day()+int(mtime.Month())<<5+(mtime.Year()-1980)<<9) ||
+		dosTime != uint16(mtime.Second()/2+mtime.Minute()<<5+mtime.Hour()<<11) {
+		t.Errorf("wrong modtime in local file header of 'attr/content.txt': %#x %#x", dosDate, dosTime)
+	}
+}
+
+func TestZipFSRWConformance(t *testing.T) {
+	dirFS, err := osfsrw.NewFS(t.TempDir(), testLogger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zipFS, err := NewFSFile(dirFS, "conformance.zip", false, testLogger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// new files are readable after the zip file has been written
+	reopen := func(fsys writefs.ReadWriteFS) (writefs.ReadWriteFS, error) {
+		if err := writefs.Close(fsys); err != nil {
+			return nil, err
+		}
+		zipFS, err = NewFSFile(dirFS, "conformance.zip", false, testLogger)
+		return zipFS, err
+	}
+	writefstest.TestReadWriteFS(t, zipFS, &writefstest.Options{Reopen: reopen})
+	if err := writefs.Close(zipFS); err != nil {
+		t.Fatal(err)
+	}
+}